@@ -0,0 +1,261 @@
+package memodid
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"golang.org/x/xerrors"
+)
+
+// ControllerGraph is the transitive controller graph of a DID.
+// Deactivated DIDs are kept as nodes but their controllers are not followed.
+type ControllerGraph struct {
+	Root MemoDID
+
+	// resolved documents of all reachable DIDs, indexed by memo-specific-id
+	Documents map[string]*MemoDIDDocument
+
+	// activated controllers of each reachable DID, indexed by memo-specific-id
+	Controllers map[string][]MemoDID
+
+	// reachable DIDs that are deactivated
+	Deactivated []MemoDID
+
+	// controller cycles, each cycle starts and ends with the same DID
+	Cycles [][]MemoDID
+}
+
+// Authority is the result of evaluating a signer's authority over a DID
+type Authority struct {
+	Authorized bool
+
+	// controller chain from the DID to the DID holding Method,
+	// Chain[0] is the evaluated DID and Chain[len(Chain)-1] is the signer DID
+	Chain []MemoDID
+
+	// verification method through which the signer has authority
+	Method *VerificationMethod
+}
+
+// ResolveControllerGraph resolves did and all of its controllers recursively
+func ResolveControllerGraph(resolver DIDResolver, didString string) (*ControllerGraph, error) {
+	did, err := ParseMemoDID(didString)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &ControllerGraph{
+		Root:        *did,
+		Documents:   make(map[string]*MemoDIDDocument),
+		Controllers: make(map[string][]MemoDID),
+	}
+
+	// depth first search, 'onPath' records dids on current path to find cycles
+	var path []MemoDID
+	onPath := make(map[string]bool)
+	var visit func(did MemoDID) error
+	visit = func(did MemoDID) error {
+		if onPath[did.Identifier] {
+			cycle := []MemoDID{did}
+			for i := len(path) - 1; i >= 0; i-- {
+				cycle = append([]MemoDID{path[i]}, cycle...)
+				if path[i].Identifier == did.Identifier {
+					break
+				}
+			}
+			g.Cycles = append(g.Cycles, cycle)
+			return nil
+		}
+		if _, ok := g.Documents[did.Identifier]; ok {
+			return nil
+		}
+
+		document, err := resolver.Resolve(did.String())
+		if err != nil {
+			return err
+		}
+		g.Documents[did.Identifier] = document
+		if isDeactivatedDocument(document) {
+			g.Deactivated = append(g.Deactivated, did)
+			return nil
+		}
+		g.Controllers[did.Identifier] = document.Controller
+
+		path = append(path, did)
+		onPath[did.Identifier] = true
+		for _, controller := range document.Controller {
+			if err := visit(controller); err != nil {
+				return err
+			}
+		}
+		onPath[did.Identifier] = false
+		path = path[:len(path)-1]
+
+		return nil
+	}
+
+	if err := visit(*did); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// IsDeactivated reports whether a reachable did is deactivated
+func (g *ControllerGraph) IsDeactivated(did MemoDID) bool {
+	document, ok := g.Documents[did.Identifier]
+	return ok && isDeactivatedDocument(document)
+}
+
+// EvaluateAuthority reports whether signer has authority over did.
+// signer can be a DID, a DID URL or a hex encoded public key. A DID has authority over
+// itself and over every DID it controls directly or through a chain of activated controllers.
+// Authority is exercised through the masterKey, an authentication or a capabilityInvocation method of the signer DID.
+func EvaluateAuthority(resolver DIDResolver, didString string, signer string) (*Authority, error) {
+	g, err := ResolveControllerGraph(resolver, didString)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.EvaluateAuthority(signer)
+}
+
+// EvaluateAuthority reports whether signer has authority over the root of g, see EvaluateAuthority
+func (g *ControllerGraph) EvaluateAuthority(signer string) (*Authority, error) {
+	var match func(did MemoDID, document *MemoDIDDocument) *VerificationMethod
+	switch {
+	case strings.HasPrefix(signer, "did:") && strings.Contains(signer, "#"):
+		didUrl, err := ParseMemoDIDUrl(signer)
+		if err != nil {
+			return nil, err
+		}
+		match = func(did MemoDID, document *MemoDIDDocument) *VerificationMethod {
+			if did.Identifier != didUrl.Identifier {
+				return nil
+			}
+			method := findVerificationMethod(document, *didUrl)
+			if method == nil || !hasAuthority(document, method.ID) {
+				return nil
+			}
+			return method
+		}
+	case strings.HasPrefix(signer, "did:"):
+		signerDID, err := ParseMemoDID(signer)
+		if err != nil {
+			return nil, err
+		}
+		match = func(did MemoDID, document *MemoDIDDocument) *VerificationMethod {
+			if did.Identifier != signerDID.Identifier {
+				return nil
+			}
+			for i := range document.VerificationMethod {
+				if hasAuthority(document, document.VerificationMethod[i].ID) {
+					return &document.VerificationMethod[i]
+				}
+			}
+			return nil
+		}
+	default:
+		publicKey, err := hexutil.Decode(normalizeHex(signer))
+		if err != nil {
+			return nil, xerrors.Errorf("signer %s is neither a did nor a hex public key", signer)
+		}
+		match = func(did MemoDID, document *MemoDIDDocument) *VerificationMethod {
+			for i := range document.VerificationMethod {
+				method := &document.VerificationMethod[i]
+				key, err := method.PublicKeyBytes()
+				if err == nil && bytes.Equal(key, publicKey) && hasAuthority(document, method.ID) {
+					return method
+				}
+			}
+			return nil
+		}
+	}
+
+	// breadth first search gives the shortest controller chain
+	parent := make(map[string]*MemoDID)
+	visited := map[string]bool{g.Root.Identifier: true}
+	queue := []MemoDID{g.Root}
+	for len(queue) > 0 {
+		did := queue[0]
+		queue = queue[1:]
+
+		document := g.Documents[did.Identifier]
+		if document == nil || isDeactivatedDocument(document) {
+			continue
+		}
+
+		if method := match(did, document); method != nil {
+			chain := []MemoDID{did}
+			for p := parent[did.Identifier]; p != nil; p = parent[p.Identifier] {
+				chain = append([]MemoDID{*p}, chain...)
+			}
+			return &Authority{
+				Authorized: true,
+				Chain:      chain,
+				Method:     method,
+			}, nil
+		}
+
+		for _, controller := range g.Controllers[did.Identifier] {
+			if visited[controller.Identifier] {
+				continue
+			}
+			visited[controller.Identifier] = true
+			p := did
+			parent[controller.Identifier] = &p
+			queue = append(queue, controller)
+		}
+	}
+
+	return &Authority{Authorized: false}, nil
+}
+
+// hasAuthority reports whether the method can act on behalf of the document's DID
+func hasAuthority(document *MemoDIDDocument, didUrl MemoDIDUrl) bool {
+	if didUrl.Identifier != document.ID.Identifier {
+		return false
+	}
+	if didUrl.GetMethodIndex() == 0 {
+		return true
+	}
+	return containsDIDUrl(document.Authentication, didUrl) || containsDIDUrl(document.CapabilityInvocation, didUrl)
+}
+
+func containsDIDUrl(didUrls []MemoDIDUrl, didUrl MemoDIDUrl) bool {
+	for _, id := range didUrls {
+		if sameDIDUrl(id, didUrl) {
+			return true
+		}
+	}
+	return false
+}
+
+func findVerificationMethod(document *MemoDIDDocument, didUrl MemoDIDUrl) *VerificationMethod {
+	for i := range document.VerificationMethod {
+		if sameDIDUrl(document.VerificationMethod[i].ID, didUrl) {
+			return &document.VerificationMethod[i]
+		}
+	}
+	return nil
+}
+
+// sameDIDUrl compares did urls without chain segment, a did url qualified
+// with the chain of the document is the same as the unqualified one
+func sameDIDUrl(a, b MemoDIDUrl) bool {
+	a, b = a.withoutChain(), b.withoutChain()
+	return a.String() == b.String()
+}
+
+// resolver returns an empty document for deactivated DID
+func isDeactivatedDocument(document *MemoDIDDocument) bool {
+	return document.ID.Identifier == ""
+}
+
+func normalizeHex(s string) string {
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return "0x" + s
+	}
+	return s
+}
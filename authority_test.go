@@ -0,0 +1,151 @@
+package memodid
+
+import (
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+// mockResolver resolves documents from memory, deactivated dids are mapped to empty documents
+type mockResolver map[string]*MemoDIDDocument
+
+var _ DIDResolver = mockResolver{}
+
+func (r mockResolver) Resolve(didString string) (*MemoDIDDocument, error) {
+	// documents are kept by dids without chain segment
+	if did, err := ParseMemoDID(didString); err == nil {
+		onChain := did.withoutChain()
+		didString = onChain.String()
+	}
+	document, ok := r[didString]
+	if !ok {
+		return nil, xerrors.Errorf("%s: %w", didString, ErrNotFound)
+	}
+	return document, nil
+}
+
+func (r mockResolver) Dereference(didUrlString string) (string, string, error) {
+	didUrl, err := ParseMemoDIDUrl(didUrlString)
+	if err != nil {
+		return "", "", err
+	}
+	did := didUrl.DID()
+	document, err := r.Resolve(did.String())
+	if err != nil {
+		return "", "", err
+	}
+	method := findVerificationMethod(document, *didUrl)
+	if method == nil {
+		return "", "", xerrors.Errorf("%s: %w", didUrlString, ErrNotFound)
+	}
+	return method.Type, method.PublicKeyHex, nil
+}
+
+func (r mockResolver) add(document *MemoDIDDocument) {
+	r[document.ID.String()] = document
+}
+
+// genDocument creates a document with a secp256k1 masterKey derived from privateKeyHex
+func genDocument(privateKeyHex string, controllers ...MemoDID) (*MemoDIDDocument, error) {
+	publicKeyHex, did, err := CreatSimpleDID(privateKeyHex)
+	if err != nil {
+		return nil, err
+	}
+	masterKey, err := genVerificationMethod(did, 0, did, "EcdsaSecp256k1VerificationKey2019", publicKeyHex)
+	if err != nil {
+		return nil, err
+	}
+	return &MemoDIDDocument{
+		Context:            DefaultContext,
+		ID:                 *did,
+		Controller:         controllers,
+		VerificationMethod: []VerificationMethod{masterKey},
+	}, nil
+}
+
+func TestEvaluateAuthority(t *testing.T) {
+	docA, err := genDocument(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	docB, err := genDocument(globalPrivateKey2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	docC, err := genDocument(globalPrivateKey3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// A <- B <- C <- A (cycle)
+	docA.Controller = []MemoDID{docB.ID}
+	docB.Controller = []MemoDID{docC.ID}
+	docC.Controller = []MemoDID{docA.ID}
+	resolver := mockResolver{}
+	resolver.add(docA)
+	resolver.add(docB)
+	resolver.add(docC)
+
+	graph, err := ResolveControllerGraph(resolver, docA.ID.String())
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(graph.Cycles) != 1 || len(graph.Cycles[0]) != 4 {
+		t.Errorf("Unexpect cycles: %v", graph.Cycles)
+		return
+	}
+
+	// by did
+	authority, err := EvaluateAuthority(resolver, docA.ID.String(), docC.ID.String())
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !authority.Authorized || len(authority.Chain) != 3 || authority.Method.ID.String() != docC.VerificationMethod[0].ID.String() {
+		t.Errorf("Unexpect authority: %v", authority)
+		return
+	}
+
+	// by public key
+	authority, err = EvaluateAuthority(resolver, docA.ID.String(), docB.VerificationMethod[0].PublicKeyHex)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !authority.Authorized || len(authority.Chain) != 2 || authority.Chain[1].String() != docB.ID.String() {
+		t.Errorf("Unexpect authority: %v", authority)
+		return
+	}
+
+	// by did url, key-1 is not in authentication
+	keyURL, err := docB.ID.DIDUrl(1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	authority, err = EvaluateAuthority(resolver, docA.ID.String(), keyURL.String())
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if authority.Authorized {
+		t.Error("Unknown verification method should not have authority")
+		return
+	}
+
+	// deactivate B, so C loses authority over A
+	resolver[docB.ID.String()] = &MemoDIDDocument{}
+	authority, err = EvaluateAuthority(resolver, docA.ID.String(), docC.ID.String())
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if authority.Authorized {
+		t.Error("Authority should not pass through a deactivated controller")
+		return
+	}
+}
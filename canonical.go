@@ -0,0 +1,296 @@
+package memodid
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/xerrors"
+)
+
+// Normalize sorts controllers, verification methods and relationships by DID and method index
+// and removes duplicated entries, so documents with the same content have the same representation
+func (d *MemoDIDDocument) Normalize() {
+	d.Controller = normalizeDIDs(d.Controller)
+
+	sort.SliceStable(d.VerificationMethod, func(i, j int) bool {
+		return compareDIDUrl(&d.VerificationMethod[i].ID, &d.VerificationMethod[j].ID) < 0
+	})
+	methods := d.VerificationMethod[:0]
+	for i, method := range d.VerificationMethod {
+		if i > 0 && compareDIDUrl(&method.ID, &d.VerificationMethod[i-1].ID) == 0 {
+			continue
+		}
+		methods = append(methods, method)
+	}
+	d.VerificationMethod = methods
+
+	d.Authentication = normalizeDIDUrls(d.Authentication)
+	d.AssertionMethod = normalizeDIDUrls(d.AssertionMethod)
+	d.CapabilityDelegation = normalizeDIDUrls(d.CapabilityDelegation)
+	d.Recovery = normalizeDIDUrls(d.Recovery)
+	d.KeyAgreement = normalizeDIDUrls(d.KeyAgreement)
+	d.CapabilityInvocation = normalizeDIDUrls(d.CapabilityInvocation)
+}
+
+// MarshalJCS encodes the normalized document as canonical JSON(RFC 8785)
+func (d *MemoDIDDocument) MarshalJCS() ([]byte, error) {
+	document := d.clone()
+	document.Normalize()
+	return marshalJCS(document)
+}
+
+// Digest returns sha256 hash of the canonical JSON of the document
+func (d *MemoDIDDocument) Digest() ([]byte, error) {
+	data, err := d.MarshalJCS()
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(data)
+	return hash[:], nil
+}
+
+// clone returns a copy of the document which doesn't share slices with d
+func (d *MemoDIDDocument) clone() *MemoDIDDocument {
+	document := *d
+	document.Controller = append([]MemoDID(nil), d.Controller...)
+	document.VerificationMethod = append([]VerificationMethod(nil), d.VerificationMethod...)
+	document.Authentication = append([]MemoDIDUrl(nil), d.Authentication...)
+	document.AssertionMethod = append([]MemoDIDUrl(nil), d.AssertionMethod...)
+	document.CapabilityDelegation = append([]MemoDIDUrl(nil), d.CapabilityDelegation...)
+	document.Recovery = append([]MemoDIDUrl(nil), d.Recovery...)
+	document.KeyAgreement = append([]MemoDIDUrl(nil), d.KeyAgreement...)
+	document.CapabilityInvocation = append([]MemoDIDUrl(nil), d.CapabilityInvocation...)
+	if d.CapabilityDelegationExpiration != nil {
+		document.CapabilityDelegationExpiration = make(map[string]int64, len(d.CapabilityDelegationExpiration))
+		for id, expiration := range d.CapabilityDelegationExpiration {
+			document.CapabilityDelegationExpiration[id] = expiration
+		}
+	}
+	return &document
+}
+
+func normalizeDIDs(dids []MemoDID) []MemoDID {
+	if len(dids) == 0 {
+		return nil
+	}
+	sort.SliceStable(dids, func(i, j int) bool {
+		return strings.ToLower(dids[i].Identifier) < strings.ToLower(dids[j].Identifier)
+	})
+	result := dids[:1]
+	for _, did := range dids[1:] {
+		if !strings.EqualFold(did.Identifier, result[len(result)-1].Identifier) {
+			result = append(result, did)
+		}
+	}
+	return result
+}
+
+func normalizeDIDUrls(didUrls []MemoDIDUrl) []MemoDIDUrl {
+	if len(didUrls) == 0 {
+		return nil
+	}
+	sort.SliceStable(didUrls, func(i, j int) bool {
+		return compareDIDUrl(&didUrls[i], &didUrls[j]) < 0
+	})
+	result := didUrls[:1]
+	for i := 1; i < len(didUrls); i++ {
+		if compareDIDUrl(&didUrls[i], &result[len(result)-1]) != 0 {
+			result = append(result, didUrls[i])
+		}
+	}
+	return result
+}
+
+// compareDIDUrl orders DID URLs by identifier and then by method index
+func compareDIDUrl(a, b *MemoDIDUrl) int {
+	if c := strings.Compare(strings.ToLower(a.Identifier), strings.ToLower(b.Identifier)); c != 0 {
+		return c
+	}
+	ia, ib := a.GetMethodIndex(), b.GetMethodIndex()
+	switch {
+	case ia < ib:
+		return -1
+	case ia > ib:
+		return 1
+	default:
+		return strings.Compare(a.Fragment, b.Fragment)
+	}
+}
+
+// marshalJCS encodes the JSON data model of v with JSON Canonicalization Scheme(RFC 8785)
+func marshalJCS(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := encodeJCS(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeJCS(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case string:
+		return encodeJCSString(buf, v)
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		s, err := formatES6Number(f)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeJCS(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		// properties are sorted by their UTF-16 code units
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return lessUTF16(keys[i], keys[j])
+		})
+
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeJCSString(buf, key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := encodeJCS(buf, v[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return xerrors.Errorf("jcs: unsupported type %T", value)
+	}
+	return nil
+}
+
+func encodeJCSString(buf *bytes.Buffer, s string) error {
+	if !utf8.ValidString(s) {
+		return xerrors.Errorf("jcs: invalid utf-8 string %q", s)
+	}
+
+	const hexDigits = "0123456789abcdef"
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[r>>4])
+				buf.WriteByte(hexDigits[r&0xf])
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+	return nil
+}
+
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// formatES6Number formats f as ECMAScript Number.prototype.toString does
+func formatES6Number(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", xerrors.Errorf("jcs: %v is not a valid json number", f)
+	}
+	if f == 0 {
+		return "0", nil
+	}
+
+	sign := ""
+	if f < 0 {
+		sign = "-"
+		f = -f
+	}
+
+	// shortest digits and exponent, e.g. 1.2345e+21
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exponent, _ := strings.Cut(s, "e")
+	digits := strings.Replace(mantissa, ".", "", 1)
+	e, err := strconv.Atoi(exponent)
+	if err != nil {
+		return "", err
+	}
+	k, n := len(digits), e+1
+
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k), nil
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:], nil
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits, nil
+	}
+
+	result := sign + digits[:1]
+	if k > 1 {
+		result += "." + digits[1:]
+	}
+	if n-1 >= 0 {
+		return result + "e+" + strconv.Itoa(n-1), nil
+	}
+	return result + "e" + strconv.Itoa(n-1), nil
+}
@@ -0,0 +1,126 @@
+package memodid
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	controller1, err := genDocument(globalPrivateKey2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	controller2, err := genDocument(globalPrivateKey3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document, err := genDocument(globalPrivateKey1, controller1.ID, controller2.ID)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	key1, err := document.ID.DIDUrl(1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	key10, err := document.ID.DIDUrl(10)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	masterKey := document.VerificationMethod[0].ID
+	document.Authentication = []MemoDIDUrl{*key10, masterKey, *key1, masterKey}
+
+	reordered := *document.clone()
+	reordered.Controller = []MemoDID{controller2.ID, controller1.ID, controller2.ID}
+	reordered.Authentication = []MemoDIDUrl{*key1, *key10, masterKey}
+
+	digest1, err := document.Digest()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	digest2, err := reordered.Digest()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !bytes.Equal(digest1, digest2) {
+		t.Error("Documents with the same content should have the same digest")
+		return
+	}
+	// digest doesn't change the document
+	if len(document.Authentication) != 4 {
+		t.Errorf("Unexpect authentication: %v", document.Authentication)
+		return
+	}
+
+	document.Normalize()
+	if len(document.Controller) != 2 || len(document.Authentication) != 3 {
+		t.Errorf("Duplicated entries should be removed: %v", document)
+		return
+	}
+	// key-10 is ordered by method index, not by string
+	if document.Authentication[0].String() != masterKey.String() || document.Authentication[1].Fragment != "key-1" || document.Authentication[2].Fragment != "key-10" {
+		t.Errorf("Unexpect order of authentication: %v", document.Authentication)
+	}
+}
+
+func TestMarshalJCS(t *testing.T) {
+	document, err := genDocument(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	data, err := document.MarshalJCS()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	t.Log(string(data))
+
+	method := document.VerificationMethod[0]
+	expected := `{"@context":"` + DefaultContext + `","id":"` + document.ID.String() + `","verificationMethod":[{"controller":"` +
+		method.Controller.String() + `","id":"` + method.ID.String() + `","publicKeyHex":"` + method.PublicKeyHex + `","type":"` + method.Type + `"}]}`
+	if string(data) != expected {
+		t.Errorf("Unexpect canonical json: %s", data)
+		return
+	}
+
+	var result MemoDIDDocument
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func TestJCSEncoding(t *testing.T) {
+	// examples of RFC 8785
+	data, err := marshalJCS(json.RawMessage(`{"numbers":[333333333.33333329,1E30,4.50,2e-3,0.000000000000000000000000001],` +
+		`"string":"€$\u000F\u000aA'B\"\\\\\"\/","literals":[null,true,false]}`))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	expected := `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],` +
+		`"string":"€$\u000f\nA'B\"\\\\\"/"}`
+	if string(data) != expected {
+		t.Errorf("Unexpect canonical json: %s", data)
+		return
+	}
+
+	data, err = marshalJCS(json.RawMessage(`{"€":1,"\r":2,"\ufb33":3,"1":4,"😀":5,"\u0080":6,"ö":7}`))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	// surrogate pairs are ordered before U+FB33
+	expected = `{"\r":2,"1":4,"` + "\u0080" + `":6,"ö":7,"€":1,"😀":5,"` + "\ufb33" + `":3}`
+	if string(data) != expected {
+		t.Errorf("Unexpect property order: %s", data)
+	}
+}
@@ -0,0 +1,396 @@
+package memodid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+
+	"golang.org/x/xerrors"
+)
+
+// CBORMediaType is the media type of CBOR encoded did document
+const CBORMediaType = "application/did+cbor"
+
+// maximum nesting depth of decoded CBOR data items
+const cborMaxDepth = 32
+
+// MarshalCBOR encodes the document as application/did+cbor
+func (d *MemoDIDDocument) MarshalCBOR() ([]byte, error) {
+	return marshalCBOR(d)
+}
+
+// UnmarshalCBOR decodes application/did+cbor document
+func (d *MemoDIDDocument) UnmarshalCBOR(data []byte) error {
+	return unmarshalCBOR(data, d)
+}
+
+func (v VerificationMethod) MarshalCBOR() ([]byte, error) {
+	return marshalCBOR(v)
+}
+
+func (v *VerificationMethod) UnmarshalCBOR(data []byte) error {
+	return unmarshalCBOR(data, v)
+}
+
+func (d MemoDID) MarshalCBOR() ([]byte, error) {
+	return marshalCBOR(d)
+}
+
+func (d *MemoDID) UnmarshalCBOR(data []byte) error {
+	return unmarshalCBOR(data, d)
+}
+
+func (d MemoDIDUrl) MarshalCBOR() ([]byte, error) {
+	return marshalCBOR(d)
+}
+
+func (d *MemoDIDUrl) UnmarshalCBOR(data []byte) error {
+	return unmarshalCBOR(data, d)
+}
+
+// marshalCBOR encodes the JSON data model of v with core deterministic encoding(RFC 8949 section 4.2),
+// so the CBOR and JSON representations carry the same properties and values
+func marshalCBOR(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := encodeCBOR(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func unmarshalCBOR(data []byte, v interface{}) error {
+	d := &cborDecoder{data: data}
+	value, err := d.decode(0)
+	if err != nil {
+		return err
+	}
+	if d.pos != len(data) {
+		return xerrors.Errorf("cbor: %d trailing bytes", len(data)-d.pos)
+	}
+
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(jsonData, v)
+}
+
+const (
+	cborUnsigned = 0
+	cborNegative = 1
+	cborText     = 3
+	cborArray    = 4
+	cborMap      = 5
+	cborSimple   = 7
+)
+
+// encodeHead writes the shortest head of major type with argument n
+func encodeHead(buf *bytes.Buffer, major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		buf.WriteByte(major | byte(n))
+	case n <= math.MaxUint8:
+		buf.Write([]byte{major | 24, byte(n)})
+	case n <= math.MaxUint16:
+		buf.WriteByte(major | 25)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case n <= math.MaxUint32:
+		buf.WriteByte(major | 26)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		buf.WriteByte(major | 27)
+		buf.Write(binary.BigEndian.AppendUint64(nil, n))
+	}
+}
+
+func encodeCBOR(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(0xf6)
+	case bool:
+		if v {
+			buf.WriteByte(0xf5)
+		} else {
+			buf.WriteByte(0xf4)
+		}
+	case string:
+		encodeHead(buf, cborText, uint64(len(v)))
+		buf.WriteString(v)
+	case json.Number:
+		return encodeNumber(buf, v)
+	case []interface{}:
+		encodeHead(buf, cborArray, uint64(len(v)))
+		for _, item := range v {
+			if err := encodeCBOR(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		// keys are sorted by bytewise order of their encodings
+		keys := make([][]byte, 0, len(v))
+		encodedKeys := make(map[string]string, len(v))
+		for key := range v {
+			var kb bytes.Buffer
+			encodeHead(&kb, cborText, uint64(len(key)))
+			kb.WriteString(key)
+			keys = append(keys, kb.Bytes())
+			encodedKeys[string(kb.Bytes())] = key
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return bytes.Compare(keys[i], keys[j]) < 0
+		})
+
+		encodeHead(buf, cborMap, uint64(len(v)))
+		for _, key := range keys {
+			buf.Write(key)
+			if err := encodeCBOR(buf, v[encodedKeys[string(key)]]); err != nil {
+				return err
+			}
+		}
+	default:
+		return xerrors.Errorf("cbor: unsupported type %T", value)
+	}
+	return nil
+}
+
+func encodeNumber(buf *bytes.Buffer, n json.Number) error {
+	if i, ok := new(big.Int).SetString(n.String(), 10); ok {
+		switch {
+		case i.Sign() >= 0 && i.IsUint64():
+			encodeHead(buf, cborUnsigned, i.Uint64())
+			return nil
+		case i.Sign() < 0:
+			// -1 - n
+			m := new(big.Int).Sub(new(big.Int).Neg(i), big.NewInt(1))
+			if m.IsUint64() {
+				encodeHead(buf, cborNegative, m.Uint64())
+				return nil
+			}
+		}
+		return xerrors.Errorf("cbor: integer %s out of range", n)
+	}
+
+	f, err := n.Float64()
+	if err != nil {
+		return err
+	}
+	encodeFloat(buf, f)
+	return nil
+}
+
+// encodeFloat encodes f in the shortest of 16, 32 and 64 bits that keeps its value,
+// as the deterministic encoding of RFC 8949 section 4.2.1 requires
+func encodeFloat(buf *bytes.Buffer, f float64) {
+	if f32 := float32(f); float64(f32) == f || math.IsNaN(f) {
+		if h, ok := float16Bits(f32); ok {
+			buf.WriteByte(0xf9)
+			buf.Write(binary.BigEndian.AppendUint16(nil, h))
+			return
+		}
+		buf.WriteByte(0xfa)
+		buf.Write(binary.BigEndian.AppendUint32(nil, math.Float32bits(f32)))
+		return
+	}
+	buf.WriteByte(0xfb)
+	buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+}
+
+// float16Bits returns the half precision bits of f if f can be represented exactly
+func float16Bits(f float32) (uint16, bool) {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+
+	switch {
+	case exp == 0xff && mant != 0:
+		// NaN is encoded as the canonical quiet NaN
+		return 0x7e00, true
+	case exp == 0xff:
+		return sign | 0x7c00, true
+	case exp == 0 && mant == 0:
+		return sign, true
+	case exp == 0:
+		// subnormal float32 is too small for half precision
+		return 0, false
+	}
+
+	e := exp - 127
+	switch {
+	case e >= -14 && e <= 15:
+		if mant&0x1fff != 0 {
+			return 0, false
+		}
+		return sign | uint16(e+15)<<10 | uint16(mant>>13), true
+	case e >= -24 && e < -14:
+		// subnormal half precision is m * 2^-24
+		full := mant | 0x800000
+		shift := uint(-(e + 1))
+		if full&(1<<shift-1) != 0 {
+			return 0, false
+		}
+		return sign | uint16(full>>shift), true
+	}
+	return 0, false
+}
+
+// float16Value returns the value of half precision bits h
+func float16Value(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant != 0 {
+			return math.NaN()
+		}
+		f = math.Inf(1)
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *cborDecoder) read(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, xerrors.Errorf("cbor: unexpected end of data")
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// head returns major type, additional info and argument
+func (d *cborDecoder) head() (byte, byte, uint64, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+
+	var n uint64
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info <= 27:
+		arg, err := d.read(1 << (info - 24))
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		for _, x := range arg {
+			n = n<<8 | uint64(x)
+		}
+	default:
+		return 0, 0, 0, xerrors.Errorf("cbor: indefinite length and reserved values are not supported")
+	}
+
+	return major, info, n, nil
+}
+
+func (d *cborDecoder) decode(depth int) (interface{}, error) {
+	if depth > cborMaxDepth {
+		return nil, xerrors.Errorf("cbor: exceed max depth %d", cborMaxDepth)
+	}
+
+	major, info, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUnsigned:
+		return json.Number(strconv.FormatUint(n, 10)), nil
+	case cborNegative:
+		i := new(big.Int).SetUint64(n)
+		return json.Number(i.Neg(i).Sub(i, big.NewInt(1)).String()), nil
+	case cborText:
+		b, err := d.read(n)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case cborArray:
+		// each item takes at least one byte
+		if n > uint64(len(d.data)-d.pos) {
+			return nil, xerrors.Errorf("cbor: unexpected end of data")
+		}
+		items := make([]interface{}, 0, n)
+		for i := uint64(0); i < n; i++ {
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case cborMap:
+		if n > uint64(len(d.data)-d.pos) {
+			return nil, xerrors.Errorf("cbor: unexpected end of data")
+		}
+		m := make(map[string]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			key, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, xerrors.Errorf("cbor: map key must be text string")
+			}
+			if _, ok := m[k]; ok {
+				return nil, xerrors.Errorf("cbor: duplicate map key %s", k)
+			}
+			value, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = value
+		}
+		return m, nil
+	case cborSimple:
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22:
+			return nil, nil
+		case 25:
+			return float16Value(uint16(n)), nil
+		case 26:
+			return float64(math.Float32frombits(uint32(n))), nil
+		case 27:
+			return math.Float64frombits(n), nil
+		}
+		return nil, xerrors.Errorf("cbor: unsupported simple value %d", info)
+	default:
+		return nil, xerrors.Errorf("cbor: unsupported major type %d", major)
+	}
+}
@@ -0,0 +1,193 @@
+package memodid
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestDocumentCBOR(t *testing.T) {
+	controller, err := genDocument(globalPrivateKey2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document, err := genDocument(globalPrivateKey1, controller.ID)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document.Authentication = []MemoDIDUrl{document.VerificationMethod[0].ID}
+	document.CapabilityInvocation = []MemoDIDUrl{document.VerificationMethod[0].ID}
+
+	data, err := document.MarshalCBOR()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	t.Log(hex.EncodeToString(data))
+
+	var result MemoDIDDocument
+	err = result.UnmarshalCBOR(data)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !reflect.DeepEqual(*document, result) {
+		t.Errorf("Unexpect document from cbor: %v", result)
+		return
+	}
+
+	// same content as json form
+	jsonData, err := json.Marshal(document)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	resultJSON, err := json.Marshal(&result)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !bytes.Equal(jsonData, resultJSON) {
+		t.Errorf("Unexpect json form: %s", resultJSON)
+		return
+	}
+
+	// deterministic
+	again, err := result.MarshalCBOR()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !bytes.Equal(data, again) {
+		t.Error("CBOR encoding is not deterministic")
+	}
+}
+
+func TestDIDCBOR(t *testing.T) {
+	did, err := ParseMemoDID("did:memo:d687daa192ffa26373395872191e8502cc41fbfbf27dc07d3da3d7f4d6f24f5e")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	data, err := did.MarshalCBOR()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	// text string of 73 bytes
+	if data[0] != 0x78 || data[1] != 73 || string(data[2:]) != did.String() {
+		t.Errorf("Unexpect cbor of did: %x", data)
+		return
+	}
+	var resultDID MemoDID
+	err = resultDID.UnmarshalCBOR(data)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if resultDID.String() != did.String() {
+		t.Errorf("Unexpect did: %s", resultDID.String())
+		return
+	}
+
+	didUrl, err := ParseMemoDIDUrl(did.String() + "#masterKey")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	data, err = didUrl.MarshalCBOR()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	var resultDIDUrl MemoDIDUrl
+	err = resultDIDUrl.UnmarshalCBOR(data)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if resultDIDUrl.String() != didUrl.String() {
+		t.Errorf("Unexpect did url: %s", resultDIDUrl.String())
+	}
+}
+
+func TestCBOREncoding(t *testing.T) {
+	var value interface{}
+	err := json.Unmarshal([]byte(`{"b":[1,-1,500,null,true],"aa":"x","a":false}`), &value)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	data, err := marshalCBOR(value)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	// shorter keys first, then bytewise order
+	expected := "a3" + "6161f4" + "6162" + "85" + "01" + "20" + "1901f4" + "f6" + "f5" + "626161" + "6178"
+	if hex.EncodeToString(data) != expected {
+		t.Errorf("Unexpect cbor encoding: %x", data)
+		return
+	}
+
+	// floating-point vectors of RFC 8949 Appendix A
+	for value, expected := range map[float64]string{
+		0.0:                    "f90000",
+		math.Copysign(0, -1):   "f98000",
+		1.0:                    "f93c00",
+		1.1:                    "fb3ff199999999999a",
+		1.5:                    "f93e00",
+		65504.0:                "f97bff",
+		100000.0:               "fa47c35000",
+		3.4028234663852886e+38: "fa7f7fffff",
+		1.0e+300:               "fb7e37e43c8800759c",
+		5.960464477539063e-8:   "f90001",
+		0.00006103515625:       "f90400",
+		-4.0:                   "f9c400",
+		-4.1:                   "fbc010666666666666",
+		math.Inf(1):            "f97c00",
+		math.Inf(-1):           "f9fc00",
+	} {
+		var buf bytes.Buffer
+		encodeFloat(&buf, value)
+		if hex.EncodeToString(buf.Bytes()) != expected {
+			t.Errorf("Unexpect cbor encoding of %v: %x", value, buf.Bytes())
+			return
+		}
+		d := &cborDecoder{data: buf.Bytes()}
+		result, err := d.decode(0)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if decoded, ok := result.(float64); !ok || decoded != value || math.Signbit(decoded) != math.Signbit(value) {
+			t.Errorf("Unexpect decoded value of %x: %v", buf.Bytes(), result)
+			return
+		}
+	}
+	var buf bytes.Buffer
+	encodeFloat(&buf, math.NaN())
+	if hex.EncodeToString(buf.Bytes()) != "f97e00" {
+		t.Errorf("Unexpect cbor encoding of NaN: %x", buf.Bytes())
+		return
+	}
+
+	invalid := [][]byte{
+		{0xa1, 0x01, 0x01}, // non text key
+		{0x9f, 0x01, 0xff}, // indefinite length
+		{0x62, 0x61},       // truncated
+		{0x01, 0x01},       // trailing bytes
+		{0xa2, 0x61, 0x61, 0x01, 0x61, 0x61, 0x02}, // duplicate key
+	}
+	for _, data := range invalid {
+		var result interface{}
+		if err := unmarshalCBOR(data, &result); err == nil {
+			t.Errorf("Invalid cbor %x should be rejected", data)
+		}
+	}
+}
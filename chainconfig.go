@@ -0,0 +1,207 @@
+package memodid
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	com "github.com/memoio/contractsv2/common"
+	inst "github.com/memoio/contractsv2/go_contracts/instance"
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v3"
+)
+
+// environment variables of ChainConfig
+const (
+	EnvChainConfig   = "MEMO_DID_CONFIG"
+	EnvEndpoint      = "MEMO_DID_ENDPOINT"
+	EnvChainID       = "MEMO_DID_CHAIN_ID"
+	EnvInstance      = "MEMO_DID_INSTANCE"
+	EnvProxy         = "MEMO_DID_PROXY"
+	EnvAccountDid    = "MEMO_DID_ACCOUNT_DID"
+	EnvBlockTime     = "MEMO_DID_BLOCK_TIME"
+	EnvConfirmations = "MEMO_DID_CONFIRMATIONS"
+)
+
+// ChainConfig describes a memo chain, such as a private network.
+// Contract addresses are read from the Instance contract unless Proxy and AccountDid are given.
+type ChainConfig struct {
+	Endpoint string `json:"endpoint"`
+	// it is queried from the endpoint if it is nil
+	ChainID    *big.Int       `json:"chainId,omitempty"`
+	Instance   common.Address `json:"instance,omitempty"`
+	Proxy      common.Address `json:"proxy,omitempty"`
+	AccountDid common.Address `json:"accountDid,omitempty"`
+	// seconds, default is 5
+	BlockTime     uint64 `json:"blockTime,omitempty"`
+	Confirmations uint64 `json:"confirmations,omitempty"`
+}
+
+// defaultBlockTime is the block time of memo chains
+const defaultBlockTime = 5
+
+// DefaultChainConfig returns the config of chain known by contractsv2, such as "dev"
+func DefaultChainConfig(chain string) *ChainConfig {
+	instanceAddr, endpoint := com.GetInsEndPointByChain(chain)
+	return &ChainConfig{
+		Endpoint:  endpoint,
+		Instance:  instanceAddr,
+		BlockTime: defaultBlockTime,
+	}
+}
+
+// LoadChainConfig parses the config in yaml or json
+func LoadChainConfig(data []byte) (*ChainConfig, error) {
+	config, err := parseChainConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	return config, config.validate()
+}
+
+func LoadChainConfigFile(path string) (*ChainConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return LoadChainConfig(data)
+}
+
+func parseChainConfig(data []byte) (*ChainConfig, error) {
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ChainConfig{}
+	if err := json.Unmarshal(jsonData, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// LoadChainConfigFromEnv loads the config file in MEMO_DID_CONFIG if it is set,
+// and then overrides it with the other MEMO_DID_* variables
+func LoadChainConfigFromEnv() (*ChainConfig, error) {
+	config := &ChainConfig{}
+	if path := os.Getenv(EnvChainConfig); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		config, err = parseChainConfig(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if endpoint := os.Getenv(EnvEndpoint); endpoint != "" {
+		config.Endpoint = endpoint
+	}
+	if chainID := os.Getenv(EnvChainID); chainID != "" {
+		id, ok := new(big.Int).SetString(chainID, 10)
+		if !ok {
+			return nil, xerrors.Errorf("%s: invalid chain id %s", EnvChainID, chainID)
+		}
+		config.ChainID = id
+	}
+	for env, addr := range map[string]*common.Address{EnvInstance: &config.Instance, EnvProxy: &config.Proxy, EnvAccountDid: &config.AccountDid} {
+		if value := os.Getenv(env); value != "" {
+			if !common.IsHexAddress(value) {
+				return nil, xerrors.Errorf("%s: invalid address %s", env, value)
+			}
+			*addr = common.HexToAddress(value)
+		}
+	}
+	for env, number := range map[string]*uint64{EnvBlockTime: &config.BlockTime, EnvConfirmations: &config.Confirmations} {
+		if value := os.Getenv(env); value != "" {
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, xerrors.Errorf("%s: %w", env, err)
+			}
+			*number = n
+		}
+	}
+
+	return config, config.validate()
+}
+
+func (c *ChainConfig) validate() error {
+	if c.Endpoint == "" {
+		return xerrors.Errorf("chain config has no endpoint")
+	}
+	if c.Instance == (common.Address{}) && (c.Proxy == (common.Address{}) || c.AccountDid == (common.Address{})) {
+		return xerrors.Errorf("chain config needs instance address, or both proxy and accountDid addresses")
+	}
+	return nil
+}
+
+// txWaiter polls the receipt every block, and waits for 12 blocks besides the confirmations
+func (c *ChainConfig) txWaiter() *TxWaiter {
+	blockTime := time.Duration(c.BlockTime) * time.Second
+	if blockTime == 0 {
+		blockTime = defaultBlockTime * time.Second
+	}
+	return &TxWaiter{
+		PollInterval:  blockTime,
+		Timeout:       time.Duration(12+c.Confirmations) * blockTime,
+		Confirmations: c.Confirmations,
+	}
+}
+
+// chainContracts is the chain id and contract addresses got by a config
+type chainContracts struct {
+	chainID     *big.Int
+	proxyAddr   common.Address
+	accountAddr common.Address
+}
+
+func (c *ChainConfig) contracts(client *ethclient.Client) (*chainContracts, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+
+	contracts := &chainContracts{
+		chainID:     c.ChainID,
+		proxyAddr:   c.Proxy,
+		accountAddr: c.AccountDid,
+	}
+	if contracts.chainID == nil {
+		chainID, err := client.ChainID(context.TODO())
+		if err != nil {
+			return nil, xerrors.Errorf("get chain id from %s: %w", c.Endpoint, err)
+		}
+		contracts.chainID = chainID
+	}
+
+	if contracts.proxyAddr == (common.Address{}) || contracts.accountAddr == (common.Address{}) {
+		instanceIns, err := inst.NewInstance(c.Instance, client)
+		if err != nil {
+			return nil, err
+		}
+		if contracts.proxyAddr == (common.Address{}) {
+			contracts.proxyAddr, err = instanceIns.Instances(&bind.CallOpts{}, com.TypeDidProxy)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if contracts.accountAddr == (common.Address{}) {
+			contracts.accountAddr, err = instanceIns.Instances(&bind.CallOpts{}, com.TypeAccountDid)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return contracts, nil
+}
@@ -0,0 +1,88 @@
+package memodid
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestLoadChainConfig(t *testing.T) {
+	yamlData := []byte(`
+endpoint: http://127.0.0.1:8545
+chainId: 1337
+proxy: "0x1000000000000000000000000000000000000001"
+accountDid: "0x1000000000000000000000000000000000000002"
+blockTime: 2
+confirmations: 3
+`)
+	jsonData := []byte(`{"endpoint": "http://127.0.0.1:8545", "chainId": 1337, "proxy": "0x1000000000000000000000000000000000000001",
+	"accountDid": "0x1000000000000000000000000000000000000002", "blockTime": 2, "confirmations": 3}`)
+
+	for _, data := range [][]byte{yamlData, jsonData} {
+		config, err := LoadChainConfig(data)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if config.Endpoint != "http://127.0.0.1:8545" || config.ChainID.Int64() != 1337 ||
+			config.Proxy != common.HexToAddress("0x1000000000000000000000000000000000000001") ||
+			config.AccountDid != common.HexToAddress("0x1000000000000000000000000000000000000002") ||
+			config.BlockTime != 2 || config.Confirmations != 3 {
+			t.Errorf("Unexpect config: %+v", config)
+			return
+		}
+
+		waiter := config.txWaiter()
+		if waiter.PollInterval != 2*time.Second || waiter.Timeout != 30*time.Second || waiter.Confirmations != 3 {
+			t.Errorf("Unexpect waiter: %+v", waiter)
+			return
+		}
+	}
+
+	// no endpoint
+	_, err := LoadChainConfig([]byte(`instance: "0x1000000000000000000000000000000000000001"`))
+	if err == nil {
+		t.Error("Config without endpoint should be invalid")
+		return
+	}
+	// no accountDid
+	_, err = LoadChainConfig([]byte("endpoint: http://127.0.0.1:8545\nproxy: \"0x1000000000000000000000000000000000000001\""))
+	if err == nil {
+		t.Error("Config without instance or accountDid should be invalid")
+		return
+	}
+}
+
+func TestLoadChainConfigFromEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain.yaml")
+	err := os.WriteFile(path, []byte("endpoint: http://127.0.0.1:8545\nblockTime: 2\n"), 0644)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	t.Setenv(EnvChainConfig, path)
+	t.Setenv(EnvEndpoint, "http://10.0.0.1:8545")
+	t.Setenv(EnvInstance, "0x1000000000000000000000000000000000000003")
+	t.Setenv(EnvConfirmations, "1")
+	config, err := LoadChainConfigFromEnv()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if config.Endpoint != "http://10.0.0.1:8545" || config.Instance != common.HexToAddress("0x1000000000000000000000000000000000000003") ||
+		config.BlockTime != 2 || config.Confirmations != 1 || config.ChainID != nil {
+		t.Errorf("Unexpect config: %+v", config)
+		return
+	}
+
+	t.Setenv(EnvChainID, "memo")
+	_, err = LoadChainConfigFromEnv()
+	if err == nil {
+		t.Error("Invalid chain id should be rejected")
+		return
+	}
+}
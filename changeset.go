@@ -0,0 +1,431 @@
+package memodid
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/memoio/did-solidity/go-contracts/proxy"
+	"golang.org/x/xerrors"
+)
+
+// change operations
+const (
+	AddOperation    = "add"
+	UpdateOperation = "update"
+	RemoveOperation = "remove"
+)
+
+// DefaultDelegationExpireTime is the expire time(in seconds) of capabilityDelegation added by Apply
+var DefaultDelegationExpireTime = int64(365 * 24 * 3600)
+
+type ControllerChange struct {
+	Op         string
+	Controller MemoDID
+}
+
+// VerificationMethodChange adds, updates or removes a method, Old is nil for add and New is nil for remove
+type VerificationMethodChange struct {
+	Op  string
+	Old *VerificationMethod
+	New *VerificationMethod
+}
+
+// ID returns DID URL of the changed verification method
+func (c *VerificationMethodChange) ID() MemoDIDUrl {
+	if c.New != nil {
+		return c.New.ID
+	}
+	return c.Old.ID
+}
+
+type RelationShipChange struct {
+	Op           string
+	RelationType int
+	ID           MemoDIDUrl
+	// expire time(in seconds) of added capabilityDelegation
+	ExpireTime int64
+	// expiration(unix time) of added or renewed capabilityDelegation, it takes precedence over ExpireTime
+	Expiration int64
+}
+
+// expireTime returns seconds from now to the expiration of the change
+func (c *RelationShipChange) expireTime() int64 {
+	if c.Expiration > 0 {
+		return c.Expiration - time.Now().Unix()
+	}
+	return c.ExpireTime
+}
+
+// delegationController adds capabilityDelegation with absolute expiration, which doesn't drift
+// with the confirmation time as relative expire time of AddRelationShip does
+type delegationController interface {
+	addDelegationUntil(did MemoDID, didUrl MemoDIDUrl, expiration int64) error
+}
+
+// ChangeSet is the difference between two versions of a did document.
+// Memo did documents have no services, so only controllers, verification methods
+// and relationships are compared.
+type ChangeSet struct {
+	DID                 MemoDID
+	Controllers         []ControllerChange
+	VerificationMethods []VerificationMethodChange
+	RelationShips       []RelationShipChange
+}
+
+// IsEmpty reports whether the two documents are the same
+func (cs *ChangeSet) IsEmpty() bool {
+	return len(cs.Controllers) == 0 && len(cs.VerificationMethods) == 0 && len(cs.RelationShips) == 0
+}
+
+// String lists the changes, one change per line
+func (cs *ChangeSet) String() string {
+	var lines []string
+	for _, change := range cs.Controllers {
+		lines = append(lines, fmt.Sprintf("%s controller %s", change.Op, change.Controller.String()))
+	}
+	for _, change := range cs.VerificationMethods {
+		id := change.ID()
+		switch change.Op {
+		case RemoveOperation:
+			lines = append(lines, fmt.Sprintf("%s verificationMethod %s", change.Op, id.String()))
+		default:
+			lines = append(lines, fmt.Sprintf("%s verificationMethod %s(%s)", change.Op, id.String(), change.New.Type))
+		}
+	}
+	for _, change := range cs.RelationShips {
+		lines = append(lines, fmt.Sprintf("%s %s %s", change.Op, relationShipName(change.RelationType), change.ID.String()))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Diff compares two versions of a did document and returns the changes from old to new
+func Diff(old, new *MemoDIDDocument) (*ChangeSet, error) {
+	if old.ID.Identifier != "" && !strings.EqualFold(old.ID.Identifier, new.ID.Identifier) {
+		return nil, xerrors.Errorf("can't diff documents of different dids: %s, %s", old.ID.String(), new.ID.String())
+	}
+	oldDocument, newDocument := old.clone(), new.clone()
+	oldDocument.Normalize()
+	newDocument.Normalize()
+
+	cs := &ChangeSet{DID: newDocument.ID}
+
+	oldControllers := make(map[string]bool)
+	for _, controller := range oldDocument.Controller {
+		oldControllers[strings.ToLower(controller.Identifier)] = true
+	}
+	newControllers := make(map[string]bool)
+	for _, controller := range newDocument.Controller {
+		newControllers[strings.ToLower(controller.Identifier)] = true
+		if !oldControllers[strings.ToLower(controller.Identifier)] {
+			cs.Controllers = append(cs.Controllers, ControllerChange{Op: AddOperation, Controller: controller})
+		}
+	}
+	for _, controller := range oldDocument.Controller {
+		if !newControllers[strings.ToLower(controller.Identifier)] {
+			cs.Controllers = append(cs.Controllers, ControllerChange{Op: RemoveOperation, Controller: controller})
+		}
+	}
+
+	oldMethods := make(map[string]*VerificationMethod)
+	for i := range oldDocument.VerificationMethod {
+		oldMethods[oldDocument.VerificationMethod[i].ID.String()] = &oldDocument.VerificationMethod[i]
+	}
+	newMethods := make(map[string]*VerificationMethod)
+	for i := range newDocument.VerificationMethod {
+		method := &newDocument.VerificationMethod[i]
+		newMethods[method.ID.String()] = method
+
+		oldMethod, ok := oldMethods[method.ID.String()]
+		if !ok {
+			cs.VerificationMethods = append(cs.VerificationMethods, VerificationMethodChange{Op: AddOperation, New: method})
+			continue
+		}
+		same, err := sameVerificationMethod(oldMethod, method)
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			cs.VerificationMethods = append(cs.VerificationMethods, VerificationMethodChange{Op: UpdateOperation, Old: oldMethod, New: method})
+		}
+	}
+	for i := range oldDocument.VerificationMethod {
+		method := &oldDocument.VerificationMethod[i]
+		if _, ok := newMethods[method.ID.String()]; !ok {
+			cs.VerificationMethods = append(cs.VerificationMethods, VerificationMethodChange{Op: RemoveOperation, Old: method})
+		}
+	}
+
+	for _, relationType := range []int{Authentication, AssertionMethod, CapabilityDelegation, Recovery, KeyAgreement, CapabilityInvocation} {
+		oldDIDUrls := make(map[string]bool)
+		for _, didUrl := range oldDocument.RelationShip(relationType) {
+			oldDIDUrls[didUrl.String()] = true
+		}
+		newDIDUrls := make(map[string]bool)
+		for _, didUrl := range newDocument.RelationShip(relationType) {
+			newDIDUrls[didUrl.String()] = true
+			if !oldDIDUrls[didUrl.String()] {
+				change := RelationShipChange{Op: AddOperation, RelationType: relationType, ID: didUrl}
+				if relationType == CapabilityDelegation {
+					change.ExpireTime = DefaultDelegationExpireTime
+					change.Expiration = newDocument.CapabilityDelegationExpiration[didUrl.String()]
+				}
+				cs.RelationShips = append(cs.RelationShips, change)
+				continue
+			}
+
+			// delegation is renewed if new document has another expiration
+			if relationType == CapabilityDelegation {
+				expiration := newDocument.CapabilityDelegationExpiration[didUrl.String()]
+				if expiration > 0 && expiration != oldDocument.CapabilityDelegationExpiration[didUrl.String()] {
+					cs.RelationShips = append(cs.RelationShips, RelationShipChange{Op: UpdateOperation, RelationType: relationType, ID: didUrl, Expiration: expiration})
+				}
+			}
+		}
+		for _, didUrl := range oldDocument.RelationShip(relationType) {
+			if !newDIDUrls[didUrl.String()] {
+				cs.RelationShips = append(cs.RelationShips, RelationShipChange{Op: RemoveOperation, RelationType: relationType, ID: didUrl})
+			}
+		}
+	}
+
+	return cs, nil
+}
+
+// sameVerificationMethod compares type, controller and public key, the representation of public key is ignored
+func sameVerificationMethod(a, b *VerificationMethod) (bool, error) {
+	if a.Type != b.Type || !strings.EqualFold(a.Controller.Identifier, b.Controller.Identifier) {
+		return false, nil
+	}
+	if a.BlockchainAccountID != "" || b.BlockchainAccountID != "" {
+		return a.BlockchainAccountID == b.BlockchainAccountID, nil
+	}
+	ka, err := a.PublicKeyBytes()
+	if err != nil {
+		return false, err
+	}
+	kb, err := b.PublicKeyBytes()
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(compressPublicKey(ka), compressPublicKey(kb)), nil
+}
+
+func relationShipName(relationType int) string {
+	switch relationType {
+	case Authentication:
+		return "authentication"
+	case AssertionMethod:
+		return "assertionMethod"
+	case CapabilityDelegation:
+		return "capabilityDelegation"
+	case Recovery:
+		return "recovery"
+	case KeyAgreement:
+		return "keyAgreement"
+	case CapabilityInvocation:
+		return "capabilityInvocation"
+	default:
+		return fmt.Sprintf("relationShip(%d)", relationType)
+	}
+}
+
+// Apply sends the transactions that turn the document of cs.DID into the new version of the change set.
+// New verification methods get the next method index on chain, so their ids must follow the existing methods.
+func (c *MemoDIDController) Apply(cs *ChangeSet) error {
+	operations, err := c.Plan(cs)
+	if err != nil {
+		return err
+	}
+	return runOperations(c, operations)
+}
+
+// Plan returns the controller operations that Apply runs for cs, without sending any transaction
+func (c *MemoDIDController) Plan(cs *ChangeSet) ([]Operation, error) {
+	methodCount, err := c.methodCount(cs.DID)
+	if err != nil {
+		return nil, err
+	}
+	return planChangeSet(cs, methodCount)
+}
+
+// methodCount returns the number of verification methods of did, including deactivated ones
+func (c *MemoDIDController) methodCount(did MemoDID) (int64, error) {
+	did, err := c.onChain(did)
+	if err != nil {
+		return 0, err
+	}
+	client, err := ethclient.DialContext(context.TODO(), c.endpoint)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	accountIns, err := proxy.NewIAccountDid(c.accountAddr, client)
+	if err != nil {
+		return 0, err
+	}
+	size, err := accountIns.GetVeriLen(&bind.CallOpts{}, did.Identifier)
+	if err != nil {
+		return 0, err
+	}
+	return size.Int64(), nil
+}
+
+// Operation is a call of DIDController
+type Operation struct {
+	Description string
+	run         func(controller DIDController) error
+}
+
+func (o Operation) String() string {
+	return o.Description
+}
+
+func runOperations(controller DIDController, operations []Operation) error {
+	for _, operation := range operations {
+		if err := operation.run(controller); err != nil {
+			return xerrors.Errorf("%s: %w", operation.Description, err)
+		}
+	}
+	return nil
+}
+
+// planChangeSet orders the changes as: add controllers, add and update methods, add relationships,
+// remove relationships, remove methods and remove controllers, so that the controller keeps its authority
+// until the last step. Relationship changes which follow from method changes are skipped:
+// keyAgreement consists of x25519 methods and masterKey is always in capabilityInvocation.
+func planChangeSet(cs *ChangeSet, methodCount int64) ([]Operation, error) {
+	did := cs.DID
+	var operations []Operation
+	add := func(run func(controller DIDController) error, format string, args ...interface{}) {
+		operations = append(operations, Operation{Description: fmt.Sprintf(format, args...), run: run})
+	}
+
+	removedMethods := make(map[string]bool)
+	var added []VerificationMethodChange
+	for _, change := range cs.VerificationMethods {
+		switch change.Op {
+		case AddOperation:
+			added = append(added, change)
+		case RemoveOperation:
+			removedMethods[change.Old.ID.String()] = true
+		}
+	}
+	sort.Slice(added, func(i, j int) bool {
+		return added[i].New.ID.GetMethodIndex() < added[j].New.ID.GetMethodIndex()
+	})
+
+	for _, change := range cs.Controllers {
+		if change.Op != AddOperation {
+			continue
+		}
+		controller := change.Controller
+		add(func(c DIDController) error {
+			return c.AddController(did, controller)
+		}, "AddController(%s, %s)", did.String(), controller.String())
+	}
+
+	for _, change := range added {
+		method := change.New
+		if int64(method.ID.GetMethodIndex()) != methodCount {
+			next, err := did.DIDUrl(methodCount)
+			if err != nil {
+				return nil, err
+			}
+			return nil, xerrors.Errorf("%s can't be added, the next method of %s is %s", method.ID.String(), did.String(), next.String())
+		}
+		publicKey, err := method.PublicKeyBytes()
+		if err != nil {
+			return nil, err
+		}
+		publicKeyHex := hexutil.Encode(publicKey)
+		add(func(c DIDController) error {
+			return c.AddVerificationMethod(did, method.Type, method.Controller, publicKeyHex)
+		}, "AddVerificationMethod(%s, %s, %s, %s)", did.String(), method.Type, method.Controller.String(), publicKeyHex)
+		methodCount++
+	}
+	for _, change := range cs.VerificationMethods {
+		if change.Op != UpdateOperation {
+			continue
+		}
+		method := change.New
+		if !strings.EqualFold(change.Old.Controller.Identifier, method.Controller.Identifier) {
+			return nil, xerrors.Errorf("controller of %s can't be changed", method.ID.String())
+		}
+		publicKey, err := method.PublicKeyBytes()
+		if err != nil {
+			return nil, err
+		}
+		publicKeyHex := hexutil.Encode(publicKey)
+		add(func(c DIDController) error {
+			return c.UpdateVerificationMethod(method.ID, method.Type, publicKeyHex)
+		}, "UpdateVerificationMethod(%s, %s, %s)", method.ID.String(), method.Type, publicKeyHex)
+	}
+
+	for _, change := range cs.RelationShips {
+		if change.Op == RemoveOperation || change.RelationType == KeyAgreement {
+			continue
+		}
+		if change.RelationType == CapabilityInvocation {
+			if err := checkInvocationMethod(did, change.ID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		change := change
+		if change.Expiration > 0 {
+			// delegation is added again to renew it
+			add(func(c DIDController) error {
+				if dc, ok := c.(delegationController); ok && change.RelationType == CapabilityDelegation {
+					return dc.addDelegationUntil(did, change.ID, change.Expiration)
+				}
+				return c.AddRelationShip(did, change.RelationType, change.ID, change.expireTime())
+			}, "AddRelationShip(%s, %s, %s, until %s)", did.String(), relationShipName(change.RelationType), change.ID.String(), time.Unix(change.Expiration, 0).UTC().Format(time.RFC3339))
+			continue
+		}
+		add(func(c DIDController) error {
+			return c.AddRelationShip(did, change.RelationType, change.ID, change.ExpireTime)
+		}, "AddRelationShip(%s, %s, %s, %d)", did.String(), relationShipName(change.RelationType), change.ID.String(), change.ExpireTime)
+	}
+	for _, change := range cs.RelationShips {
+		if change.Op != RemoveOperation || removedMethods[change.ID.String()] {
+			continue
+		}
+		if change.RelationType == CapabilityInvocation {
+			return nil, xerrors.Errorf("masterKey can always invoke capabilities, use UpdateVerificationMethod to change it")
+		}
+		change := change
+		add(func(c DIDController) error {
+			return c.DeactivateRelationShip(did, change.RelationType, change.ID)
+		}, "DeactivateRelationShip(%s, %s, %s)", did.String(), relationShipName(change.RelationType), change.ID.String())
+	}
+
+	for _, change := range cs.VerificationMethods {
+		if change.Op != RemoveOperation {
+			continue
+		}
+		id := change.Old.ID
+		add(func(c DIDController) error {
+			return c.DeactivateVerificationMethod(id)
+		}, "DeactivateVerificationMethod(%s)", id.String())
+	}
+
+	for _, change := range cs.Controllers {
+		if change.Op != RemoveOperation {
+			continue
+		}
+		controller := change.Controller
+		add(func(c DIDController) error {
+			return c.DeactivateController(did, controller)
+		}, "DeactivateController(%s, %s)", did.String(), controller.String())
+	}
+
+	return operations, nil
+}
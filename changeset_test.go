@@ -0,0 +1,240 @@
+package memodid
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// recordController records the operations instead of sending transactions
+type recordController struct {
+	ops []string
+}
+
+var _ DIDController = &recordController{}
+
+func (r *recordController) RegisterDID() error {
+	r.ops = append(r.ops, "RegisterDID")
+	return nil
+}
+
+func (r *recordController) AddController(did MemoDID, controller MemoDID) error {
+	r.ops = append(r.ops, "AddController "+controller.String())
+	return nil
+}
+
+func (r *recordController) DeactivateController(did MemoDID, controller MemoDID) error {
+	r.ops = append(r.ops, "DeactivateController "+controller.String())
+	return nil
+}
+
+func (r *recordController) AddVerificationMethod(did MemoDID, vtype string, controller MemoDID, publicKeyHex string) error {
+	r.ops = append(r.ops, "AddVerificationMethod "+vtype)
+	return nil
+}
+
+func (r *recordController) UpdateVerificationMethod(didUrl MemoDIDUrl, vtype string, publicKeyHex string) error {
+	r.ops = append(r.ops, "UpdateVerificationMethod "+didUrl.Fragment)
+	return nil
+}
+
+func (r *recordController) DeactivateVerificationMethod(didUrl MemoDIDUrl) error {
+	r.ops = append(r.ops, "DeactivateVerificationMethod "+didUrl.Fragment)
+	return nil
+}
+
+func (r *recordController) AddRelationShip(did MemoDID, relationType int, didUrl MemoDIDUrl, expireTime int64) error {
+	r.ops = append(r.ops, fmt.Sprintf("AddRelationShip %s %s", relationShipName(relationType), didUrl.Fragment))
+	return nil
+}
+
+func (r *recordController) addDelegationUntil(did MemoDID, didUrl MemoDIDUrl, expiration int64) error {
+	r.ops = append(r.ops, fmt.Sprintf("AddRelationShip capabilityDelegation %s until %d", didUrl.Fragment, expiration))
+	return nil
+}
+
+func (r *recordController) DeactivateRelationShip(did MemoDID, relationType int, didUrl MemoDIDUrl) error {
+	r.ops = append(r.ops, fmt.Sprintf("DeactivateRelationShip %s %s", relationShipName(relationType), didUrl.Fragment))
+	return nil
+}
+
+func (r *recordController) DeactivateDID(did MemoDID) error {
+	r.ops = append(r.ops, "DeactivateDID")
+	return nil
+}
+
+func TestDiff(t *testing.T) {
+	_, pks, err := ToPublicKeys([]string{globalPrivateKey3, globalPrivateKey4, globalPrivateKey5})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	controller1, err := genDocument(globalPrivateKey2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	controller2, err := genDocument(globalPrivateKey3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	old, err := genDocument(globalPrivateKey1, controller1.ID)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	did := old.ID
+	key1, err := genVerificationMethod(&did, 1, nil, EcdsaSecp256k1VerificationKey2019, pks[0])
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	key2, err := genVerificationMethod(&did, 2, nil, EcdsaSecp256k1VerificationKey2019, pks[1])
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	old.VerificationMethod = append(old.VerificationMethod, key1)
+	old.Authentication = []MemoDIDUrl{key1.ID}
+	old.Recovery = []MemoDIDUrl{key1.ID}
+
+	// same content in another key format
+	same, err := old.WithKeyFormat(KeyFormatJwk, nil)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	cs, err := Diff(old, same)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !cs.IsEmpty() {
+		t.Errorf("Unexpect changes:\n%s", cs.String())
+		return
+	}
+
+	new := old.clone()
+	new.Controller = []MemoDID{controller2.ID}
+	new.VerificationMethod[1].PublicKeyHex = pks[2]
+	new.VerificationMethod = append(new.VerificationMethod, key2)
+	new.Authentication = []MemoDIDUrl{key2.ID}
+	new.Recovery = nil
+	cs, err = Diff(old, new)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	t.Log(cs.String())
+
+	expected := []string{
+		"add controller " + controller2.ID.String(),
+		"remove controller " + controller1.ID.String(),
+		"update verificationMethod " + key1.ID.String() + "(" + EcdsaSecp256k1VerificationKey2019 + ")",
+		"add verificationMethod " + key2.ID.String() + "(" + EcdsaSecp256k1VerificationKey2019 + ")",
+		"add authentication " + key2.ID.String(),
+		"remove authentication " + key1.ID.String(),
+		"remove recovery " + key1.ID.String(),
+	}
+	if cs.String() != strings.Join(expected, "\n") {
+		t.Errorf("Unexpect changes:\n%s", cs.String())
+		return
+	}
+
+	other, err := genDocument(globalPrivateKey2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	_, err = Diff(old, other)
+	if err == nil {
+		t.Error("Documents of different dids can't be compared")
+	}
+}
+
+func TestApplyChangeSet(t *testing.T) {
+	_, pks, err := ToPublicKeys([]string{globalPrivateKey3, globalPrivateKey4})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	controller1, err := genDocument(globalPrivateKey2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	old, err := genDocument(globalPrivateKey1, controller1.ID)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	did := old.ID
+	key1, err := genVerificationMethod(&did, 1, nil, EcdsaSecp256k1VerificationKey2019, pks[0])
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	key2, err := genVerificationMethod(&did, 2, nil, EcdsaSecp256k1VerificationKey2019, pks[1])
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	old.VerificationMethod = append(old.VerificationMethod, key1)
+	old.Authentication = []MemoDIDUrl{key1.ID}
+
+	new := old.clone()
+	new.Controller = nil
+	new.VerificationMethod = []VerificationMethod{old.VerificationMethod[0], key2}
+	new.Authentication = []MemoDIDUrl{key2.ID}
+	new.CapabilityDelegation = []MemoDIDUrl{key2.ID}
+	new.CapabilityDelegationExpiration = map[string]int64{key2.ID.String(): 1700000000}
+	cs, err := Diff(old, new)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	operations, err := planChangeSet(cs, 2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	r := &recordController{}
+	err = runOperations(r, operations)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	// authentication of deactivated key-1 is removed with the method
+	expected := []string{
+		"AddVerificationMethod " + EcdsaSecp256k1VerificationKey2019,
+		"AddRelationShip authentication key-2",
+		"AddRelationShip capabilityDelegation key-2 until 1700000000",
+		"DeactivateVerificationMethod key-1",
+		"DeactivateController " + controller1.ID.String(),
+	}
+	if strings.Join(r.ops, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpect operations:\n%s", strings.Join(r.ops, "\n"))
+		return
+	}
+
+	// key-2 can't be added if there are 3 methods on chain
+	_, err = planChangeSet(cs, 3)
+	if err == nil {
+		t.Error("Method index should be checked")
+		return
+	}
+
+	// only masterKey can invoke capabilities
+	new.CapabilityInvocation = []MemoDIDUrl{old.VerificationMethod[0].ID, key2.ID}
+	cs, err = Diff(old, new)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	_, err = planChangeSet(cs, 2)
+	if err == nil || !strings.Contains(err.Error(), "only masterKey can invoke capabilities") {
+		t.Errorf("capabilityInvocation of key-2 should be rejected: %v", err)
+	}
+}
@@ -1,377 +1,707 @@
-package memodid
-
-import (
-	"context"
-	"crypto/ecdsa"
-	"encoding/binary"
-	"encoding/hex"
-	"math/big"
-	"time"
-
-	// "memo"
-
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
-	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
-	"golang.org/x/xerrors"
-
-	com "github.com/memoio/contractsv2/common"
-	inst "github.com/memoio/contractsv2/go_contracts/instance"
-	"github.com/memoio/did-solidity/go-contracts/proxy"
-)
-
-var (
-	checkTxSleepTime = 6 // wait 6s(blocktime + 1s)
-	nextBlockTime    = 5 // blocktime
-)
-
-type MemoDIDController struct {
-	did           *MemoDID
-	endpoint      string
-	privateKey    *ecdsa.PrivateKey
-	didTransactor *bind.TransactOpts
-	proxyAddr     common.Address
-}
-
-var _ DIDController = &MemoDIDController{}
-
-func NewMemoDIDController(privateKey *ecdsa.PrivateKey, chain string) (*MemoDIDController, error) {
-	did, err := CreatMemoDID(privateKey, chain)
-	if err != nil {
-		return nil, err
-	}
-	controller, err := NewMemoDIDControllerWithDID(privateKey, chain, did.String())
-	return controller, err
-}
-
-func NewMemoDIDControllerWithDID(privateKey *ecdsa.PrivateKey, chain, didString string) (*MemoDIDController, error) {
-	instanceAddr, endpoint := com.GetInsEndPointByChain(chain)
-
-	client, err := ethclient.DialContext(context.TODO(), endpoint)
-	if err != nil {
-		return nil, err
-	}
-
-	chainID, err := client.NetworkID(context.Background())
-	if err != nil {
-		chainID = big.NewInt(985)
-	}
-
-	// new instanceIns
-	instanceIns, err := inst.NewInstance(instanceAddr, client)
-	if err != nil {
-		return nil, err
-	}
-
-	// get proxyAddr
-	proxyAddr, err := instanceIns.Instances(&bind.CallOpts{}, com.TypeDidProxy)
-	if err != nil {
-		return nil, err
-	}
-
-	// new auth
-	auth, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
-	if err != nil {
-		return nil, err
-	}
-	auth.Value = big.NewInt(0)     // in wei
-	auth.GasLimit = uint64(300000) // in units
-	// auth.GasPrice = big.NewInt(1000)
-
-	did, err := ParseMemoDID(didString)
-	return &MemoDIDController{
-		did:           did,
-		endpoint:      endpoint,
-		privateKey:    privateKey,
-		didTransactor: auth,
-		proxyAddr:     proxyAddr,
-	}, err
-}
-
-// Create unregistered DID
-func CreatMemoDID(privateKey *ecdsa.PrivateKey, chain string) (*MemoDID, error) {
-	_, endpoint := com.GetInsEndPointByChain(chain)
-	client, err := ethclient.DialContext(context.TODO(), endpoint)
-	if err != nil {
-		return nil, err
-	}
-	defer client.Close()
-
-	publicKey := privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return nil, xerrors.Errorf("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
-	}
-	address := crypto.PubkeyToAddress(*publicKeyECDSA)
-	nonce, err := client.PendingNonceAt(context.TODO(), address)
-	if err != nil {
-		return nil, err
-	}
-
-	identifier := hex.EncodeToString(crypto.Keccak256(binary.AppendUvarint(address.Bytes(), nonce)))
-
-	return &MemoDID{
-		Method:      "memo",
-		Identifier:  identifier,
-		Identifiers: []string{identifier},
-	}, nil
-}
-
-func (c *MemoDIDController) DID() *MemoDID {
-	return c.did
-}
-
-func (c *MemoDIDController) RegisterDID() error {
-	client, err := ethclient.DialContext(context.TODO(), c.endpoint)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
-	proxyIns, err := proxy.NewProxy(c.proxyAddr, client)
-	if err != nil {
-		return err
-	}
-
-	// Get public key from private key
-	publicKey := c.privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return xerrors.Errorf("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
-	}
-	publicKeyBytes := crypto.CompressPubkey(publicKeyECDSA)
-
-	tx, err := proxyIns.CreateDID(c.didTransactor, c.did.Identifier, "EcdsaSecp256k1VerificationKey2019", publicKeyBytes)
-	if err != nil {
-		return err
-	}
-
-	return CheckTx(c.endpoint, tx.Hash(), "RegisterDID")
-}
-
-// AddController will authorize the 'controller' to fully control of 'did'
-// AddController will add a controller in did's document
-func (c *MemoDIDController) AddController(did MemoDID, controller MemoDID) error {
-	client, err := ethclient.DialContext(context.TODO(), c.endpoint)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
-	proxyIns, err := proxy.NewProxy(c.proxyAddr, client)
-	if err != nil {
-		return err
-	}
-
-	tx, err := proxyIns.AddController(c.didTransactor, did.Identifier, c.did.Identifier, controller.Identifier)
-	if err != nil {
-		return err
-	}
-
-	return CheckTx(c.endpoint, tx.Hash(), "AddController")
-}
-
-func (c *MemoDIDController) DeactivateController(did MemoDID, controller MemoDID) error {
-	client, err := ethclient.DialContext(context.TODO(), c.endpoint)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
-	proxyIns, err := proxy.NewProxy(c.proxyAddr, client)
-	if err != nil {
-		return err
-	}
-
-	tx, err := proxyIns.RemoveController(c.didTransactor, did.Identifier, c.did.Identifier, controller.Identifier)
-	if err != nil {
-		return err
-	}
-
-	return CheckTx(c.endpoint, tx.Hash(), "RemoveController")
-}
-
-func (c *MemoDIDController) AddVerificationMethod(did MemoDID, vtype string, controller MemoDID, publicKeyHex string) error {
-	publicKeyBytes, err := hex.DecodeString(publicKeyHex)
-	if err != nil {
-		return err
-	}
-
-	publicKey := proxy.IAccountDidPublicKey{
-		MethodType:  vtype,
-		Controller:  controller.Identifier,
-		PubKeyData:  publicKeyBytes,
-		Deactivated: false,
-	}
-
-	client, err := ethclient.DialContext(context.TODO(), c.endpoint)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
-	proxyIns, err := proxy.NewProxy(c.proxyAddr, client)
-	if err != nil {
-		return err
-	}
-
-	tx, err := proxyIns.AddVeri(c.didTransactor, did.Identifier, c.did.Identifier, publicKey)
-	if err != nil {
-		return err
-	}
-
-	return CheckTx(c.endpoint, tx.Hash(), "AddVerificationMethod")
-}
-
-func (c *MemoDIDController) UpdateVerificationMethod(didUrl MemoDIDUrl, vtype string, publicKeyHex string) error {
-	client, err := ethclient.DialContext(context.TODO(), c.endpoint)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
-	proxyIns, err := proxy.NewProxy(c.proxyAddr, client)
-	if err != nil {
-		return err
-	}
-
-	tx, err := proxyIns.UpdateVeri(c.didTransactor, didUrl.Identifier, big.NewInt(int64(didUrl.GetMethodIndex())), vtype, []byte(publicKeyHex))
-	if err != nil {
-		return err
-	}
-	return CheckTx(c.endpoint, tx.Hash(), "UpdateVerificationMethod")
-}
-
-func (c *MemoDIDController) DeactivateVerificationMethod(didUrl MemoDIDUrl) error {
-	client, err := ethclient.DialContext(context.TODO(), c.endpoint)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
-	proxyIns, err := proxy.NewProxy(c.proxyAddr, client)
-	if err != nil {
-		return err
-	}
-
-	tx, err := proxyIns.DeactivateVeri(c.didTransactor, didUrl.Identifier, c.did.Identifier, big.NewInt(int64(didUrl.GetMethodIndex())), true)
-	if err != nil {
-		return err
-	}
-
-	return CheckTx(c.endpoint, tx.Hash(), "DeactivateVerificationMethod")
-}
-
-func (c *MemoDIDController) AddRelationShip(did MemoDID, relationType int, didUrl MemoDIDUrl, expireTime int64) error {
-	client, err := ethclient.DialContext(context.TODO(), c.endpoint)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
-	proxyIns, err := proxy.NewProxy(c.proxyAddr, client)
-	if err != nil {
-		return err
-	}
-
-	var tx *types.Transaction
-	switch relationType {
-	case Authentication:
-		tx, err = proxyIns.AddAuth(c.didTransactor, did.Identifier, c.did.Identifier, didUrl.String())
-	case AssertionMethod:
-		tx, err = proxyIns.AddAssertion(c.didTransactor, did.Identifier, c.did.Identifier, didUrl.String())
-	case CapabilityDelegation:
-		tx, err = proxyIns.AddDelegation(c.didTransactor, did.Identifier, c.did.Identifier, didUrl.String(), big.NewInt(expireTime+time.Now().Unix()))
-	case Recovery:
-		tx, err = proxyIns.AddRecovery(c.didTransactor, did.Identifier, c.did.Identifier, didUrl.String())
-	default:
-		return xerrors.Errorf("unsupported relation ships")
-	}
-	if err != nil {
-		return err
-	}
-
-	return CheckTx(c.endpoint, tx.Hash(), "AddRelationShip")
-}
-
-func (c *MemoDIDController) DeactivateRelationShip(did MemoDID, relationType int, didUrl MemoDIDUrl) error {
-	client, err := ethclient.DialContext(context.TODO(), c.endpoint)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
-	proxyIns, err := proxy.NewProxy(c.proxyAddr, client)
-	if err != nil {
-		return err
-	}
-
-	var tx *types.Transaction
-	switch relationType {
-	case Authentication:
-		tx, err = proxyIns.RemoveAuth(c.didTransactor, did.Identifier, c.did.Identifier, didUrl.String())
-	case AssertionMethod:
-		tx, err = proxyIns.RemoveAssertion(c.didTransactor, did.Identifier, c.did.Identifier, didUrl.String())
-	case CapabilityDelegation:
-		tx, err = proxyIns.RemoveDelegation(c.didTransactor, did.Identifier, c.did.Identifier, didUrl.String())
-	case Recovery:
-		tx, err = proxyIns.RemoveRecovery(c.didTransactor, did.Identifier, c.did.Identifier, didUrl.String())
-	default:
-		return xerrors.Errorf("unsupported relation ships")
-	}
-	if err != nil {
-		return err
-	}
-
-	return CheckTx(c.endpoint, tx.Hash(), "DeactivateRelationShip")
-}
-
-func (c *MemoDIDController) DeactivateDID(did MemoDID) error {
-	client, err := ethclient.DialContext(context.TODO(), c.endpoint)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
-	proxyIns, err := proxy.NewProxy(c.proxyAddr, client)
-	if err != nil {
-		return err
-	}
-
-	tx, err := proxyIns.DeactivateDID(c.didTransactor, did.Identifier, c.did.Identifier, true)
-	if err != nil {
-		return err
-	}
-
-	return CheckTx(c.endpoint, tx.Hash(), "DeactivateDID")
-}
-
-// CheckTx check whether transaction is successful through receipt
-func CheckTx(endPoint string, txHash common.Hash, name string) error {
-	var receipt *types.Receipt
-
-	t := checkTxSleepTime
-	for i := 0; i < 10; i++ {
-		time.Sleep(time.Duration(t) * time.Second)
-		receipt = com.GetTransactionReceipt(endPoint, txHash)
-		if receipt != nil {
-			break
-		}
-		t = nextBlockTime
-	}
-
-	if receipt == nil {
-		return xerrors.Errorf("%s: cann't get transaction(%s) receipt, not packaged", name, txHash)
-	}
-
-	// 0 means fail
-	if receipt.Status == 0 {
-		if receipt.GasUsed != receipt.CumulativeGasUsed {
-			return xerrors.Errorf("%s: transaction(%s) exceed gas limit", name, txHash)
-		}
-		return xerrors.Errorf("%s: transaction(%s) mined but execution failed, please check your tx input", name, txHash)
-	}
-	return nil
-}
+package memodid
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	// "memo"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"golang.org/x/xerrors"
+
+	com "github.com/memoio/contractsv2/common"
+	"github.com/memoio/did-solidity/go-contracts/proxy"
+)
+
+// MemoDIDController is safe for concurrent use, nonces of its transactions are assigned locally
+type MemoDIDController struct {
+	did           *MemoDID
+	endpoint      string
+	chainID       *big.Int
+	privateKey    *ecdsa.PrivateKey
+	didTransactor *bind.TransactOpts
+	proxyAddr     common.Address
+	accountAddr   common.Address
+	waiter        *TxWaiter
+	gas           *GasConfig
+	pending       PendingStore
+	recoveries    RecoveryStore
+	nonces        nonceManager
+
+	policyLock sync.RWMutex
+	policies   map[string]*MultisigPolicy
+	// clients of the transactions run by ExecuteProposal -> identifier of the did they can change
+	approved map[*ethclient.Client]string
+
+	// pending operations being tracked, keyed by id
+	trackLock sync.Mutex
+	tracking  map[string]*PendingOperation
+}
+
+var _ DIDController = &MemoDIDController{}
+
+func NewMemoDIDController(privateKey *ecdsa.PrivateKey, chain string) (*MemoDIDController, error) {
+	did, err := CreatMemoDID(privateKey, chain)
+	if err != nil {
+		return nil, err
+	}
+	controller, err := NewMemoDIDControllerWithDID(privateKey, chain, did.String())
+	return controller, err
+}
+
+func NewMemoDIDControllerWithDID(privateKey *ecdsa.PrivateKey, chain, didString string) (*MemoDIDController, error) {
+	return NewMemoDIDControllerWithConfig(privateKey, DefaultChainConfig(chain), didString)
+}
+
+// NewMemoDIDControllerWithConfig makes a controller of didString on the chain described by config,
+// a new unregistered did is created if didString is empty
+func NewMemoDIDControllerWithConfig(privateKey *ecdsa.PrivateKey, config *ChainConfig, didString string) (*MemoDIDController, error) {
+	client, err := ethclient.DialContext(context.TODO(), config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	contracts, err := config.contracts(client)
+	if err != nil {
+		return nil, err
+	}
+
+	// new auth
+	auth, err := bind.NewKeyedTransactorWithChainID(privateKey, contracts.chainID)
+	if err != nil {
+		return nil, err
+	}
+	auth.Value = big.NewInt(0) // in wei
+	// gas limit and fee are set by GasConfig for each transaction
+
+	var did *MemoDID
+	if didString == "" {
+		did, err = createMemoDID(client, privateKey)
+	} else {
+		did, err = ParseMemoDID(didString)
+		if err == nil && did.ChainID != "" && did.ChainID != contracts.chainID.String() {
+			return nil, xerrors.Errorf("did of chain %s can't be controlled on chain %s", did.ChainID, contracts.chainID)
+		}
+	}
+	return &MemoDIDController{
+		did:           did,
+		endpoint:      config.Endpoint,
+		chainID:       contracts.chainID,
+		privateKey:    privateKey,
+		didTransactor: auth,
+		proxyAddr:     contracts.proxyAddr,
+		accountAddr:   contracts.accountAddr,
+		waiter:        config.txWaiter(),
+		gas:           DefaultGasConfig(),
+		recoveries:    newMemRecoveryStore(),
+	}, err
+}
+
+// Create unregistered DID
+func CreatMemoDID(privateKey *ecdsa.PrivateKey, chain string) (*MemoDID, error) {
+	_, endpoint := com.GetInsEndPointByChain(chain)
+	client, err := ethclient.DialContext(context.TODO(), endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	return createMemoDID(client, privateKey)
+}
+
+func createMemoDID(client *ethclient.Client, privateKey *ecdsa.PrivateKey) (*MemoDID, error) {
+	publicKey := privateKey.Public()
+	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, xerrors.Errorf("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
+	}
+	address := crypto.PubkeyToAddress(*publicKeyECDSA)
+	nonce, err := client.PendingNonceAt(context.TODO(), address)
+	if err != nil {
+		return nil, err
+	}
+
+	identifier := memoIdentifier(address, nonce)
+
+	return &MemoDID{
+		Method:      "memo",
+		Identifier:  identifier,
+		Identifiers: []string{identifier},
+	}, nil
+}
+
+func (c *MemoDIDController) DID() *MemoDID {
+	return c.did
+}
+
+// SetTxWaiter sets how the controller waits for its transactions
+func (c *MemoDIDController) SetTxWaiter(waiter *TxWaiter) {
+	c.waiter = waiter
+}
+
+// resolver resolves documents on the chain of the controller
+func (c *MemoDIDController) resolver() *MemoDIDResolver {
+	return &MemoDIDResolver{
+		endpoint:    c.endpoint,
+		chainID:     c.chainID,
+		accountAddr: c.accountAddr,
+	}
+}
+
+// onChain checks did is on the chain of the controller, and returns it without chain segment
+// as dids are recorded in contract
+func (c *MemoDIDController) onChain(did MemoDID) (MemoDID, error) {
+	if err := c.checkChain(did.ChainID); err != nil {
+		return MemoDID{}, err
+	}
+	return did.withoutChain(), nil
+}
+
+// onChainUrl is onChain of DID URL
+func (c *MemoDIDController) onChainUrl(didUrl MemoDIDUrl) (MemoDIDUrl, error) {
+	if err := c.checkChain(didUrl.ChainID); err != nil {
+		return MemoDIDUrl{}, err
+	}
+	return didUrl.withoutChain(), nil
+}
+
+func (c *MemoDIDController) checkChain(chainID string) error {
+	if chainID != "" && chainID != c.chainID.String() {
+		return xerrors.Errorf("did of chain %s can't be changed on chain %s", chainID, c.chainID)
+	}
+	return nil
+}
+
+// RegisterDID registers did with the secp256k1 public key of controller's private key as masterKey
+func (c *MemoDIDController) RegisterDID() error {
+	// Get public key from private key
+	publicKey := c.privateKey.Public()
+	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return xerrors.Errorf("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
+	}
+	publicKeyBytes := crypto.CompressPubkey(publicKeyECDSA)
+
+	return c.RegisterDIDWithMasterKey(EcdsaSecp256k1VerificationKey2019, publicKeyBytes)
+}
+
+// RegisterDIDWithMasterKey registers did with the given masterKey, such as an ed25519 key of a mobile wallet
+func (c *MemoDIDController) RegisterDIDWithMasterKey(vtype string, publicKey []byte) error {
+	_, err := c.RegisterDIDWithResult(vtype, publicKey)
+	return err
+}
+
+// RegisterDIDWithResult is RegisterDIDWithMasterKey with the transaction result
+func (c *MemoDIDController) RegisterDIDWithResult(vtype string, publicKey []byte) (*TxResult, error) {
+	return c.transact(func(client *ethclient.Client) (*txRequest, error) {
+		return c.registerDID(client, vtype, publicKey)
+	})
+}
+
+func (c *MemoDIDController) registerDID(client *ethclient.Client, vtype string, publicKey []byte) (*txRequest, error) {
+	if err := ValidatePublicKey(vtype, publicKey); err != nil {
+		return nil, err
+	}
+	if isX25519Type(vtype) {
+		return nil, xerrors.Errorf("%s can't be used as masterKey", vtype)
+	}
+
+	return &txRequest{
+		name: "RegisterDID",
+		send: func(proxyIns *proxy.Proxy, opts *bind.TransactOpts) (*types.Transaction, error) {
+			return proxyIns.CreateDID(opts, c.did.Identifier, vtype, publicKey)
+		},
+	}, nil
+}
+
+// AddController will authorize the 'controller' to fully control of 'did'
+// AddController will add a controller in did's document
+func (c *MemoDIDController) AddController(did MemoDID, controller MemoDID) error {
+	_, err := c.AddControllerWithResult(did, controller)
+	return err
+}
+
+// AddControllerWithResult is AddController with the transaction result
+func (c *MemoDIDController) AddControllerWithResult(did MemoDID, controller MemoDID) (*TxResult, error) {
+	return c.transact(func(client *ethclient.Client) (*txRequest, error) {
+		return c.addController(client, did, controller)
+	})
+}
+
+func (c *MemoDIDController) addController(client *ethclient.Client, did MemoDID, controller MemoDID) (*txRequest, error) {
+	vd := &validator{}
+	vd.validateDID("did", did)
+	vd.validateDID("controller", controller)
+	if err := vd.err(); err != nil {
+		return nil, err
+	}
+	did, err := c.onChain(did)
+	if err != nil {
+		return nil, err
+	}
+	controller, err = c.onChain(controller)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.checkControl(client, did); err != nil {
+		return nil, err
+	}
+
+	return &txRequest{
+		name: "AddController",
+		send: func(proxyIns *proxy.Proxy, opts *bind.TransactOpts) (*types.Transaction, error) {
+			return proxyIns.AddController(opts, did.Identifier, c.did.Identifier, controller.Identifier)
+		},
+	}, nil
+}
+
+func (c *MemoDIDController) DeactivateController(did MemoDID, controller MemoDID) error {
+	_, err := c.DeactivateControllerWithResult(did, controller)
+	return err
+}
+
+// DeactivateControllerWithResult is DeactivateController with the transaction result
+func (c *MemoDIDController) DeactivateControllerWithResult(did MemoDID, controller MemoDID) (*TxResult, error) {
+	return c.transact(func(client *ethclient.Client) (*txRequest, error) {
+		return c.deactivateController(client, did, controller)
+	})
+}
+
+func (c *MemoDIDController) deactivateController(client *ethclient.Client, did MemoDID, controller MemoDID) (*txRequest, error) {
+	did, err := c.onChain(did)
+	if err != nil {
+		return nil, err
+	}
+	controller, err = c.onChain(controller)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkControl(client, did); err != nil {
+		return nil, err
+	}
+
+	return &txRequest{
+		name: "RemoveController",
+		send: func(proxyIns *proxy.Proxy, opts *bind.TransactOpts) (*types.Transaction, error) {
+			return proxyIns.RemoveController(opts, did.Identifier, c.did.Identifier, controller.Identifier)
+		},
+	}, nil
+}
+
+// AddVerificationMethod adds a verification method to did's document,
+// public key can be encoded as hex, multibase or JWK
+func (c *MemoDIDController) AddVerificationMethod(did MemoDID, vtype string, controller MemoDID, publicKeyHex string) error {
+	_, err := c.AddVerificationMethodWithResult(did, vtype, controller, publicKeyHex)
+	return err
+}
+
+// AddVerificationMethodWithResult is AddVerificationMethod with the transaction result
+func (c *MemoDIDController) AddVerificationMethodWithResult(did MemoDID, vtype string, controller MemoDID, publicKeyHex string) (*TxResult, error) {
+	return c.transact(func(client *ethclient.Client) (*txRequest, error) {
+		return c.addVerificationMethod(client, did, vtype, controller, publicKeyHex)
+	})
+}
+
+func (c *MemoDIDController) addVerificationMethod(client *ethclient.Client, did MemoDID, vtype string, controller MemoDID, publicKeyHex string) (*txRequest, error) {
+	publicKeyBytes, err := DecodePublicKey(publicKeyHex)
+	if err != nil {
+		return nil, err
+	}
+	vd := &validator{}
+	vd.validateDID("did", did)
+	vd.validateDID("controller", controller)
+	if err := ValidatePublicKey(vtype, publicKeyBytes); err != nil {
+		vd.add("publicKey", "%s", err.Error())
+	}
+	if err := vd.err(); err != nil {
+		return nil, err
+	}
+	did, err = c.onChain(did)
+	if err != nil {
+		return nil, err
+	}
+	controller, err = c.onChain(controller)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey := proxy.IAccountDidPublicKey{
+		MethodType:  vtype,
+		Controller:  controller.Identifier,
+		PubKeyData:  publicKeyBytes,
+		Deactivated: false,
+	}
+
+	if err := c.checkControl(client, did); err != nil {
+		return nil, err
+	}
+
+	return &txRequest{
+		name: "AddVerificationMethod",
+		send: func(proxyIns *proxy.Proxy, opts *bind.TransactOpts) (*types.Transaction, error) {
+			return proxyIns.AddVeri(opts, did.Identifier, c.did.Identifier, publicKey)
+		},
+		complete: func(client *ethclient.Client, result *TxResult) {
+			result.DIDUrl = c.addedMethod(client, did, publicKey, result)
+		},
+	}, nil
+}
+
+func (c *MemoDIDController) UpdateVerificationMethod(didUrl MemoDIDUrl, vtype string, publicKeyHex string) error {
+	_, err := c.UpdateVerificationMethodWithResult(didUrl, vtype, publicKeyHex)
+	return err
+}
+
+// UpdateVerificationMethodWithResult is UpdateVerificationMethod with the transaction result
+func (c *MemoDIDController) UpdateVerificationMethodWithResult(didUrl MemoDIDUrl, vtype string, publicKeyHex string) (*TxResult, error) {
+	return c.transact(func(client *ethclient.Client) (*txRequest, error) {
+		return c.updateVerificationMethod(client, didUrl, vtype, publicKeyHex)
+	})
+}
+
+func (c *MemoDIDController) updateVerificationMethod(client *ethclient.Client, didUrl MemoDIDUrl, vtype string, publicKeyHex string) (*txRequest, error) {
+	publicKeyBytes, err := DecodePublicKey(publicKeyHex)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidatePublicKey(vtype, publicKeyBytes); err != nil {
+		return nil, err
+	}
+	didUrl, err = c.onChainUrl(didUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.checkControl(client, didUrl.DID()); err != nil {
+		return nil, err
+	}
+
+	return &txRequest{
+		name: "UpdateVerificationMethod",
+		send: func(proxyIns *proxy.Proxy, opts *bind.TransactOpts) (*types.Transaction, error) {
+			return proxyIns.UpdateVeri(opts, didUrl.Identifier, big.NewInt(int64(didUrl.GetMethodIndex())), vtype, publicKeyBytes)
+		},
+	}, nil
+}
+
+func (c *MemoDIDController) DeactivateVerificationMethod(didUrl MemoDIDUrl) error {
+	_, err := c.DeactivateVerificationMethodWithResult(didUrl)
+	return err
+}
+
+// DeactivateVerificationMethodWithResult is DeactivateVerificationMethod with the transaction result
+func (c *MemoDIDController) DeactivateVerificationMethodWithResult(didUrl MemoDIDUrl) (*TxResult, error) {
+	return c.transact(func(client *ethclient.Client) (*txRequest, error) {
+		return c.deactivateVerificationMethod(client, didUrl)
+	})
+}
+
+func (c *MemoDIDController) deactivateVerificationMethod(client *ethclient.Client, didUrl MemoDIDUrl) (*txRequest, error) {
+	didUrl, err := c.onChainUrl(didUrl)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkControl(client, didUrl.DID()); err != nil {
+		return nil, err
+	}
+
+	return &txRequest{
+		name: "DeactivateVerificationMethod",
+		send: func(proxyIns *proxy.Proxy, opts *bind.TransactOpts) (*types.Transaction, error) {
+			return proxyIns.DeactivateVeri(opts, didUrl.Identifier, c.did.Identifier, big.NewInt(int64(didUrl.GetMethodIndex())), true)
+		},
+	}, nil
+}
+
+// AddRelationShip adds didUrl to relationType of did. For capabilityDelegation, expireTime is the
+// lifetime in seconds from now, AddDelegation and RenewDelegation take absolute expiration.
+func (c *MemoDIDController) AddRelationShip(did MemoDID, relationType int, didUrl MemoDIDUrl, expireTime int64) error {
+	_, err := c.AddRelationShipWithResult(did, relationType, didUrl, expireTime)
+	return err
+}
+
+// AddRelationShipWithResult is AddRelationShip with the transaction result,
+// the result is empty if no transaction is sent, such as keyAgreement and capabilityInvocation of masterKey
+func (c *MemoDIDController) AddRelationShipWithResult(did MemoDID, relationType int, didUrl MemoDIDUrl, expireTime int64) (*TxResult, error) {
+	return c.transact(func(client *ethclient.Client) (*txRequest, error) {
+		return c.addRelationShip(client, did, relationType, didUrl, expireTime)
+	})
+}
+
+func (c *MemoDIDController) addRelationShip(client *ethclient.Client, did MemoDID, relationType int, didUrl MemoDIDUrl, expireTime int64) (*txRequest, error) {
+	return c.addRelationShipUntil(client, did, relationType, didUrl, expireTime+time.Now().Unix())
+}
+
+// addRelationShipUntil is addRelationShip with the expiration(unix time) of capabilityDelegation
+func (c *MemoDIDController) addRelationShipUntil(client *ethclient.Client, did MemoDID, relationType int, didUrl MemoDIDUrl, expiration int64) (*txRequest, error) {
+	vd := &validator{}
+	vd.validateDID("did", did)
+	vd.validateDID("didUrl", didUrl.DID())
+	if didUrl.GetMethodIndex() < 0 {
+		vd.add("didUrl", "unsupported fragment %s", didUrl.Fragment)
+	}
+	if err := vd.err(); err != nil {
+		return nil, err
+	}
+	did, err := c.onChain(did)
+	if err != nil {
+		return nil, err
+	}
+	didUrl, err = c.onChainUrl(didUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.checkControl(client, did); err != nil {
+		return nil, err
+	}
+
+	switch relationType {
+	case Authentication, AssertionMethod, CapabilityDelegation, Recovery:
+		if err := c.checkRelationShipMethod(client, didUrl); err != nil {
+			return nil, err
+		}
+	}
+
+	expirationTime := big.NewInt(expiration)
+	var send txSender
+	switch relationType {
+	case Authentication:
+		send = func(proxyIns *proxy.Proxy, opts *bind.TransactOpts) (*types.Transaction, error) {
+			return proxyIns.AddAuth(opts, did.Identifier, c.did.Identifier, didUrl.String())
+		}
+	case AssertionMethod:
+		send = func(proxyIns *proxy.Proxy, opts *bind.TransactOpts) (*types.Transaction, error) {
+			return proxyIns.AddAssertion(opts, did.Identifier, c.did.Identifier, didUrl.String())
+		}
+	case CapabilityDelegation:
+		send = func(proxyIns *proxy.Proxy, opts *bind.TransactOpts) (*types.Transaction, error) {
+			return proxyIns.AddDelegation(opts, did.Identifier, c.did.Identifier, didUrl.String(), expirationTime)
+		}
+	case Recovery:
+		send = func(proxyIns *proxy.Proxy, opts *bind.TransactOpts) (*types.Transaction, error) {
+			return proxyIns.AddRecovery(opts, did.Identifier, c.did.Identifier, didUrl.String())
+		}
+	case KeyAgreement:
+		// keyAgreement consists of all activated x25519 methods, no need to send transaction
+		return nil, c.checkKeyAgreementMethod(client, did, didUrl)
+	case CapabilityInvocation:
+		// masterKey is always in capabilityInvocation, no need to send transaction
+		if err := checkInvocationMethod(did, didUrl); err != nil {
+			return nil, err
+		}
+		return nil, c.checkRelationShipMethod(client, didUrl)
+	default:
+		return nil, xerrors.Errorf("unsupported relation ships")
+	}
+
+	return &txRequest{name: "AddRelationShip", send: send}, nil
+}
+
+func (c *MemoDIDController) DeactivateRelationShip(did MemoDID, relationType int, didUrl MemoDIDUrl) error {
+	_, err := c.DeactivateRelationShipWithResult(did, relationType, didUrl)
+	return err
+}
+
+// DeactivateRelationShipWithResult is DeactivateRelationShip with the transaction result
+func (c *MemoDIDController) DeactivateRelationShipWithResult(did MemoDID, relationType int, didUrl MemoDIDUrl) (*TxResult, error) {
+	return c.transact(func(client *ethclient.Client) (*txRequest, error) {
+		return c.deactivateRelationShip(client, did, relationType, didUrl)
+	})
+}
+
+func (c *MemoDIDController) deactivateRelationShip(client *ethclient.Client, did MemoDID, relationType int, didUrl MemoDIDUrl) (*txRequest, error) {
+	did, err := c.onChain(did)
+	if err != nil {
+		return nil, err
+	}
+	didUrl, err = c.onChainUrl(didUrl)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkControl(client, did); err != nil {
+		return nil, err
+	}
+
+	var send txSender
+	switch relationType {
+	case Authentication:
+		send = func(proxyIns *proxy.Proxy, opts *bind.TransactOpts) (*types.Transaction, error) {
+			return proxyIns.RemoveAuth(opts, did.Identifier, c.did.Identifier, didUrl.String())
+		}
+	case AssertionMethod:
+		send = func(proxyIns *proxy.Proxy, opts *bind.TransactOpts) (*types.Transaction, error) {
+			return proxyIns.RemoveAssertion(opts, did.Identifier, c.did.Identifier, didUrl.String())
+		}
+	case CapabilityDelegation:
+		send = func(proxyIns *proxy.Proxy, opts *bind.TransactOpts) (*types.Transaction, error) {
+			return proxyIns.RemoveDelegation(opts, did.Identifier, c.did.Identifier, didUrl.String())
+		}
+	case Recovery:
+		send = func(proxyIns *proxy.Proxy, opts *bind.TransactOpts) (*types.Transaction, error) {
+			return proxyIns.RemoveRecovery(opts, did.Identifier, c.did.Identifier, didUrl.String())
+		}
+	case KeyAgreement:
+		// x25519 method can only be used for keyAgreement, so deactivate the method
+		err := c.checkKeyAgreementMethod(client, did, didUrl)
+		if err != nil {
+			return nil, err
+		}
+		send = func(proxyIns *proxy.Proxy, opts *bind.TransactOpts) (*types.Transaction, error) {
+			return proxyIns.DeactivateVeri(opts, didUrl.Identifier, c.did.Identifier, big.NewInt(int64(didUrl.GetMethodIndex())), true)
+		}
+	case CapabilityInvocation:
+		if err := checkInvocationMethod(did, didUrl); err != nil {
+			return nil, err
+		}
+		return nil, xerrors.Errorf("masterKey can always invoke capabilities, use UpdateVerificationMethod to change it")
+	default:
+		return nil, xerrors.Errorf("unsupported relation ships")
+	}
+
+	return &txRequest{name: "DeactivateRelationShip", send: send}, nil
+}
+
+// checkKeyAgreementMethod checks didUrl is an activated x25519 method of did.
+// AccountDid contract doesn't record keyAgreement relationship, the keyAgreement
+// of a document is made up of its activated x25519 methods.
+func (c *MemoDIDController) checkKeyAgreementMethod(client *ethclient.Client, did MemoDID, didUrl MemoDIDUrl) error {
+	if didUrl.Identifier != did.Identifier {
+		return xerrors.Errorf("%s is not a verification method of %s: %w", didUrl.String(), did.String(), ErrNotFound)
+	}
+
+	accountIns, err := proxy.NewIAccountDid(c.accountAddr, client)
+	if err != nil {
+		return err
+	}
+
+	method, err := accountIns.GetVeri(&bind.CallOpts{}, didUrl.Identifier, big.NewInt(int64(didUrl.GetMethodIndex())))
+	if err != nil {
+		return err
+	}
+	if method.Deactivated {
+		return xerrors.Errorf("The Verify Method(%s): %w", didUrl.String(), ErrDeactivated)
+	}
+	if !isX25519Type(method.MethodType) {
+		return xerrors.Errorf("%s(%s) can't be used for key agreement", didUrl.String(), method.MethodType)
+	}
+
+	return nil
+}
+
+// checkInvocationMethod checks didUrl is the masterKey of did. AccountDid contract doesn't record
+// capabilityInvocation, the masterKey is the only method that the contract accepts to invoke updates.
+func checkInvocationMethod(did MemoDID, didUrl MemoDIDUrl) error {
+	if didUrl.Identifier != did.Identifier || didUrl.GetMethodIndex() != 0 {
+		return xerrors.Errorf("%s can't be in capabilityInvocation of %s, only masterKey can invoke capabilities until the contract records capabilityInvocation", didUrl.String(), did.String())
+	}
+	return nil
+}
+
+// checkRelationShipMethod checks didUrl is an activated verification method which isn't x25519,
+// as x25519 methods can only be used for keyAgreement
+func (c *MemoDIDController) checkRelationShipMethod(client *ethclient.Client, didUrl MemoDIDUrl) error {
+	accountIns, err := proxy.NewIAccountDid(c.accountAddr, client)
+	if err != nil {
+		return err
+	}
+
+	size, err := accountIns.GetVeriLen(&bind.CallOpts{}, didUrl.Identifier)
+	if err != nil {
+		return err
+	}
+	index := int64(didUrl.GetMethodIndex())
+	if index >= size.Int64() {
+		return xerrors.Errorf("The Verify Method(%s): %w", didUrl.String(), ErrNotFound)
+	}
+	method, err := accountIns.GetVeri(&bind.CallOpts{}, didUrl.Identifier, big.NewInt(index))
+	if err != nil {
+		return err
+	}
+	if method.Deactivated {
+		return xerrors.Errorf("The Verify Method(%s): %w", didUrl.String(), ErrDeactivated)
+	}
+	if isX25519Type(method.MethodType) {
+		return xerrors.Errorf("%s(%s) can only be used for key agreement", didUrl.String(), method.MethodType)
+	}
+
+	return nil
+}
+
+// checkControl checks did is activated and can be changed by the did of controller,
+// so that the transaction won't be reverted for these reasons
+func (c *MemoDIDController) checkControl(client *ethclient.Client, did MemoDID) error {
+	accountIns, err := proxy.NewIAccountDid(c.accountAddr, client)
+	if err != nil {
+		return err
+	}
+
+	deactivated, err := accountIns.IsDeactivated(&bind.CallOpts{}, did.Identifier)
+	if err != nil {
+		return err
+	}
+	if deactivated {
+		return xerrors.Errorf("%s: %w", did.String(), ErrDeactivated)
+	}
+	if err := c.checkPolicy(client, did); err != nil {
+		return err
+	}
+
+	if strings.EqualFold(did.Identifier, c.did.Identifier) {
+		return nil
+	}
+	isController, err := accountIns.IsController(&bind.CallOpts{}, did.Identifier, c.did.Identifier)
+	if err != nil {
+		return err
+	}
+	if !isController {
+		return xerrors.Errorf("%s can't change %s: %w", c.did.String(), did.String(), ErrNotController)
+	}
+	return nil
+}
+
+func (c *MemoDIDController) DeactivateDID(did MemoDID) error {
+	_, err := c.DeactivateDIDWithResult(did)
+	return err
+}
+
+// DeactivateDIDWithResult is DeactivateDID with the transaction result
+func (c *MemoDIDController) DeactivateDIDWithResult(did MemoDID) (*TxResult, error) {
+	return c.transact(func(client *ethclient.Client) (*txRequest, error) {
+		return c.deactivateDID(client, did)
+	})
+}
+
+func (c *MemoDIDController) deactivateDID(client *ethclient.Client, did MemoDID) (*txRequest, error) {
+	did, err := c.onChain(did)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkControl(client, did); err != nil {
+		return nil, err
+	}
+
+	return &txRequest{
+		name: "DeactivateDID",
+		send: func(proxyIns *proxy.Proxy, opts *bind.TransactOpts) (*types.Transaction, error) {
+			return proxyIns.DeactivateDID(opts, did.Identifier, c.did.Identifier, true)
+		},
+	}, nil
+}
+
+// CheckTx check whether transaction is successful through receipt with DefaultTxWaiter.
+// It returns ErrReceiptTimeout if the transaction isn't packaged in time, and *ErrTxReverted if it failed.
+func CheckTx(endPoint string, txHash common.Hash, name string) error {
+	_, err := DefaultTxWaiter().Wait(context.TODO(), endPoint, txHash, name)
+	return err
+}
@@ -0,0 +1,165 @@
+package memodid
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/memoio/did-solidity/go-contracts/proxy"
+	"golang.org/x/xerrors"
+)
+
+// Delegation is a method in capabilityDelegation of a did and its expiration
+type Delegation struct {
+	ID         MemoDIDUrl
+	Expiration time.Time
+}
+
+// Expired reports whether the delegation is expired at now
+func (d *Delegation) Expired(now time.Time) bool {
+	return now.After(d.Expiration)
+}
+
+// ListDelegations returns capabilityDelegation of did including expired ones, ordered by expiration.
+// Removed delegations and deactivated methods are not listed.
+func (r *MemoDIDResolver) ListDelegations(didString string) ([]Delegation, error) {
+	did, err := r.parseDID(didString)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := ethclient.DialContext(context.TODO(), r.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	accountIns, err := proxy.NewIAccountDid(r.accountAddr, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryDelegations(accountIns, did)
+}
+
+// ExpiringDelegations returns delegations of did which are expired or expire within d
+func (r *MemoDIDResolver) ExpiringDelegations(didString string, within time.Duration) ([]Delegation, error) {
+	delegations, err := r.ListDelegations(didString)
+	if err != nil {
+		return nil, err
+	}
+	return filterExpiring(delegations, time.Now().Add(within)), nil
+}
+
+func filterExpiring(delegations []Delegation, deadline time.Time) []Delegation {
+	var expiring []Delegation
+	for _, delegation := range delegations {
+		if delegation.Expired(deadline) {
+			expiring = append(expiring, delegation)
+		}
+	}
+	return expiring
+}
+
+func queryDelegations(accountIns *proxy.IAccountDid, did *MemoDID) ([]Delegation, error) {
+	delegationIter, err := accountIns.FilterAddDelegation(&bind.FilterOpts{}, []string{did.Identifier})
+	if err != nil {
+		return nil, err
+	}
+	defer delegationIter.Close()
+
+	var delegations []Delegation
+	seen := make(map[string]bool)
+	for delegationIter.Next() {
+		// parse method id
+		didUrl, err := ParseMemoDIDUrl(delegationIter.Event.Id)
+		if err != nil {
+			return nil, err
+		}
+		// renewed delegation has more than one event
+		if seen[didUrl.String()] {
+			continue
+		}
+		seen[didUrl.String()] = true
+
+		// removed delegation has no expiration
+		expiration, err := accountIns.InDelegation(&bind.CallOpts{}, did.Identifier, didUrl.String())
+		if err != nil {
+			return nil, err
+		}
+		if expiration == nil || expiration.Sign() <= 0 {
+			continue
+		}
+		verificationMethod, err := accountIns.GetVeri(&bind.CallOpts{}, didUrl.DID().Identifier, big.NewInt(int64(didUrl.GetMethodIndex())))
+		if err != nil {
+			return nil, err
+		}
+		if !verificationMethod.Deactivated {
+			delegations = append(delegations, Delegation{ID: *didUrl, Expiration: time.Unix(expiration.Int64(), 0)})
+		}
+	}
+
+	sort.SliceStable(delegations, func(i, j int) bool {
+		return delegations[i].Expiration.Before(delegations[j].Expiration)
+	})
+	return delegations, nil
+}
+
+// AddDelegation adds didUrl to capabilityDelegation of did until expiration
+func (c *MemoDIDController) AddDelegation(did MemoDID, didUrl MemoDIDUrl, expiration time.Time) error {
+	if !expiration.After(time.Now()) {
+		return xerrors.Errorf("expiration %s has passed", expiration.Format(time.RFC3339))
+	}
+	return c.addDelegationUntil(did, didUrl, expiration.Unix())
+}
+
+// addDelegationUntil adds didUrl to capabilityDelegation of did until expiration(unix time), which may have passed.
+// The expiration is sent as it is, converting it to relative time would drift.
+func (c *MemoDIDController) addDelegationUntil(did MemoDID, didUrl MemoDIDUrl, expiration int64) error {
+	_, err := c.transact(func(client *ethclient.Client) (*txRequest, error) {
+		return c.addRelationShipUntil(client, did, CapabilityDelegation, didUrl, expiration)
+	})
+	return err
+}
+
+// RenewDelegation sets a new expiration of didUrl in capabilityDelegation of did, expired delegations can be renewed too
+func (c *MemoDIDController) RenewDelegation(did MemoDID, didUrl MemoDIDUrl, expiration time.Time) error {
+	current, err := c.delegationExpiration(did, didUrl)
+	if err != nil {
+		return err
+	}
+	if current <= 0 {
+		return xerrors.Errorf("%s is not in capabilityDelegation of %s: %w", didUrl.String(), did.String(), ErrNotFound)
+	}
+	return c.AddDelegation(did, didUrl, expiration)
+}
+
+// delegationExpiration returns the expiration(unix time) of didUrl in did's capabilityDelegation
+func (c *MemoDIDController) delegationExpiration(did MemoDID, didUrl MemoDIDUrl) (int64, error) {
+	did, err := c.onChain(did)
+	if err != nil {
+		return 0, err
+	}
+	didUrl, err = c.onChainUrl(didUrl)
+	if err != nil {
+		return 0, err
+	}
+	client, err := ethclient.DialContext(context.TODO(), c.endpoint)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	accountIns, err := proxy.NewIAccountDid(c.accountAddr, client)
+	if err != nil {
+		return 0, err
+	}
+	expiration, err := accountIns.InDelegation(&bind.CallOpts{}, did.Identifier, didUrl.String())
+	if err != nil {
+		return 0, err
+	}
+	return expiration.Int64(), nil
+}
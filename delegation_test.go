@@ -0,0 +1,127 @@
+package memodid
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDelegationExpiration(t *testing.T) {
+	_, pks, err := ToPublicKeys([]string{globalPrivateKey2, globalPrivateKey3})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document, err := genDocument(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	did := document.ID
+	key1, err := genVerificationMethod(&did, 1, nil, EcdsaSecp256k1VerificationKey2019, pks[0])
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	key2, err := genVerificationMethod(&did, 2, nil, EcdsaSecp256k1VerificationKey2019, pks[1])
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document.VerificationMethod = append(document.VerificationMethod, key1, key2)
+	document.CapabilityDelegation = []MemoDIDUrl{key1.ID}
+	document.CapabilityDelegationExpiration = map[string]int64{key1.ID.String(): 2000000000}
+	err = document.Validate()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// expiration of a method not in capabilityDelegation
+	invalid := document.clone()
+	invalid.CapabilityDelegationExpiration[key2.ID.String()] = 2000000000
+	err = invalid.Validate()
+	if err == nil || !strings.Contains(err.Error(), "capabilityDelegationExpiration") {
+		t.Errorf("Unexpect validation result: %v", err)
+		return
+	}
+
+	// renew key-1 and delegate key-2
+	renewed := document.clone()
+	renewed.CapabilityDelegation = append(renewed.CapabilityDelegation, key2.ID)
+	renewed.CapabilityDelegationExpiration[key1.ID.String()] = 2100000000
+	renewed.CapabilityDelegationExpiration[key2.ID.String()] = 2100000000
+	cs, err := Diff(document, renewed)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	t.Log(cs.String())
+	if len(cs.RelationShips) != 2 || cs.RelationShips[0].Op != UpdateOperation || cs.RelationShips[1].Op != AddOperation ||
+		cs.RelationShips[0].Expiration != 2100000000 || cs.RelationShips[1].Expiration != 2100000000 {
+		t.Errorf("Unexpect changes:\n%s", cs.String())
+		return
+	}
+	operations, err := planChangeSet(cs, 3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(operations) != 2 || !strings.HasSuffix(operations[0].String(), "until 2036-07-18T13:20:00Z)") {
+		t.Errorf("Unexpect operations: %v", operations)
+		return
+	}
+
+	// expiration is part of the document digest
+	digest1, err := document.Digest()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document.CapabilityDelegationExpiration[key1.ID.String()] = 2000000001
+	digest2, err := document.Digest()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if string(digest1) == string(digest2) {
+		t.Error("Digest should cover delegation expiration")
+	}
+}
+
+func TestFilterExpiring(t *testing.T) {
+	document, err := genDocument(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	now := time.Unix(1000000, 0)
+	var delegations []Delegation
+	for i, expiration := range []time.Duration{-time.Hour, time.Hour, 48 * time.Hour} {
+		didUrl, err := document.ID.DIDUrl(int64(i + 1))
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		delegations = append(delegations, Delegation{ID: *didUrl, Expiration: now.Add(expiration)})
+	}
+
+	if !delegations[0].Expired(now) || delegations[1].Expired(now) {
+		t.Error("Unexpect expired delegations")
+		return
+	}
+	expiring := filterExpiring(delegations, now.Add(24*time.Hour))
+	if len(expiring) != 2 || expiring[0].ID.Fragment != "key-1" || expiring[1].ID.Fragment != "key-2" {
+		t.Errorf("Unexpect expiring delegations: %v", expiring)
+	}
+}
+
+func TestListDelegationsOfOtherChain(t *testing.T) {
+	resolver := &MemoDIDResolver{chainID: big.NewInt(985)}
+	_, err := resolver.ListDelegations("did:memo:1:ce5ac89f84530a1cf2cdee5a0643045a8b0a4995b1c765ba289d7859cfb1193e")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Did of other chain should not be listed: %v", err)
+	}
+}
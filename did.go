@@ -1,233 +1,376 @@
-package memodid
-
-import (
-	"encoding/json"
-	"fmt"
-	"strconv"
-	"strings"
-
-	"github.com/nuts-foundation/did-ockam"
-	"golang.org/x/xerrors"
-)
-
-const (
-	Authentication int = iota
-	AssertionMethod
-	CapabilityDelegation
-	Recovery
-)
-
-type MemoDID struct {
-	// DID Method(memo)
-	Method string
-
-	// The memo-specific-id component of a DID
-	// memo-specific-id = hex(hash(address, nonce))
-	Identifier string
-
-	// memo-specific-id may be composed of multiple `:` separated idstrings
-	// for example: did:memo:{chainID}:ce5ac89f84530a1cf2cdee5a0643045a8b0a4995b1c765ba289d7859cfb1193e
-	Identifiers []string
-}
-
-// Parse parses the input string into a DID structure.
-func ParseMemoDID(didString string) (*MemoDID, error) {
-	did, err := did.Parse(didString)
-	if err != nil {
-		return nil, err
-	}
-	if did.IsURL() {
-		return nil, xerrors.Errorf("%s is did url", didString)
-	}
-	if did.Method != "memo" {
-		return nil, xerrors.Errorf("unsupported method %s", did.Method)
-	}
-	if len(did.IDStrings) > 1 {
-		// TODO: check didString[2:len(didStrings)-1] ==? {chain id}
-		return nil, xerrors.Errorf("TODO: support chain id")
-	}
-	if isNot32ByteHex(did.IDStrings[len(did.IDStrings)-1]) {
-		return nil, xerrors.Errorf("%s is not 32 byte hex string", did.IDStrings[len(did.IDStrings)-1])
-	}
-	return &MemoDID{
-		Method:      "memo",
-		Identifier:  did.ID,
-		Identifiers: did.IDStrings,
-	}, nil
-}
-
-func (d *MemoDID) String() string {
-	return "did:" + d.Method + ":" + d.Identifier
-}
-
-func (d MemoDID) MarshalJSON() ([]byte, error) {
-	if d.Identifier == "" {
-		d.Identifier = strings.Join(d.Identifiers, ":")
-	}
-	didString := "did:" + d.Method + ":" + d.Identifier
-	return json.Marshal(didString)
-}
-
-func (d *MemoDID) UnmarshalJSON(data []byte) error {
-	var didString string
-	err := json.Unmarshal(data, &didString)
-	if err != nil {
-		return err
-	}
-	did, err := ParseMemoDID(didString)
-	if err != nil {
-		return err
-	}
-	d.Method = did.Method
-	d.Identifier = did.Identifier
-	d.Identifiers = did.Identifiers
-	return err
-}
-
-func (d *MemoDID) DIDUrl(methodIndex int64) (*MemoDIDUrl, error) {
-	var id *MemoDIDUrl
-	if methodIndex < 0 {
-		return nil, xerrors.Errorf("method index cannot be less than 0")
-	} else if methodIndex == 0 {
-		id = &MemoDIDUrl{
-			Method:      d.Method,
-			Identifier:  d.Identifier,
-			Identidiers: d.Identifiers,
-			Fragment:    "masterKey",
-		}
-	} else {
-		id = &MemoDIDUrl{
-			Method:      d.Method,
-			Identifier:  d.Identifier,
-			Identidiers: d.Identifiers,
-			Fragment:    fmt.Sprintf("key-%d", methodIndex),
-		}
-	}
-
-	return id, nil
-}
-
-type MemoDIDUrl struct {
-	// DID Method(memo)
-	Method string
-
-	// The memo-specific-id component of a DID
-	// memo-specific-id = hex(hash(address, nonce))
-	Identifier string
-
-	// memo-specific-id may be composed of multiple `:` separated idstrings
-	// for example: did:memo:{chainID}:ce5ac89f84530a1cf2cdee5a0643045a8b0a4995b1c765ba289d7859cfb1193e
-	Identidiers []string
-
-	// DID Fragment, the portion of a DID reference that follows the first character ("#")
-	// support fragment: masterKey, key-{i}
-	Fragment string
-}
-
-func ParseMemoDIDUrl(didUrl string) (*MemoDIDUrl, error) {
-	did, err := did.Parse(didUrl)
-	if err != nil {
-		return nil, err
-	}
-	if !did.IsURL() {
-		return nil, xerrors.Errorf("%s is not did url", didUrl)
-	}
-	if did.Method != "memo" {
-		return nil, xerrors.Errorf("unsupported method %s", did.Method)
-	}
-	if len(did.IDStrings) > 1 {
-		// TODO: check didString[2:len(didStrings)-1] ==? {chain id}
-		return nil, xerrors.Errorf("TODO: support chain id")
-	}
-	if isNot32ByteHex(did.IDStrings[len(did.IDStrings)-1]) {
-		return nil, xerrors.Errorf("%s is not 32 byte hex string", did.IDStrings[len(did.IDStrings)-1])
-	}
-	if did.Path != "" || did.Query != "" {
-		return nil, xerrors.Errorf("unsupported path and query in memo did")
-	}
-	if len(did.Fragment) <= 4 {
-		return nil, xerrors.Errorf("unsupportted fragment: %s", did.Fragment)
-	}
-	if did.Fragment != "masterKey" && (did.Fragment[:4] != "key-" || isNotPositiveNumber(did.Fragment[4:])) {
-		return nil, xerrors.Errorf("unsupportted fragment: %s", did.Fragment)
-	}
-	return &MemoDIDUrl{
-		Method:      did.Method,
-		Identifier:  did.ID,
-		Identidiers: did.IDStrings,
-		Fragment:    did.Fragment,
-	}, nil
-}
-
-func (d *MemoDIDUrl) String() string {
-	return "did:" + d.Method + ":" + d.Identifier + "#" + d.Fragment
-}
-
-func (d MemoDIDUrl) MarshalJSON() ([]byte, error) {
-	return json.Marshal(d.String())
-}
-
-func (d *MemoDIDUrl) UnmarshalJSON(data []byte) error {
-	var didUrlString string
-	err := json.Unmarshal(data, &didUrlString)
-	if err != nil {
-		return err
-	}
-	didUrl, err := ParseMemoDIDUrl(didUrlString)
-	if err != nil {
-		return err
-	}
-	d.Method = didUrl.Method
-	d.Identifier = didUrl.Identifier
-	d.Identidiers = didUrl.Identidiers
-	d.Fragment = didUrl.Fragment
-	return nil
-}
-
-func (d *MemoDIDUrl) GetMethodIndex() int {
-	if d.Fragment == "masterKey" {
-		return 0
-	}
-	if d.Fragment[:4] == "key-" {
-		if i, err := strconv.Atoi(d.Fragment[4:]); err == nil {
-			return i
-		}
-	}
-	return -1
-}
-
-func (d *MemoDIDUrl) DID() MemoDID {
-	return MemoDID{
-		Method:      d.Method,
-		Identifier:  d.Identifier,
-		Identifiers: d.Identidiers,
-	}
-}
-
-func isNot32ByteHex(s string) bool {
-	if len(s) != 64 {
-		return true
-	}
-
-	for _, b := range s {
-		if !((b >= '0' && b <= '9') || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')) {
-			return true
-		}
-	}
-
-	return false
-}
-
-func isNotPositiveNumber(s string) bool {
-	if s == "" || s == "0" {
-		return true
-	}
-
-	for _, b := range s {
-		if b < '0' || b > '9' {
-			return true
-		}
-	}
-
-	return false
-}
+package memodid
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nuts-foundation/did-ockam"
+	"golang.org/x/xerrors"
+)
+
+const (
+	Authentication int = iota
+	AssertionMethod
+	CapabilityDelegation
+	Recovery
+	KeyAgreement
+	CapabilityInvocation
+)
+
+type MemoDID struct {
+	// DID Method(memo)
+	Method string
+
+	// The optional chain segment of did:memo:{chainID}:{memo-specific-id},
+	// empty means the did isn't bound to a chain
+	ChainID string
+
+	// The memo-specific-id component of a DID, without chain segment
+	// memo-specific-id = hex(hash(address, nonce))
+	Identifier string
+
+	// memo-specific-id may be composed of multiple `:` separated idstrings
+	// for example: did:memo:{chainID}:ce5ac89f84530a1cf2cdee5a0643045a8b0a4995b1c765ba289d7859cfb1193e
+	Identifiers []string
+}
+
+// Parse parses the input string into a DID structure.
+func ParseMemoDID(didString string) (*MemoDID, error) {
+	did, err := did.Parse(didString)
+	if err != nil {
+		return nil, xerrors.Errorf("%s: %w", err.Error(), ErrInvalidDID)
+	}
+	if did.IsURL() {
+		return nil, xerrors.Errorf("%s is did url: %w", didString, ErrInvalidDID)
+	}
+	if did.Method != "memo" {
+		return nil, xerrors.Errorf("%s: %w", did.Method, ErrUnsupportedMethod)
+	}
+	chainID, identifier, err := parseIDStrings(did.IDStrings)
+	if err != nil {
+		return nil, err
+	}
+	return &MemoDID{
+		Method:      "memo",
+		ChainID:     chainID,
+		Identifier:  identifier,
+		Identifiers: did.IDStrings,
+	}, nil
+}
+
+// ParseDID parses a did of any method, memo dids are checked by ParseMemoDID
+// and the method-specific-id of others is kept in Identifier as it is
+func ParseDID(didString string) (*MemoDID, error) {
+	method, idStrings, fragment, err := splitDID(didString)
+	if err != nil {
+		return nil, err
+	}
+	if method == "memo" {
+		return ParseMemoDID(didString)
+	}
+	if fragment != "" {
+		return nil, xerrors.Errorf("%s is did url: %w", didString, ErrInvalidDID)
+	}
+	return &MemoDID{
+		Method:      method,
+		Identifier:  strings.Join(idStrings, ":"),
+		Identifiers: idStrings,
+	}, nil
+}
+
+func (d *MemoDID) String() string {
+	return "did:" + d.Method + ":" + chainPrefix(d.ChainID) + d.Identifier
+}
+
+func (d MemoDID) MarshalJSON() ([]byte, error) {
+	if d.Identifier == "" {
+		d.Identifier = strings.Join(d.Identifiers, ":")
+	}
+	return json.Marshal(d.String())
+}
+
+// withoutChain returns the did without chain segment, which is the form kept by contracts
+func (d MemoDID) withoutChain() MemoDID {
+	if d.ChainID == "" {
+		return d
+	}
+	return MemoDID{
+		Method:      d.Method,
+		Identifier:  d.Identifier,
+		Identifiers: []string{d.Identifier},
+	}
+}
+
+// UnmarshalJSON decodes dids of any method as documents of other methods are resolved,
+// operations which write dids to contract check they are memo dids
+func (d *MemoDID) UnmarshalJSON(data []byte) error {
+	var didString string
+	err := json.Unmarshal(data, &didString)
+	if err != nil {
+		return err
+	}
+	did, err := ParseDID(didString)
+	if err != nil {
+		return err
+	}
+	d.Method = did.Method
+	d.ChainID = did.ChainID
+	d.Identifier = did.Identifier
+	d.Identifiers = did.Identifiers
+	return err
+}
+
+func (d *MemoDID) DIDUrl(methodIndex int64) (*MemoDIDUrl, error) {
+	var id *MemoDIDUrl
+	if methodIndex < 0 {
+		return nil, xerrors.Errorf("method index cannot be less than 0: %w", ErrInvalidDID)
+	} else if methodIndex == 0 {
+		id = &MemoDIDUrl{
+			Method:      d.Method,
+			ChainID:     d.ChainID,
+			Identifier:  d.Identifier,
+			Identidiers: d.Identifiers,
+			Fragment:    "masterKey",
+		}
+	} else {
+		id = &MemoDIDUrl{
+			Method:      d.Method,
+			ChainID:     d.ChainID,
+			Identifier:  d.Identifier,
+			Identidiers: d.Identifiers,
+			Fragment:    fmt.Sprintf("key-%d", methodIndex),
+		}
+	}
+
+	return id, nil
+}
+
+type MemoDIDUrl struct {
+	// DID Method(memo)
+	Method string
+
+	// The optional chain segment, same as MemoDID.ChainID
+	ChainID string
+
+	// The memo-specific-id component of a DID, without chain segment
+	// memo-specific-id = hex(hash(address, nonce))
+	Identifier string
+
+	// memo-specific-id may be composed of multiple `:` separated idstrings
+	// for example: did:memo:{chainID}:ce5ac89f84530a1cf2cdee5a0643045a8b0a4995b1c765ba289d7859cfb1193e
+	Identidiers []string
+
+	// DID Fragment, the portion of a DID reference that follows the first character ("#")
+	// support fragment: masterKey, key-{i}
+	Fragment string
+}
+
+func ParseMemoDIDUrl(didUrl string) (*MemoDIDUrl, error) {
+	did, err := did.Parse(didUrl)
+	if err != nil {
+		return nil, xerrors.Errorf("%s: %w", err.Error(), ErrInvalidDID)
+	}
+	if !did.IsURL() {
+		return nil, xerrors.Errorf("%s is not did url: %w", didUrl, ErrInvalidDID)
+	}
+	if did.Method != "memo" {
+		return nil, xerrors.Errorf("%s: %w", did.Method, ErrUnsupportedMethod)
+	}
+	chainID, identifier, err := parseIDStrings(did.IDStrings)
+	if err != nil {
+		return nil, err
+	}
+	if did.Path != "" || did.Query != "" {
+		return nil, xerrors.Errorf("unsupported path and query in memo did: %w", ErrInvalidDID)
+	}
+	if len(did.Fragment) <= 4 {
+		return nil, xerrors.Errorf("unsupportted fragment %s: %w", did.Fragment, ErrInvalidDID)
+	}
+	if did.Fragment != "masterKey" && (did.Fragment[:4] != "key-" || isNotPositiveNumber(did.Fragment[4:])) {
+		return nil, xerrors.Errorf("unsupportted fragment %s: %w", did.Fragment, ErrInvalidDID)
+	}
+	return &MemoDIDUrl{
+		Method:      did.Method,
+		ChainID:     chainID,
+		Identifier:  identifier,
+		Identidiers: did.IDStrings,
+		Fragment:    did.Fragment,
+	}, nil
+}
+
+// ParseDIDUrl parses a did url of any method, memo did urls are checked by ParseMemoDIDUrl
+// and others must have a fragment without path or query
+func ParseDIDUrl(didUrl string) (*MemoDIDUrl, error) {
+	method, idStrings, fragment, err := splitDID(didUrl)
+	if err != nil {
+		return nil, err
+	}
+	if method == "memo" {
+		return ParseMemoDIDUrl(didUrl)
+	}
+	if fragment == "" {
+		return nil, xerrors.Errorf("%s is not did url with fragment: %w", didUrl, ErrInvalidDID)
+	}
+	return &MemoDIDUrl{
+		Method:      method,
+		Identifier:  strings.Join(idStrings, ":"),
+		Identidiers: idStrings,
+		Fragment:    fragment,
+	}, nil
+}
+
+func (d *MemoDIDUrl) String() string {
+	return "did:" + d.Method + ":" + chainPrefix(d.ChainID) + d.Identifier + "#" + d.Fragment
+}
+
+// withoutChain returns the did url without chain segment, which is the form kept by contracts
+func (d MemoDIDUrl) withoutChain() MemoDIDUrl {
+	if d.ChainID == "" {
+		return d
+	}
+	return MemoDIDUrl{
+		Method:      d.Method,
+		Identifier:  d.Identifier,
+		Identidiers: []string{d.Identifier},
+		Fragment:    d.Fragment,
+	}
+}
+
+func (d MemoDIDUrl) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON decodes did urls of any method like MemoDID.UnmarshalJSON
+func (d *MemoDIDUrl) UnmarshalJSON(data []byte) error {
+	var didUrlString string
+	err := json.Unmarshal(data, &didUrlString)
+	if err != nil {
+		return err
+	}
+	didUrl, err := ParseDIDUrl(didUrlString)
+	if err != nil {
+		return err
+	}
+	d.Method = didUrl.Method
+	d.ChainID = didUrl.ChainID
+	d.Identifier = didUrl.Identifier
+	d.Identidiers = didUrl.Identidiers
+	d.Fragment = didUrl.Fragment
+	return nil
+}
+
+func (d *MemoDIDUrl) GetMethodIndex() int {
+	if d.Fragment == "masterKey" {
+		return 0
+	}
+	if strings.HasPrefix(d.Fragment, "key-") {
+		if i, err := strconv.Atoi(d.Fragment[4:]); err == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+func (d *MemoDIDUrl) DID() MemoDID {
+	return MemoDID{
+		Method:      d.Method,
+		ChainID:     d.ChainID,
+		Identifier:  d.Identifier,
+		Identifiers: d.Identidiers,
+	}
+}
+
+// parseIDStrings parses the memo-specific-id, which is {identifier} or {chainID}:{identifier}
+func parseIDStrings(idStrings []string) (string, string, error) {
+	if len(idStrings) > 2 {
+		return "", "", xerrors.Errorf("too many segments in %s: %w", strings.Join(idStrings, ":"), ErrInvalidDID)
+	}
+	chainID := ""
+	if len(idStrings) == 2 {
+		chainID = idStrings[0]
+		if isNotPositiveNumber(chainID) {
+			return "", "", xerrors.Errorf("%s is not chain id: %w", chainID, ErrInvalidDID)
+		}
+	}
+	identifier := idStrings[len(idStrings)-1]
+	if isNot32ByteHex(identifier) {
+		return "", "", xerrors.Errorf("%s is not 32 byte hex string: %w", identifier, ErrInvalidDID)
+	}
+	return chainID, identifier, nil
+}
+
+func chainPrefix(chainID string) string {
+	if chainID == "" {
+		return ""
+	}
+	return chainID + ":"
+}
+
+func isNot32ByteHex(s string) bool {
+	if len(s) != 64 {
+		return true
+	}
+
+	for _, b := range s {
+		if !((b >= '0' && b <= '9') || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isNotPositiveNumber(s string) bool {
+	if s == "" || s == "0" {
+		return true
+	}
+
+	for _, b := range s {
+		if b < '0' || b > '9' {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitDID splits did:{method}:{idstring}[:{idstring}...][#{fragment}] of any method,
+// idstrings may be percent-encoded as did:web does, path and query are not supported
+func splitDID(s string) (string, []string, string, error) {
+	didPart, fragment, hasFragment := strings.Cut(s, "#")
+	if hasFragment && fragment == "" {
+		return "", nil, "", xerrors.Errorf("%s has empty fragment: %w", s, ErrInvalidDID)
+	}
+	parts := strings.SplitN(didPart, ":", 3)
+	if len(parts) != 3 || parts[0] != "did" {
+		return "", nil, "", xerrors.Errorf("%s is not did: %w", s, ErrInvalidDID)
+	}
+	method := parts[1]
+	if method == "" || strings.IndexFunc(method, func(r rune) bool { return !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') }) >= 0 {
+		return "", nil, "", xerrors.Errorf("invalid method %s: %w", method, ErrInvalidDID)
+	}
+
+	idStrings := strings.Split(parts[2], ":")
+	for _, idString := range idStrings {
+		if idString == "" {
+			return "", nil, "", xerrors.Errorf("%s has empty idstring: %w", s, ErrInvalidDID)
+		}
+		for i := 0; i < len(idString); i++ {
+			b := idString[i]
+			switch {
+			case (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b == '.' || b == '-' || b == '_':
+			case b == '%' && i+2 < len(idString) && isHexByte(idString[i+1]) && isHexByte(idString[i+2]):
+				i += 2
+			default:
+				return "", nil, "", xerrors.Errorf("invalid character %q in %s: %w", b, idString, ErrInvalidDID)
+			}
+		}
+	}
+	return method, idStrings, fragment, nil
+}
+
+func isHexByte(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')
+}
@@ -0,0 +1,186 @@
+package memodid
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/xerrors"
+)
+
+// multicodec prefix of secp256k1-pub
+var secp256k1MulticodecPrefix = []byte{0xe7, 0x01}
+
+// KeyResolver resolves did:key offline, the document is derived from the public key in the did.
+// A key agreement method is derived from ed25519 keys as the did:key spec does.
+type KeyResolver struct{}
+
+var _ DIDResolver = &KeyResolver{}
+
+func NewKeyResolver() *KeyResolver {
+	return &KeyResolver{}
+}
+
+// NewKeyDID returns the did:key of an ed25519, x25519 or secp256k1 public key
+func NewKeyDID(vtype string, publicKey []byte) (*MemoDID, error) {
+	if err := ValidatePublicKey(vtype, publicKey); err != nil {
+		return nil, err
+	}
+
+	var identifier string
+	switch {
+	case isEd25519Type(vtype):
+		identifier = encodeMultibaseKey(Ed25519VerificationKey2020, publicKey)
+	case isX25519Type(vtype):
+		identifier = encodeMultibaseKey(X25519KeyAgreementKey2020, publicKey)
+	case vtype != EcdsaSecp256k1VerificationKey2019:
+		return nil, xerrors.Errorf("unsupported verification method type %s", vtype)
+	default:
+		compressed := compressPublicKey(publicKey)
+		identifier = "z" + encodeBase58(append(append([]byte{}, secp256k1MulticodecPrefix...), compressed...))
+	}
+	return ParseDID("did:key:" + identifier)
+}
+
+func (r *KeyResolver) Resolve(didString string) (*MemoDIDDocument, error) {
+	result, err := r.ResolveWithMetadata(didString)
+	if err != nil {
+		return nil, err
+	}
+	return result.Document, nil
+}
+
+func (r *KeyResolver) ResolveWithMetadata(didString string) (*ResolutionResult, error) {
+	did, err := ParseDID(didString)
+	if err != nil {
+		return nil, err
+	}
+	if did.Method != "key" {
+		return nil, xerrors.Errorf("%s: %w", did.Method, ErrUnsupportedMethod)
+	}
+
+	document, err := keyDocument(*did)
+	if err != nil {
+		return nil, err
+	}
+	return &ResolutionResult{
+		Document:           document,
+		ResolutionMetadata: ResolutionMetadata{ContentType: ContentTypeDIDLD},
+	}, nil
+}
+
+func (r *KeyResolver) Dereference(didUrlString string) (string, string, error) {
+	return dereference(r, didUrlString)
+}
+
+// keyDocument derives the document of did:key
+func keyDocument(did MemoDID) (*MemoDIDDocument, error) {
+	if len(did.Identifier) < 2 || did.Identifier[0] != 'z' {
+		return nil, xerrors.Errorf("%s is not base58btc multibase: %w", did.Identifier, ErrInvalidDID)
+	}
+	data, err := decodeBase58(did.Identifier[1:])
+	if err != nil {
+		return nil, xerrors.Errorf("%s: %w", err.Error(), ErrInvalidDID)
+	}
+
+	document := &MemoDIDDocument{
+		Context: DefaultContext,
+		ID:      did,
+	}
+	addMethod := func(method VerificationMethod) MemoDIDUrl {
+		document.VerificationMethod = append(document.VerificationMethod, method)
+		return method.ID
+	}
+	newMethod := func(vtype string, fragment string) VerificationMethod {
+		return VerificationMethod{
+			ID: MemoDIDUrl{
+				Method:      did.Method,
+				Identifier:  did.Identifier,
+				Identidiers: did.Identifiers,
+				Fragment:    fragment,
+			},
+			Controller: did,
+			Type:       vtype,
+		}
+	}
+
+	switch {
+	case bytes.HasPrefix(data, ed25519MulticodecPrefix) && len(data) == len(ed25519MulticodecPrefix)+ed25519.PublicKeySize:
+		method := newMethod(Ed25519VerificationKey2020, did.Identifier)
+		method.PublicKeyMultibase = did.Identifier
+		id := addMethod(method)
+		document.Authentication = []MemoDIDUrl{id}
+		document.AssertionMethod = []MemoDIDUrl{id}
+		document.CapabilityDelegation = []MemoDIDUrl{id}
+		document.CapabilityInvocation = []MemoDIDUrl{id}
+
+		x25519Key, err := ed25519ToX25519(data[len(ed25519MulticodecPrefix):])
+		if err != nil {
+			return nil, xerrors.Errorf("%s: %w", err.Error(), ErrInvalidDID)
+		}
+		multibase := encodeMultibaseKey(X25519KeyAgreementKey2020, x25519Key)
+		agreement := newMethod(X25519KeyAgreementKey2020, multibase)
+		agreement.PublicKeyMultibase = multibase
+		document.KeyAgreement = []MemoDIDUrl{addMethod(agreement)}
+	case bytes.HasPrefix(data, x25519MulticodecPrefix) && len(data) == len(x25519MulticodecPrefix)+curve25519.PointSize:
+		method := newMethod(X25519KeyAgreementKey2020, did.Identifier)
+		method.PublicKeyMultibase = did.Identifier
+		document.KeyAgreement = []MemoDIDUrl{addMethod(method)}
+	case bytes.HasPrefix(data, secp256k1MulticodecPrefix):
+		publicKey := data[len(secp256k1MulticodecPrefix):]
+		if _, err := crypto.DecompressPubkey(publicKey); err != nil {
+			return nil, xerrors.Errorf("invalid secp256k1 key in %s: %w", did.String(), ErrInvalidDID)
+		}
+		method := newMethod(EcdsaSecp256k1VerificationKey2019, did.Identifier)
+		method.PublicKeyHex = hexutil.Encode(publicKey)
+		id := addMethod(method)
+		document.Authentication = []MemoDIDUrl{id}
+		document.AssertionMethod = []MemoDIDUrl{id}
+		document.CapabilityDelegation = []MemoDIDUrl{id}
+		document.CapabilityInvocation = []MemoDIDUrl{id}
+	default:
+		return nil, xerrors.Errorf("unsupported key type of %s: %w", did.String(), ErrInvalidDID)
+	}
+
+	return document, nil
+}
+
+// curve25519P is the field prime 2^255 - 19
+var curve25519P, _ = new(big.Int).SetString("57896044618658097711785492504343953926634992332820282019728792003956564819949", 10)
+
+// ed25519ToX25519 converts an ed25519 public key to the x25519 key of the same secret,
+// u = (1 + y) / (1 - y) mod p
+func ed25519ToX25519(publicKey []byte) ([]byte, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, xerrors.Errorf("invalid ed25519 public key: %d bytes", len(publicKey))
+	}
+	// y is little endian without the sign bit of x
+	le := append([]byte{}, publicKey...)
+	le[31] &= 0x7f
+	y := new(big.Int).SetBytes(reverseBytes(le))
+	if y.Cmp(curve25519P) >= 0 {
+		return nil, xerrors.Errorf("invalid ed25519 public key")
+	}
+
+	denominator := new(big.Int).Sub(big.NewInt(1), y)
+	denominator.Mod(denominator, curve25519P)
+	if denominator.Sign() == 0 {
+		return nil, xerrors.Errorf("invalid ed25519 public key")
+	}
+	u := new(big.Int).Add(big.NewInt(1), y)
+	u.Mul(u, denominator.ModInverse(denominator, curve25519P))
+	u.Mod(u, curve25519P)
+
+	be := u.FillBytes(make([]byte, curve25519.PointSize))
+	return reverseBytes(be), nil
+}
+
+func reverseBytes(data []byte) []byte {
+	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+		data[i], data[j] = data[j], data[i]
+	}
+	return data
+}
@@ -0,0 +1,101 @@
+package memodid
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestResolveKeyDID(t *testing.T) {
+	// example of did:key spec
+	didString := "did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK"
+	resolver := NewKeyResolver()
+	document, err := resolver.Resolve(didString)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if err := document.Validate(); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if document.ID.String() != didString || len(document.VerificationMethod) != 2 || len(document.Authentication) != 1 ||
+		document.Authentication[0].String() != didString+"#z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK" {
+		t.Errorf("Unexpect document: %+v", document)
+		return
+	}
+	if len(document.KeyAgreement) != 1 ||
+		document.KeyAgreement[0].String() != didString+"#z6LSj72tK8brWgZja8NLRwPigth2T9QRiG1uH9oKZuKjdh9p" {
+		t.Errorf("Unexpect key agreement: %v", document.KeyAgreement)
+		return
+	}
+
+	vtype, _, err := resolver.Dereference(document.KeyAgreement[0].String())
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if vtype != X25519KeyAgreementKey2020 {
+		t.Errorf("Unexpect type %s", vtype)
+		return
+	}
+
+	_, err = resolver.Resolve("did:key:z6Mk")
+	if err == nil {
+		t.Error("Resolving an invalid did:key should report an error")
+	}
+}
+
+func TestNewKeyDID(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	did, err := NewKeyDID(Ed25519VerificationKey2018, publicKey)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document, err := NewKeyResolver().Resolve(did.String())
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	msg := []byte("hello")
+	ok, err := document.VerificationMethod[0].Verify(msg, ed25519.Sign(privateKey, msg))
+	if err != nil || !ok {
+		t.Errorf("Signature of ed25519 did:key can't be verified: %v", err)
+		return
+	}
+
+	secpKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	did, err = NewKeyDID(EcdsaSecp256k1VerificationKey2019, crypto.FromECDSAPub(&secpKey.PublicKey))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document, err = NewKeyResolver().Resolve(did.String())
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if err := document.Validate(); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	sig, err := Sign(EcdsaSecp256k1VerificationKey2019, secpKey, msg)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	ok, err = document.VerificationMethod[0].Verify(msg, sig)
+	if err != nil || !ok {
+		t.Errorf("Signature of secp256k1 did:key can't be verified: %v", err)
+	}
+}
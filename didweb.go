@@ -0,0 +1,216 @@
+package memodid
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// ContentTypeDID is the media type of documents without @context
+const ContentTypeDID = "application/did+json"
+
+// maxWebDocumentSize limits the size of did.json fetched by WebResolver
+const maxWebDocumentSize = 1 << 20
+
+// WebResolver resolves did:web by fetching did.json over https with Client,
+// which can be replaced to resolve offline or through a proxy.
+// A single controller, relative references like "#key-1" and verification methods embedded in
+// relationships are allowed by DID Core, they are converted to the form of MemoDIDDocument.
+type WebResolver struct {
+	Client *http.Client
+}
+
+var _ DIDResolver = &WebResolver{}
+
+// NewWebResolver returns a did:web resolver, http.DefaultClient is used if client is nil
+func NewWebResolver(client *http.Client) *WebResolver {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &WebResolver{Client: client}
+}
+
+func (r *WebResolver) Resolve(didString string) (*MemoDIDDocument, error) {
+	result, err := r.ResolveWithMetadata(didString)
+	if err != nil {
+		return nil, err
+	}
+	return result.Document, nil
+}
+
+func (r *WebResolver) ResolveWithMetadata(didString string) (*ResolutionResult, error) {
+	did, err := ParseDID(didString)
+	if err != nil {
+		return nil, err
+	}
+	if did.Method != "web" {
+		return nil, xerrors.Errorf("%s: %w", did.Method, ErrUnsupportedMethod)
+	}
+	documentURL, err := webDocumentURL(*did)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, documentURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", ContentTypeDIDLD+", "+ContentTypeDID+", application/json")
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusGone:
+		return nil, xerrors.Errorf("%s: %w", documentURL, ErrNotFound)
+	case response.StatusCode != http.StatusOK:
+		return nil, xerrors.Errorf("get %s: %s", documentURL, response.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(response.Body, maxWebDocumentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxWebDocumentSize {
+		return nil, xerrors.Errorf("%s is larger than %d bytes", documentURL, maxWebDocumentSize)
+	}
+
+	data, err = normalizeWebDocument(*did, data)
+	if err != nil {
+		return nil, xerrors.Errorf("decode %s: %w", documentURL, err)
+	}
+	document := &MemoDIDDocument{}
+	if err := json.Unmarshal(data, document); err != nil {
+		return nil, xerrors.Errorf("decode %s: %w", documentURL, err)
+	}
+	if document.ID.String() != did.String() {
+		return nil, xerrors.Errorf("document of %s has id %s", did.String(), document.ID.String())
+	}
+
+	contentType := ContentTypeDID
+	if document.Context != "" {
+		contentType = ContentTypeDIDLD
+	}
+	return &ResolutionResult{
+		Document:           document,
+		ResolutionMetadata: ResolutionMetadata{ContentType: contentType},
+	}, nil
+}
+
+func (r *WebResolver) Dereference(didUrlString string) (string, string, error) {
+	return dereference(r, didUrlString)
+}
+
+// webRelationShips are the properties which reference verification methods
+var webRelationShips = []string{"authentication", "assertionMethod", "capabilityDelegation", "capabilityInvocation", "keyAgreement", "recovery"}
+
+// normalizeWebDocument converts the document of did to the form of MemoDIDDocument:
+// controller is a list, relative DID URLs are resolved against did, and verification methods
+// embedded in relationships are moved to verificationMethod and referenced by id
+func normalizeWebDocument(did MemoDID, data []byte) ([]byte, error) {
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	absolute := func(ref string) string {
+		if strings.HasPrefix(ref, "#") {
+			return did.String() + ref
+		}
+		return ref
+	}
+	normalizeMethod := func(value interface{}) (map[string]interface{}, error) {
+		method, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, xerrors.Errorf("verification method is not an object")
+		}
+		id, _ := method["id"].(string)
+		method["id"] = absolute(id)
+		if controller, ok := method["controller"].(string); ok {
+			method["controller"] = absolute(controller)
+		}
+		return method, nil
+	}
+
+	if controller, ok := document["controller"].(string); ok {
+		document["controller"] = []interface{}{controller}
+	}
+
+	var methods []interface{}
+	if value, ok := document["verificationMethod"]; ok && value != nil {
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, xerrors.Errorf("verificationMethod is not a list")
+		}
+		for _, item := range list {
+			method, err := normalizeMethod(item)
+			if err != nil {
+				return nil, err
+			}
+			methods = append(methods, method)
+		}
+	}
+	for _, name := range webRelationShips {
+		value, ok := document[name]
+		if !ok || value == nil {
+			continue
+		}
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, xerrors.Errorf("%s is not a list", name)
+		}
+		refs := make([]interface{}, 0, len(list))
+		for _, item := range list {
+			if ref, ok := item.(string); ok {
+				refs = append(refs, absolute(ref))
+				continue
+			}
+			method, err := normalizeMethod(item)
+			if err != nil {
+				return nil, err
+			}
+			methods = append(methods, method)
+			refs = append(refs, method["id"])
+		}
+		document[name] = refs
+	}
+	if methods != nil {
+		document["verificationMethod"] = methods
+	}
+
+	return json.Marshal(document)
+}
+
+// webDocumentURL returns the url of did.json, e.g.
+// did:web:example.com -> https://example.com/.well-known/did.json
+// did:web:example.com%3A8443:user:alice -> https://example.com:8443/user/alice/did.json
+func webDocumentURL(did MemoDID) (string, error) {
+	segments := make([]string, len(did.Identifiers))
+	for i, segment := range did.Identifiers {
+		decoded, err := url.PathUnescape(segment)
+		if err != nil || decoded == "" || strings.Contains(decoded, "/") {
+			return "", xerrors.Errorf("invalid segment %s of %s: %w", segment, did.String(), ErrInvalidDID)
+		}
+		segments[i] = decoded
+	}
+
+	path := "/.well-known"
+	if len(segments) > 1 {
+		path = "/" + strings.Join(segments[1:], "/")
+	}
+	u := url.URL{Scheme: "https", Host: segments[0], Path: path + "/did.json"}
+	if u.Hostname() == "" {
+		return "", xerrors.Errorf("%s has no host: %w", did.String(), ErrInvalidDID)
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,183 @@
+package memodid
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// mockTransport serves documents keyed by url
+type mockTransport map[string]string
+
+func (m mockTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	body, ok := m[request.URL.String()]
+	if !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func TestResolveWebDID(t *testing.T) {
+	for didString, expected := range map[string]string{
+		"did:web:example.com":                    "https://example.com/.well-known/did.json",
+		"did:web:example.com%3A8443:user:alice":  "https://example.com:8443/user/alice/did.json",
+		"did:web:w3c-ccg.github.io:user%2Falice": "",
+	} {
+		did, err := ParseDID(didString)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		u, err := webDocumentURL(*did)
+		if u != expected || (expected == "" && !errors.Is(err, ErrInvalidDID)) {
+			t.Errorf("Unexpect url of %s: %s, %v", didString, u, err)
+			return
+		}
+	}
+
+	resolver := NewWebResolver(&http.Client{Transport: mockTransport{
+		"https://example.com/.well-known/did.json": `{
+			"@context": ["https://www.w3.org/ns/did/v1", "https://w3id.org/security/suites/ed25519-2020/v1"],
+			"id": "did:web:example.com",
+			"verificationMethod": [{
+				"id": "did:web:example.com#owner",
+				"type": "Ed25519VerificationKey2020",
+				"controller": "did:web:example.com",
+				"publicKeyMultibase": "z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK"
+			}],
+			"authentication": ["did:web:example.com#owner"]
+		}`,
+		"https://other.com/.well-known/did.json": `{"id": "did:web:example.com", "verificationMethod": []}`,
+	}})
+
+	result, err := resolver.ResolveWithMetadata("did:web:example.com")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if result.ResolutionMetadata.ContentType != ContentTypeDIDLD || len(result.Document.Authentication) != 1 {
+		t.Errorf("Unexpect result: %+v", result)
+		return
+	}
+	if err := result.Document.Validate(); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	vtype, publicKey, err := resolver.Dereference("did:web:example.com#owner")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if vtype != Ed25519VerificationKey2020 || len(publicKey) != 66 {
+		t.Errorf("Unexpect method %s %s", vtype, publicKey)
+		return
+	}
+
+	_, err = resolver.Resolve("did:web:unknown.com")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Unexpect error of unknown domain: %v", err)
+		return
+	}
+	_, err = resolver.Resolve("did:web:other.com")
+	if err == nil {
+		t.Error("Document of another did should be rejected")
+	}
+}
+
+func TestResolveWebDIDRelative(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	jwk, err := NewJWK(Ed25519VerificationKey2020, publicKey)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	x25519Key, err := ed25519ToX25519(publicKey)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// shaped like documents published by common did:web hosts
+	resolver := NewWebResolver(&http.Client{Transport: mockTransport{
+		"https://example.com/user/alice/did.json": fmt.Sprintf(`{
+			"@context": ["https://www.w3.org/ns/did/v1", "https://w3id.org/security/suites/jws-2020/v1"],
+			"id": "did:web:example.com:user:alice",
+			"controller": "did:web:example.com",
+			"verificationMethod": [{
+				"id": "#key-1",
+				"type": "JsonWebKey2020",
+				"controller": "did:web:example.com:user:alice",
+				"publicKeyJwk": {"kty": "%s", "crv": "%s", "x": "%s"}
+			}],
+			"authentication": ["#key-1"],
+			"assertionMethod": ["did:web:example.com:user:alice#key-1"],
+			"keyAgreement": [{
+				"id": "#key-2",
+				"type": "X25519KeyAgreementKey2020",
+				"controller": "did:web:example.com:user:alice",
+				"publicKeyMultibase": "%s"
+			}],
+			"service": [{
+				"id": "#linkeddomains",
+				"type": "LinkedDomains",
+				"serviceEndpoint": "https://example.com"
+			}]
+		}`, jwk.Kty, jwk.Crv, jwk.X, encodeMultibaseKey(X25519KeyAgreementKey2020, x25519Key)),
+	}})
+
+	document, err := resolver.Resolve("did:web:example.com:user:alice")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if err := document.Validate(); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(document.Controller) != 1 || document.Controller[0].String() != "did:web:example.com" {
+		t.Errorf("Unexpect controller: %v", document.Controller)
+		return
+	}
+	if len(document.VerificationMethod) != 2 || len(document.KeyAgreement) != 1 {
+		t.Errorf("Unexpect verification methods: %+v", document.VerificationMethod)
+		return
+	}
+	keyAgreement := document.KeyAgreement[0]
+	if keyAgreement.String() != "did:web:example.com:user:alice#key-2" {
+		t.Errorf("Unexpect keyAgreement: %s", keyAgreement.String())
+		return
+	}
+	for _, relationShip := range [][]MemoDIDUrl{document.Authentication, document.AssertionMethod} {
+		if len(relationShip) != 1 || relationShip[0].String() != "did:web:example.com:user:alice#key-1" {
+			t.Errorf("Unexpect relationship: %v", relationShip)
+			return
+		}
+	}
+
+	vtype, _, err := resolver.Dereference("did:web:example.com:user:alice#key-1")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if vtype != "JsonWebKey2020" {
+		t.Errorf("Unexpect type of key-1: %s", vtype)
+		return
+	}
+	vtype, _, err = resolver.Dereference("did:web:example.com:user:alice#key-2")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if vtype != X25519KeyAgreementKey2020 {
+		t.Errorf("Unexpect type of key-2: %s", vtype)
+	}
+}
@@ -1,66 +1,129 @@
-package memodid
-
-import (
-	"github.com/ethereum/go-ethereum/common/hexutil"
-	"github.com/memoio/did-solidity/go-contracts/proxy"
-)
-
-type MemoDIDDocument struct {
-	Context              string               `json:"@context"`
-	ID                   MemoDID              `json:"id"`
-	Controller           []MemoDID            `json:"controller,omitempty"`
-	VerificationMethod   []VerificationMethod `json:"verifycationMethod"`
-	Authentication       []MemoDIDUrl         `json:"authentication,omitempty"`
-	AssertionMethod      []MemoDIDUrl         `json:"assertionMethod,omitempty"`
-	CapabilityDelegation []MemoDIDUrl         `json:"capabilityDelegation,omitempty"`
-	Recovery             []MemoDIDUrl         `json:"recovery,omitempty"`
-}
-
-type VerificationMethod struct {
-	ID           MemoDIDUrl `json:"id"`
-	Controller   MemoDID    `json:"controller"`
-	Type         string     `json:"type"`
-	PublicKeyHex string     `json:"publicKeyHex"`
-}
-
-func FromSolityData(did *MemoDID, methodIndex int64, method *proxy.IAccountDidPublicKey) (*VerificationMethod, error) {
-	if method.Controller == "" {
-		method.Controller = "did:memo:0000000000000000000000000000000000000000000000000000000000000000"
-	} else {
-		method.Controller = "did:memo:" + method.Controller
-	}
-
-	controller, err := ParseMemoDID(method.Controller)
-	if err != nil {
-		return nil, err
-	}
-
-	didUrl, err := did.DIDUrl(methodIndex)
-	if err != nil {
-		return nil, err
-	}
-
-	publicKeyHex := hexutil.Encode(method.PubKeyData)
-	return &VerificationMethod{
-		ID:           *didUrl,
-		Controller:   *controller,
-		Type:         method.MethodType,
-		PublicKeyHex: publicKeyHex,
-	}, nil
-}
-
-func ToSolidityData(method *VerificationMethod) (*proxy.IAccountDidPublicKey, error) {
-	publicKeyData, err := hexutil.Decode(method.PublicKeyHex)
-	if err != nil {
-		return nil, err
-	}
-	return &proxy.IAccountDidPublicKey{
-		Controller: method.Controller.String(),
-		MethodType: method.Type,
-		PubKeyData: publicKeyData,
-	}, nil
-}
-
-// func (v VerificationMethod) MarshalJSON() ([]byte, error) {
-// 	return json.Marshal(v)
-// }
+package memodid
+
+import (
+	"encoding/hex"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/memoio/did-solidity/go-contracts/proxy"
+)
+
+// MemoDIDDocument encodes verification methods as "verificationMethod" rather than
+// the legacy "verifycationMethod" of earlier versions, which is only decoded
+type MemoDIDDocument struct {
+	Context              string               `json:"@context"`
+	ID                   MemoDID              `json:"id"`
+	Controller           []MemoDID            `json:"controller,omitempty"`
+	VerificationMethod   []VerificationMethod `json:"verificationMethod"`
+	Authentication       []MemoDIDUrl         `json:"authentication,omitempty"`
+	AssertionMethod      []MemoDIDUrl         `json:"assertionMethod,omitempty"`
+	CapabilityDelegation []MemoDIDUrl         `json:"capabilityDelegation,omitempty"`
+	Recovery             []MemoDIDUrl         `json:"recovery,omitempty"`
+	KeyAgreement         []MemoDIDUrl         `json:"keyAgreement,omitempty"`
+	CapabilityInvocation []MemoDIDUrl         `json:"capabilityInvocation,omitempty"`
+
+	// expiration(unix time) of each capabilityDelegation, keyed by DID URL
+	CapabilityDelegationExpiration map[string]int64 `json:"capabilityDelegationExpiration,omitempty"`
+}
+
+// RelationShip returns DID URLs of the verification methods in relationType
+func (d *MemoDIDDocument) RelationShip(relationType int) []MemoDIDUrl {
+	switch relationType {
+	case Authentication:
+		return d.Authentication
+	case AssertionMethod:
+		return d.AssertionMethod
+	case CapabilityDelegation:
+		return d.CapabilityDelegation
+	case Recovery:
+		return d.Recovery
+	case KeyAgreement:
+		return d.KeyAgreement
+	case CapabilityInvocation:
+		return d.CapabilityInvocation
+	default:
+		return nil
+	}
+}
+
+// HasRelationShip reports whether didUrl is in relationType of the document,
+// e.g. whether it may invoke(CapabilityInvocation) or delegate(CapabilityDelegation) a capability
+func (d *MemoDIDDocument) HasRelationShip(relationType int, didUrl MemoDIDUrl) bool {
+	return containsDIDUrl(d.RelationShip(relationType), didUrl)
+}
+
+// WithKeyFormat returns a copy of the document whose verification methods are represented in format
+func (d *MemoDIDDocument) WithKeyFormat(format KeyFormat, chainID *big.Int) (*MemoDIDDocument, error) {
+	document := *d
+	document.VerificationMethod = make([]VerificationMethod, 0, len(d.VerificationMethod))
+	for i := range d.VerificationMethod {
+		method, err := d.VerificationMethod[i].WithKeyFormat(format, chainID)
+		if err != nil {
+			return nil, err
+		}
+		document.VerificationMethod = append(document.VerificationMethod, *method)
+	}
+	return &document, nil
+}
+
+type VerificationMethod struct {
+	ID                  MemoDIDUrl `json:"id"`
+	Controller          MemoDID    `json:"controller"`
+	Type                string     `json:"type"`
+	PublicKeyHex        string     `json:"publicKeyHex,omitempty"`
+	PublicKeyMultibase  string     `json:"publicKeyMultibase,omitempty"`
+	PublicKeyJwk        *JWK       `json:"publicKeyJwk,omitempty"`
+	BlockchainAccountID string     `json:"blockchainAccountId,omitempty"`
+}
+
+func FromSolityData(did *MemoDID, methodIndex int64, method *proxy.IAccountDidPublicKey) (*VerificationMethod, error) {
+	// method without controller(e.g. masterKey) is controlled by did itself
+	controller := did
+	if method.Controller != "" && method.Controller != zeroIdentifier {
+		c, err := ParseMemoDID("did:memo:" + method.Controller)
+		if err != nil {
+			return nil, err
+		}
+		controller = c
+	}
+
+	didUrl, err := did.DIDUrl(methodIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	verificationMethod := &VerificationMethod{
+		ID:         *didUrl,
+		Controller: *controller,
+		Type:       method.MethodType,
+	}
+	// ed25519 and x25519 keys are represented as multibase
+	if isMultibaseType(method.MethodType) {
+		verificationMethod.PublicKeyMultibase = encodeMultibaseKey(method.MethodType, method.PubKeyData)
+	} else {
+		verificationMethod.PublicKeyHex = hexutil.Encode(method.PubKeyData)
+	}
+	return verificationMethod, nil
+}
+
+func ToSolidityData(method *VerificationMethod) (*proxy.IAccountDidPublicKey, error) {
+	publicKeyData, err := method.PublicKeyBytes()
+	if err != nil {
+		return nil, err
+	}
+	return &proxy.IAccountDidPublicKey{
+		Controller: method.Controller.String(),
+		MethodType: method.Type,
+		PubKeyData: publicKeyData,
+	}, nil
+}
+
+// decodeHexKey decodes hex public key with or without 0x prefix
+func decodeHexKey(publicKeyHex string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimPrefix(publicKeyHex, "0x"))
+}
+
+// func (v VerificationMethod) MarshalJSON() ([]byte, error) {
+// 	return json.Marshal(v)
+// }
@@ -0,0 +1,56 @@
+package memodid
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/xerrors"
+)
+
+// errors returned by parsing, resolving and controlling dids are wrapped,
+// use errors.Is and errors.As to check them
+var (
+	// ErrInvalidDID means the did or did url is malformed
+	ErrInvalidDID = xerrors.New("invalid did")
+	// ErrUnsupportedMethod means the did method is not memo
+	ErrUnsupportedMethod = xerrors.New("unsupported method")
+	// ErrDeactivated means the did or verification method is deactivated
+	ErrDeactivated = xerrors.New("deactivated")
+	// ErrNotFound means the did, verification method or relationship doesn't exist
+	ErrNotFound = xerrors.New("not found")
+	// ErrNotController means the did of controller can't change the did
+	ErrNotController = xerrors.New("not controller")
+	// ErrReceiptTimeout means no receipt of the transaction is got before timeout,
+	// the transaction may be packaged later
+	ErrReceiptTimeout = xerrors.New("receipt timeout")
+	// ErrTxDropped means the transaction is removed from the pool without being packaged
+	ErrTxDropped = xerrors.New("transaction dropped")
+	// ErrTxReplaced means another transaction with the same nonce is packaged
+	ErrTxReplaced = xerrors.New("transaction replaced")
+)
+
+// ErrTxReverted is returned when a transaction is mined but its execution failed
+type ErrTxReverted struct {
+	// name of the operation
+	Name string
+	Hash common.Hash
+	// revert reason of the contract, it is empty if the reason can't be got
+	Reason string
+}
+
+func (e *ErrTxReverted) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("%s: transaction(%s) mined but execution failed, please check your tx input", e.Name, e.Hash)
+	}
+	return fmt.Sprintf("%s: transaction(%s) reverted: %s", e.Name, e.Hash, e.Reason)
+}
+
+// Is reports whether target is an ErrTxReverted of the same transaction,
+// an ErrTxReverted without hash matches any reverted transaction
+func (e *ErrTxReverted) Is(target error) bool {
+	t, ok := target.(*ErrTxReverted)
+	if !ok {
+		return false
+	}
+	return t.Hash == (common.Hash{}) || t.Hash == e.Hash
+}
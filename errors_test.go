@@ -0,0 +1,93 @@
+package memodid
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/xerrors"
+)
+
+func TestParseErrors(t *testing.T) {
+	identifier := "ce5ac89f84530a1cf2cdee5a0643045a8b0a4995b1c765ba289d7859cfb1193e"
+
+	for didString, target := range map[string]error{
+		"did:memo:1234":                         ErrInvalidDID,
+		"did:memo:" + identifier + "#masterKey": ErrInvalidDID,
+		"did:web:" + identifier:                 ErrUnsupportedMethod,
+		"did:memo:985:1:" + identifier:          ErrInvalidDID,
+		"memo:" + identifier:                    ErrInvalidDID,
+	} {
+		_, err := ParseMemoDID(didString)
+		if !errors.Is(err, target) {
+			t.Errorf("Unexpect error of %s: %v", didString, err)
+			return
+		}
+	}
+
+	for didUrl, target := range map[string]error{
+		"did:memo:" + identifier:               ErrInvalidDID,
+		"did:memo:" + identifier + "#key-0":    ErrInvalidDID,
+		"did:memo:" + identifier + "#key-a":    ErrInvalidDID,
+		"did:key:" + identifier + "#masterKey": ErrUnsupportedMethod,
+	} {
+		_, err := ParseMemoDIDUrl(didUrl)
+		if !errors.Is(err, target) {
+			t.Errorf("Unexpect error of %s: %v", didUrl, err)
+			return
+		}
+	}
+
+	did, err := ParseMemoDID("did:memo:" + identifier)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	_, err = did.DIDUrl(-1)
+	if !errors.Is(err, ErrInvalidDID) {
+		t.Errorf("Unexpect error: %v", err)
+	}
+}
+
+func TestTxReverted(t *testing.T) {
+	hash := common.HexToHash("0x01")
+	err := xerrors.Errorf("apply: %w", &ErrTxReverted{Name: "AddController", Hash: hash, Reason: "not controller"})
+
+	var reverted *ErrTxReverted
+	if !errors.As(err, &reverted) {
+		t.Errorf("Unexpect error: %v", err)
+		return
+	}
+	if reverted.Hash != hash || reverted.Reason != "not controller" {
+		t.Errorf("Unexpect reverted transaction: %v", reverted)
+		return
+	}
+	if !errors.Is(err, &ErrTxReverted{}) || !errors.Is(err, &ErrTxReverted{Hash: hash}) {
+		t.Error("Reverted transaction should match ErrTxReverted")
+		return
+	}
+	if errors.Is(err, &ErrTxReverted{Hash: common.HexToHash("0x02")}) || errors.Is(err, ErrReceiptTimeout) {
+		t.Error("Reverted transaction should only match itself")
+	}
+}
+
+func TestResolverErrors(t *testing.T) {
+	document, err := genDocument(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	resolver := mockResolver{}
+	_, err = resolver.Resolve(document.ID.String())
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Unexpect error: %v", err)
+		return
+	}
+
+	// deactivated did
+	resolver[document.ID.String()] = &MemoDIDDocument{}
+	_, err = NewRecoveryRequest(resolver[document.ID.String()], EcdsaSecp256k1VerificationKey2019, document.VerificationMethod[0].PublicKeyHex, nil)
+	if !errors.Is(err, ErrDeactivated) {
+		t.Errorf("Unexpect error: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package memodid
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"golang.org/x/xerrors"
+)
+
+// chain ids of did:ethr network names, other networks are given by hex chain id such as did:ethr:0x5:{address}
+var ethrNetworks = map[string]*big.Int{
+	"mainnet": big.NewInt(1),
+	"goerli":  big.NewInt(5),
+	"sepolia": big.NewInt(11155111),
+	"polygon": big.NewInt(137),
+}
+
+// EthrResolver resolves did:ethr:[network:]{address or compressed public key} offline.
+// The document is the default one of the identity: a #controller method with its blockchainAccountId,
+// and a #controllerKey method if the did contains public key. Changes made in the ERC-1056 registry
+// contract, such as owner changes and added attributes, are not read.
+type EthrResolver struct{}
+
+var _ DIDResolver = &EthrResolver{}
+
+func NewEthrResolver() *EthrResolver {
+	return &EthrResolver{}
+}
+
+func (r *EthrResolver) Resolve(didString string) (*MemoDIDDocument, error) {
+	result, err := r.ResolveWithMetadata(didString)
+	if err != nil {
+		return nil, err
+	}
+	return result.Document, nil
+}
+
+func (r *EthrResolver) ResolveWithMetadata(didString string) (*ResolutionResult, error) {
+	did, err := ParseDID(didString)
+	if err != nil {
+		return nil, err
+	}
+	if did.Method != "ethr" {
+		return nil, xerrors.Errorf("%s: %w", did.Method, ErrUnsupportedMethod)
+	}
+
+	document, err := ethrDocument(*did)
+	if err != nil {
+		return nil, err
+	}
+	return &ResolutionResult{
+		Document:           document,
+		ResolutionMetadata: ResolutionMetadata{ContentType: ContentTypeDIDLD},
+	}, nil
+}
+
+// Dereference returns the type and hex public key of the verification method,
+// #controller only has an address and can't be dereferenced
+func (r *EthrResolver) Dereference(didUrlString string) (string, string, error) {
+	return dereference(r, didUrlString)
+}
+
+// ethrDocument derives the default document of did:ethr
+func ethrDocument(did MemoDID) (*MemoDIDDocument, error) {
+	chainID := ethrNetworks["mainnet"]
+	identifier := did.Identifiers[len(did.Identifiers)-1]
+	switch len(did.Identifiers) {
+	case 1:
+	case 2:
+		network := did.Identifiers[0]
+		if id, ok := ethrNetworks[network]; ok {
+			chainID = id
+		} else if id, err := hexutil.DecodeBig(network); err == nil && id.Sign() > 0 {
+			chainID = id
+		} else {
+			return nil, xerrors.Errorf("unknown network %s of %s: %w", network, did.String(), ErrInvalidDID)
+		}
+	default:
+		return nil, xerrors.Errorf("%s has too many idstrings: %w", did.String(), ErrInvalidDID)
+	}
+
+	var address common.Address
+	var publicKey []byte
+	switch {
+	case common.IsHexAddress(identifier) && strings.HasPrefix(identifier, "0x"):
+		address = common.HexToAddress(identifier)
+	case len(identifier) == 68:
+		key, err := hexutil.Decode(identifier)
+		if err != nil {
+			return nil, xerrors.Errorf("%s: %w", err.Error(), ErrInvalidDID)
+		}
+		var ok bool
+		if address, ok = publicKeyToAddress(key); !ok {
+			return nil, xerrors.Errorf("invalid secp256k1 key in %s: %w", did.String(), ErrInvalidDID)
+		}
+		publicKey = key
+	default:
+		return nil, xerrors.Errorf("%s is neither address nor compressed public key: %w", identifier, ErrInvalidDID)
+	}
+
+	document := &MemoDIDDocument{
+		Context: DefaultContext,
+		ID:      did,
+	}
+	addMethod := func(method VerificationMethod) {
+		document.VerificationMethod = append(document.VerificationMethod, method)
+		document.Authentication = append(document.Authentication, method.ID)
+		document.AssertionMethod = append(document.AssertionMethod, method.ID)
+	}
+	newMethod := func(vtype string, fragment string) VerificationMethod {
+		return VerificationMethod{
+			ID: MemoDIDUrl{
+				Method:      did.Method,
+				Identifier:  did.Identifier,
+				Identidiers: did.Identifiers,
+				Fragment:    fragment,
+			},
+			Controller: did,
+			Type:       vtype,
+		}
+	}
+
+	method := newMethod(EcdsaSecp256k1RecoveryMethod2020, "controller")
+	method.BlockchainAccountID = "eip155:" + chainID.String() + ":" + address.Hex()
+	addMethod(method)
+	if publicKey != nil {
+		method := newMethod(EcdsaSecp256k1VerificationKey2019, "controllerKey")
+		method.PublicKeyHex = hexutil.Encode(publicKey)
+		addMethod(method)
+	}
+
+	return document, nil
+}
@@ -0,0 +1,79 @@
+package memodid
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestResolveEthrDID(t *testing.T) {
+	resolver := NewEthrResolver()
+
+	document, err := resolver.Resolve("did:ethr:sepolia:0xb9c5714089478a327f09197987f16f9e5d936e8a")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if err := document.Validate(); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(document.VerificationMethod) != 1 || len(document.Authentication) != 1 || len(document.AssertionMethod) != 1 {
+		t.Errorf("Unexpect document: %+v", document)
+		return
+	}
+	method := document.VerificationMethod[0]
+	if method.ID.String() != "did:ethr:sepolia:0xb9c5714089478a327f09197987f16f9e5d936e8a#controller" ||
+		method.Type != EcdsaSecp256k1RecoveryMethod2020 ||
+		method.BlockchainAccountID != "eip155:11155111:"+common.HexToAddress("0xb9c5714089478a327f09197987f16f9e5d936e8a").Hex() {
+		t.Errorf("Unexpect controller method: %+v", method)
+		return
+	}
+
+	// did of public key has the key as #controllerKey
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	publicKey := hexutil.Encode(crypto.CompressPubkey(&privateKey.PublicKey))
+	address := crypto.PubkeyToAddress(privateKey.PublicKey)
+	didString := "did:ethr:0x5:" + publicKey
+	document, err = resolver.Resolve(didString)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if err := document.Validate(); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(document.VerificationMethod) != 2 || document.VerificationMethod[0].BlockchainAccountID != "eip155:5:"+address.Hex() {
+		t.Errorf("Unexpect document: %+v", document)
+		return
+	}
+	vtype, key, err := resolver.Dereference(didString + "#controllerKey")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if vtype != EcdsaSecp256k1VerificationKey2019 || key != publicKey {
+		t.Errorf("Unexpect method %s %s", vtype, key)
+		return
+	}
+
+	for _, didString := range []string{
+		"did:ethr:unknown:0xb9c5714089478a327f09197987f16f9e5d936e8a",
+		"did:ethr:0xb9c5714089478a327f09197987f16f9e5d936e",
+		"did:ethr:mainnet:extra:0xb9c5714089478a327f09197987f16f9e5d936e8a",
+	} {
+		_, err = resolver.Resolve(didString)
+		if !errors.Is(err, ErrInvalidDID) {
+			t.Errorf("Unexpect error of %s: %v", didString, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package memodid
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/memoio/did-solidity/go-contracts/proxy"
+	"golang.org/x/xerrors"
+)
+
+// GasConfig decides the gas limit and fee of transactions sent by controller
+type GasConfig struct {
+	// gas limit is the estimated gas multiplied by GasMargin
+	GasMargin float64
+	// fixed gas limit, 0 means estimating the gas of each transaction
+	GasLimit uint64
+	// legacy gas price, if it is nil, EIP-1559 fee is used on chains with base fee
+	// and the suggested gas price is used on the others
+	GasPrice *big.Int
+
+	// a transaction pending longer than BumpAfter is resubmitted with the same nonce and
+	// BumpPercent higher fee, at most MaxBumps times. 0 means no bump.
+	BumpAfter   time.Duration
+	BumpPercent int64
+	MaxBumps    int
+	// bumped gas price or fee cap can't exceed MaxFee, nil means no limit
+	MaxFee *big.Int
+}
+
+// minBumpPercent is the least fee increase accepted by the pool of geth to replace a transaction
+const minBumpPercent = 10
+
+func DefaultGasConfig() *GasConfig {
+	return &GasConfig{
+		GasMargin:   1.2,
+		BumpAfter:   30 * time.Second,
+		BumpPercent: 20,
+		MaxBumps:    3,
+	}
+}
+
+// SetGasConfig sets the gas limit and fee of transactions sent by controller
+func (c *MemoDIDController) SetGasConfig(gas *GasConfig) {
+	c.gas = gas
+}
+
+// txSender builds, signs and sends(unless opts.NoSend) a transaction with opts through proxyIns
+type txSender func(proxyIns *proxy.Proxy, opts *bind.TransactOpts) (*types.Transaction, error)
+
+// sentTx is a transaction of operation name sent by controller,
+// it is replaced by transactions with the same nonce and higher fee while it is pending
+type sentTx struct {
+	name  string
+	nonce uint64
+	// hashes of all the replacements, the last one is the latest
+	hashes []common.Hash
+
+	// send, opts and tx are used to bump fee, transactions restored from store have no them and are not bumped
+	send txSender
+	opts bind.TransactOpts
+	tx   *types.Transaction
+	// bumped is called after a replacement is sent
+	bumped func(hash common.Hash)
+}
+
+// sendTx sends the transaction of operation name with estimated gas, bumps its fee while it is pending,
+// and waits for its receipt
+func (c *MemoDIDController) sendTx(client *ethclient.Client, name string, send txSender) (*types.Receipt, error) {
+	sent, err := c.submitTx(client, name, send)
+	if err != nil {
+		return nil, err
+	}
+	return c.trackTx(context.TODO(), client, sent)
+}
+
+// submitTx sends the transaction with a nonce from the nonce manager and estimated gas,
+// it is resent with a new nonce if the nonce is used by transactions sent by others
+func (c *MemoDIDController) submitTx(client *ethclient.Client, name string, send txSender) (*sentTx, error) {
+	gas := c.gasConfig()
+	ctx := context.TODO()
+	proxyIns, err := proxy.NewProxy(c.proxyAddr, client)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := *c.didTransactor
+	opts.Context = ctx
+	opts.GasPrice = gas.GasPrice
+	for retries := 0; ; retries++ {
+		nonce, err := c.nonces.acquire(ctx, client, opts.From)
+		if err != nil {
+			return nil, err
+		}
+		opts.Nonce = new(big.Int).SetUint64(nonce)
+
+		opts.GasLimit = gas.GasLimit
+		if opts.GasLimit == 0 {
+			// bind estimates gas when gas limit is 0, build the transaction without sending to get it
+			opts.NoSend = true
+			tx, err := send(proxyIns, &opts)
+			opts.NoSend = false
+			if err != nil {
+				c.releaseNonce(ctx, client, nonce)
+				return nil, xerrors.Errorf("%s: estimate gas: %w", name, err)
+			}
+			opts.GasLimit = withMargin(tx.Gas(), gas.GasMargin)
+		}
+
+		tx, err := send(proxyIns, &opts)
+		if err == nil {
+			return &sentTx{
+				name:   name,
+				nonce:  nonce,
+				hashes: []common.Hash{tx.Hash()},
+				send:   send,
+				opts:   opts,
+				tx:     tx,
+			}, nil
+		}
+		if !isNonceError(err) {
+			c.releaseNonce(ctx, client, nonce)
+			return nil, err
+		}
+		c.nonces.reset()
+		if retries >= maxNonceRetries {
+			return nil, err
+		}
+	}
+}
+
+// trackTx waits for the receipt of sent, and bumps its fee if it is pending longer than GasConfig.BumpAfter
+func (c *MemoDIDController) trackTx(ctx context.Context, client *ethclient.Client, sent *sentTx) (*types.Receipt, error) {
+	gas := c.gasConfig()
+	waiter := c.waiter
+	if waiter == nil {
+		waiter = DefaultTxWaiter()
+	}
+
+	for bumps := 0; ; bumps++ {
+		hash := sent.hashes[len(sent.hashes)-1]
+		w := *waiter
+		canBump := sent.send != nil && gas.BumpAfter > 0 && bumps < gas.MaxBumps
+		if canBump {
+			w.Timeout = gas.BumpAfter
+		}
+		receipt, err := w.wait(ctx, client, hash, sent.name)
+		if errors.Is(err, ErrTxDropped) {
+			c.releaseNonce(ctx, client, sent.nonce)
+		}
+		if errors.Is(err, ErrTxReplaced) {
+			// one of the transactions with lower fee is packaged
+			for i := len(sent.hashes) - 2; i >= 0; i-- {
+				if _, err := client.TransactionReceipt(ctx, sent.hashes[i]); err == nil {
+					return waiter.wait(ctx, client, sent.hashes[i], sent.name)
+				}
+			}
+		}
+		if !canBump || !errors.Is(err, ErrReceiptTimeout) {
+			return receipt, err
+		}
+
+		if !bumpFee(&sent.opts, sent.tx, gas) {
+			return waiter.wait(ctx, client, hash, sent.name)
+		}
+		proxyIns, err := proxy.NewProxy(c.proxyAddr, client)
+		if err != nil {
+			return nil, err
+		}
+		bumped, err := sent.send(proxyIns, &sent.opts)
+		if err != nil {
+			// the transaction may be packaged just now
+			return waiter.wait(ctx, client, hash, sent.name)
+		}
+		sent.tx = bumped
+		sent.hashes = append(sent.hashes, bumped.Hash())
+		if sent.bumped != nil {
+			sent.bumped(bumped.Hash())
+		}
+	}
+}
+
+// releaseNonce returns a nonce which is not used, a gap below the nonces in use is filled at once
+// rather than by the next transaction, which may never come
+func (c *MemoDIDController) releaseNonce(ctx context.Context, client *ethclient.Client, nonce uint64) {
+	if !c.nonces.release(nonce) {
+		return
+	}
+	_, err := fillNonce(ctx, client, c.didTransactor, c.gasConfig().GasPrice, nonce)
+	if err == nil {
+		return
+	}
+	if isNonceError(err) {
+		// the nonce is used by others
+		c.nonces.reset()
+		return
+	}
+	c.nonces.reuse(nonce)
+}
+
+func (c *MemoDIDController) gasConfig() *GasConfig {
+	if c.gas == nil {
+		return DefaultGasConfig()
+	}
+	return c.gas
+}
+
+func withMargin(gas uint64, margin float64) uint64 {
+	if margin <= 1 {
+		return gas
+	}
+	return uint64(math.Ceil(float64(gas) * margin))
+}
+
+// bumpFee sets opts to replace tx with higher fee, it returns false if the fee would exceed gas.MaxFee
+func bumpFee(opts *bind.TransactOpts, tx *types.Transaction, gas *GasConfig) bool {
+	percent := gas.BumpPercent
+	if percent < minBumpPercent {
+		percent = minBumpPercent
+	}
+	bump := func(fee *big.Int) *big.Int {
+		bumped := new(big.Int).Mul(fee, big.NewInt(100+percent))
+		bumped.Div(bumped, big.NewInt(100))
+		if bumped.Cmp(fee) <= 0 {
+			bumped.Add(fee, big.NewInt(1))
+		}
+		return bumped
+	}
+
+	opts.Nonce = new(big.Int).SetUint64(tx.Nonce())
+	if tx.Type() == types.DynamicFeeTxType {
+		feeCap := bump(tx.GasFeeCap())
+		if gas.MaxFee != nil && feeCap.Cmp(gas.MaxFee) > 0 {
+			return false
+		}
+		opts.GasPrice = nil
+		opts.GasFeeCap = feeCap
+		opts.GasTipCap = bump(tx.GasTipCap())
+		return true
+	}
+
+	gasPrice := bump(tx.GasPrice())
+	if gas.MaxFee != nil && gasPrice.Cmp(gas.MaxFee) > 0 {
+		return false
+	}
+	opts.GasPrice = gasPrice
+	return true
+}
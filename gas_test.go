@@ -0,0 +1,54 @@
+package memodid
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestWithMargin(t *testing.T) {
+	if withMargin(100000, 1.2) != 120000 || withMargin(100001, 1.2) != 120002 {
+		t.Error("Gas limit should be rounded up")
+		return
+	}
+	if withMargin(100000, 0) != 100000 || withMargin(100000, 0.5) != 100000 {
+		t.Error("Margin less than 1 should be ignored")
+	}
+}
+
+func TestBumpFee(t *testing.T) {
+	to := common.Address{}
+	legacy := types.NewTx(&types.LegacyTx{Nonce: 7, GasPrice: big.NewInt(1000), Gas: 21000, To: &to})
+	dynamic := types.NewTx(&types.DynamicFeeTx{Nonce: 8, GasTipCap: big.NewInt(5), GasFeeCap: big.NewInt(2000), Gas: 21000, To: &to})
+
+	opts := &bind.TransactOpts{}
+	gas := &GasConfig{BumpPercent: 20}
+	if !bumpFee(opts, legacy, gas) {
+		t.Error("Fee should be bumped")
+		return
+	}
+	if opts.Nonce.Uint64() != 7 || opts.GasPrice.Int64() != 1200 {
+		t.Errorf("Unexpect bumped transaction: nonce %d, gas price %d", opts.Nonce.Uint64(), opts.GasPrice.Int64())
+		return
+	}
+
+	// the pool requires at least 10%, and small tip is increased by 1
+	opts = &bind.TransactOpts{GasPrice: big.NewInt(1)}
+	gas = &GasConfig{BumpPercent: 1}
+	if !bumpFee(opts, dynamic, gas) {
+		t.Error("Fee should be bumped")
+		return
+	}
+	if opts.Nonce.Uint64() != 8 || opts.GasPrice != nil || opts.GasFeeCap.Int64() != 2200 || opts.GasTipCap.Int64() != 6 {
+		t.Errorf("Unexpect bumped transaction: fee cap %d, tip cap %d", opts.GasFeeCap.Int64(), opts.GasTipCap.Int64())
+		return
+	}
+
+	gas.MaxFee = big.NewInt(2100)
+	if bumpFee(&bind.TransactOpts{}, dynamic, gas) {
+		t.Error("Fee can't exceed MaxFee")
+	}
+}
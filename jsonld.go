@@ -0,0 +1,209 @@
+package memodid
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// suite contexts of verification method types
+var suiteContexts = map[string]string{
+	EcdsaSecp256k1VerificationKey2019: "https://w3id.org/security/suites/secp256k1-2019/v1",
+	Ed25519VerificationKey2018:        "https://w3id.org/security/suites/ed25519-2018/v1",
+	Ed25519VerificationKey2020:        "https://w3id.org/security/suites/ed25519-2020/v1",
+	X25519KeyAgreementKey2019:         "https://w3id.org/security/suites/x25519-2019/v1",
+	X25519KeyAgreementKey2020:         "https://w3id.org/security/suites/x25519-2020/v1",
+}
+
+// JSONLDMediaType is the media type of MarshalJSONLD output
+const JSONLDMediaType = "application/did+ld+json"
+
+const (
+	jwsContext                 = "https://w3id.org/security/suites/jws-2020/v1"
+	blockchainAccountIDContext = "https://w3id.org/security/suites/secp256k1recovery-2020/v2"
+	securityVocab              = "https://w3id.org/security#"
+	memoVocab                  = "https://github.com/memoio/did-docs/blob/master/memo-did-design.md#"
+	rdfType                    = "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"
+	rdfJSON                    = "http://www.w3.org/1999/02/22-rdf-syntax-ns#JSON"
+)
+
+// memo specific terms which are not defined by did and suite contexts
+var memoContext = map[string]interface{}{
+	"publicKeyHex": securityVocab + "publicKeyHex",
+	"recovery": map[string]interface{}{
+		"@id":        memoVocab + "recovery",
+		"@type":      "@id",
+		"@container": "@set",
+	},
+	"capabilityDelegationExpiration": map[string]interface{}{
+		"@id":   memoVocab + "capabilityDelegationExpiration",
+		"@type": "@json",
+	},
+}
+
+// JSONLDContext returns @context of the document: did context, suite contexts of
+// the key types and key representations in use, and memo specific terms
+func (d *MemoDIDDocument) JSONLDContext() []interface{} {
+	context := []interface{}{DefaultContext}
+
+	var suites []string
+	seen := make(map[string]bool)
+	addSuite := func(suite string) {
+		if suite != "" && !seen[suite] {
+			seen[suite] = true
+			suites = append(suites, suite)
+		}
+	}
+	for _, method := range d.VerificationMethod {
+		addSuite(suiteContexts[method.Type])
+		if method.PublicKeyJwk != nil {
+			addSuite(jwsContext)
+		}
+		if method.BlockchainAccountID != "" {
+			addSuite(blockchainAccountIDContext)
+		}
+	}
+	sort.Strings(suites)
+	for _, suite := range suites {
+		context = append(context, suite)
+	}
+
+	return append(context, memoContext)
+}
+
+// MarshalJSONLD encodes the document as application/did+ld+json
+func (d *MemoDIDDocument) MarshalJSONLD() ([]byte, error) {
+	type documentAlias MemoDIDDocument
+	return json.Marshal(struct {
+		Context []interface{} `json:"@context"`
+		*documentAlias
+	}{
+		Context:       d.JSONLDContext(),
+		documentAlias: (*documentAlias)(d),
+	})
+}
+
+// UnmarshalJSON decodes both application/did+json and application/did+ld+json,
+// documents with the legacy "verifycationMethod" property are also accepted
+func (d *MemoDIDDocument) UnmarshalJSON(data []byte) error {
+	type documentAlias MemoDIDDocument
+	aux := struct {
+		Context                  interface{}          `json:"@context"`
+		LegacyVerificationMethod []VerificationMethod `json:"verifycationMethod"`
+		*documentAlias
+	}{
+		documentAlias: (*documentAlias)(d),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	// suite contexts are derived from the verification methods
+	switch context := aux.Context.(type) {
+	case string:
+		d.Context = context
+	case []interface{}:
+		if len(context) > 0 {
+			d.Context, _ = context[0].(string)
+		}
+	}
+	if len(d.VerificationMethod) == 0 {
+		d.VerificationMethod = aux.LegacyVerificationMethod
+	}
+
+	return nil
+}
+
+// NQuads returns the document as sorted N-Quads, which is a deterministic form to compare and hash documents.
+// Predicates are fixed by the package instead of expanded from @context, so it is not the URDNA2015
+// canonical form of the JSON-LD document and can't be used to verify Linked Data proofs.
+func (d *MemoDIDDocument) NQuads() (string, error) {
+	var quads []string
+	seen := make(map[string]bool)
+	add := func(quad string) {
+		if !seen[quad] {
+			seen[quad] = true
+			quads = append(quads, quad)
+		}
+	}
+	iri := func(s string) string {
+		return "<" + s + ">"
+	}
+
+	subject := iri(d.ID.String())
+	for _, controller := range d.Controller {
+		add(nquad(subject, iri(securityVocab+"controller"), iri(controller.String())))
+	}
+
+	for _, method := range d.VerificationMethod {
+		id := iri(method.ID.String())
+		add(nquad(subject, iri(securityVocab+"verificationMethod"), id))
+		add(nquad(id, iri(rdfType), iri(securityVocab+method.Type)))
+		add(nquad(id, iri(securityVocab+"controller"), iri(method.Controller.String())))
+		if method.PublicKeyHex != "" {
+			add(nquad(id, iri(securityVocab+"publicKeyHex"), nquadLiteral(method.PublicKeyHex, "")))
+		}
+		if method.PublicKeyMultibase != "" {
+			add(nquad(id, iri(securityVocab+"publicKeyMultibase"), nquadLiteral(method.PublicKeyMultibase, securityVocab+"multibase")))
+		}
+		if method.PublicKeyJwk != nil {
+			// canonical JSON literal, members are sorted by name
+			members := map[string]string{
+				"kty": method.PublicKeyJwk.Kty,
+				"crv": method.PublicKeyJwk.Crv,
+				"x":   method.PublicKeyJwk.X,
+			}
+			if method.PublicKeyJwk.Y != "" {
+				members["y"] = method.PublicKeyJwk.Y
+			}
+			jwk, err := json.Marshal(members)
+			if err != nil {
+				return "", err
+			}
+			add(nquad(id, iri(securityVocab+"publicKeyJwk"), nquadLiteral(string(jwk), rdfJSON)))
+		}
+		if method.BlockchainAccountID != "" {
+			add(nquad(id, iri(securityVocab+"blockchainAccountId"), nquadLiteral(method.BlockchainAccountID, "")))
+		}
+	}
+
+	relationShips := []struct {
+		predicate string
+		didUrl    []MemoDIDUrl
+	}{
+		{securityVocab + "authenticationMethod", d.Authentication},
+		{securityVocab + "assertionMethod", d.AssertionMethod},
+		{securityVocab + "capabilityDelegationMethod", d.CapabilityDelegation},
+		{securityVocab + "capabilityInvocationMethod", d.CapabilityInvocation},
+		{securityVocab + "keyAgreementMethod", d.KeyAgreement},
+		{memoVocab + "recovery", d.Recovery},
+	}
+	for _, relationShip := range relationShips {
+		for _, didUrl := range relationShip.didUrl {
+			add(nquad(subject, iri(relationShip.predicate), iri(didUrl.String())))
+		}
+	}
+
+	if len(d.CapabilityDelegationExpiration) > 0 {
+		expiration, err := marshalJCS(d.CapabilityDelegationExpiration)
+		if err != nil {
+			return "", err
+		}
+		add(nquad(subject, iri(memoVocab+"capabilityDelegationExpiration"), nquadLiteral(string(expiration), rdfJSON)))
+	}
+
+	sort.Strings(quads)
+	return strings.Join(quads, ""), nil
+}
+
+func nquad(subject, predicate, object string) string {
+	return subject + " " + predicate + " " + object + " .\n"
+}
+
+func nquadLiteral(value string, datatype string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`).Replace(value)
+	if datatype == "" {
+		return `"` + escaped + `"`
+	}
+	return `"` + escaped + `"^^<` + datatype + `>`
+}
@@ -0,0 +1,130 @@
+package memodid
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMarshalJSONLD(t *testing.T) {
+	document, err := genDocument(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document.Authentication = []MemoDIDUrl{document.VerificationMethod[0].ID}
+
+	data, err := document.MarshalJSONLD()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	t.Log(string(data))
+
+	var raw map[string]interface{}
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	context, ok := raw["@context"].([]interface{})
+	if !ok || len(context) != 3 || context[0] != DefaultContext || context[1] != suiteContexts[EcdsaSecp256k1VerificationKey2019] {
+		t.Errorf("Unexpect @context: %v", raw["@context"])
+		return
+	}
+
+	var result MemoDIDDocument
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if result.Context != DefaultContext || len(result.VerificationMethod) != 1 {
+		t.Errorf("Unexpect document from json-ld: %v", result)
+		return
+	}
+
+	// legacy property name
+	legacy := strings.Replace(string(data), `"verificationMethod"`, `"verifycationMethod"`, 1)
+	result = MemoDIDDocument{}
+	err = json.Unmarshal([]byte(legacy), &result)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(result.VerificationMethod) != 1 {
+		t.Error("Legacy verifycationMethod should be accepted")
+	}
+}
+
+func TestNQuads(t *testing.T) {
+	document, err := genDocument(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	controller, err := genDocument(globalPrivateKey2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document.Controller = []MemoDID{controller.ID}
+	document.Authentication = []MemoDIDUrl{document.VerificationMethod[0].ID, controller.VerificationMethod[0].ID}
+	document.AssertionMethod = []MemoDIDUrl{document.VerificationMethod[0].ID}
+
+	nquads, err := document.NQuads()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	t.Log(nquads)
+
+	// moving a method to another relationship changes canonical form
+	moved := *document
+	moved.Authentication = []MemoDIDUrl{document.VerificationMethod[0].ID}
+	moved.AssertionMethod = []MemoDIDUrl{document.VerificationMethod[0].ID, controller.VerificationMethod[0].ID}
+	movedNQuads, err := moved.NQuads()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if movedNQuads == nquads {
+		t.Error("Canonical form should depend on relationships")
+		return
+	}
+
+	// order of methods in a relationship doesn't change canonical form
+	document.Authentication = []MemoDIDUrl{controller.VerificationMethod[0].ID, document.VerificationMethod[0].ID}
+	method, err := document.VerificationMethod[0].WithKeyFormat(KeyFormatJwk, nil)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	jwkDocument := *document
+	jwkDocument.VerificationMethod = []VerificationMethod{*method}
+
+	result, err := document.NQuads()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if result != nquads {
+		t.Error("Canonical form should be deterministic")
+		return
+	}
+
+	lines := strings.Split(strings.TrimSuffix(nquads, "\n"), "\n")
+	if len(lines) != 8 {
+		t.Errorf("Unexpect number of quads: %d", len(lines))
+		return
+	}
+
+	jwkNQuads, err := jwkDocument.NQuads()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !strings.Contains(jwkNQuads, `{\"crv\":\"secp256k1\",\"kty\":\"EC\"`) {
+		t.Errorf("Unexpect jwk literal: %s", jwkNQuads)
+	}
+}
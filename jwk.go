@@ -0,0 +1,112 @@
+package memodid
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/xerrors"
+)
+
+// JWK is a public key in JSON Web Key format(RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y,omitempty"`
+}
+
+// NewJWK converts public key of the verification method type to JWK
+func NewJWK(vtype string, publicKey []byte) (*JWK, error) {
+	if err := ValidatePublicKey(vtype, publicKey); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case vtype == EcdsaSecp256k1VerificationKey2019:
+		pk, err := crypto.DecompressPubkey(compressPublicKey(publicKey))
+		if err != nil {
+			return nil, err
+		}
+		return &JWK{
+			Kty: "EC",
+			Crv: "secp256k1",
+			X:   base64.RawURLEncoding.EncodeToString(pk.X.FillBytes(make([]byte, 32))),
+			Y:   base64.RawURLEncoding.EncodeToString(pk.Y.FillBytes(make([]byte, 32))),
+		}, nil
+	case isEd25519Type(vtype):
+		return &JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(publicKey),
+		}, nil
+	case isX25519Type(vtype):
+		return &JWK{
+			Kty: "OKP",
+			Crv: "X25519",
+			X:   base64.RawURLEncoding.EncodeToString(publicKey),
+		}, nil
+	default:
+		return nil, xerrors.Errorf("unsupported verification method type %s", vtype)
+	}
+}
+
+// PublicKeyBytes returns the raw public key, secp256k1 key is compressed
+func (k *JWK) PublicKeyBytes() ([]byte, error) {
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case k.Kty == "EC" && k.Crv == "secp256k1":
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, xerrors.Errorf("invalid secp256k1 jwk coordinates")
+		}
+		publicKey := append(append([]byte{4}, x...), y...)
+		pk, err := crypto.UnmarshalPubkey(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.CompressPubkey(pk), nil
+	case k.Kty == "OKP" && k.Crv == "Ed25519":
+		if len(x) != ed25519.PublicKeySize {
+			return nil, xerrors.Errorf("invalid Ed25519 jwk: %d bytes", len(x))
+		}
+		return x, nil
+	case k.Kty == "OKP" && k.Crv == "X25519":
+		if len(x) != curve25519.PointSize {
+			return nil, xerrors.Errorf("invalid X25519 jwk: %d bytes", len(x))
+		}
+		return x, nil
+	default:
+		return nil, xerrors.Errorf("unsupported jwk %s/%s", k.Kty, k.Crv)
+	}
+}
+
+// compressPublicKey compresses an uncompressed secp256k1 public key, other keys are returned as it is
+func compressPublicKey(publicKey []byte) []byte {
+	if len(publicKey) != 65 {
+		return publicKey
+	}
+	pk, err := crypto.UnmarshalPubkey(publicKey)
+	if err != nil {
+		return publicKey
+	}
+	return crypto.CompressPubkey(pk)
+}
+
+// blockchainAccountID returns CAIP-10 account id of a secp256k1 public key, e.g. eip155:985:0xab16...
+func blockchainAccountID(chainID *big.Int, publicKey []byte) (string, error) {
+	address, ok := publicKeyToAddress(publicKey)
+	if !ok {
+		return "", xerrors.Errorf("invalid secp256k1 public key: %d bytes", len(publicKey))
+	}
+	return "eip155:" + chainID.String() + ":" + address.Hex(), nil
+}
@@ -0,0 +1,95 @@
+package memodid
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestJWK(t *testing.T) {
+	sk, err := crypto.HexToECDSA(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	edpk, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	keys := map[string][]byte{
+		EcdsaSecp256k1VerificationKey2019: crypto.CompressPubkey(&sk.PublicKey),
+		Ed25519VerificationKey2020:        edpk,
+	}
+	for vtype, publicKey := range keys {
+		jwk, err := NewJWK(vtype, publicKey)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		data, err := json.Marshal(jwk)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		result, err := DecodePublicKey(string(data))
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if !bytes.Equal(result, publicKey) {
+			t.Errorf("Unexpect %s key from jwk: %x", vtype, result)
+			return
+		}
+	}
+}
+
+func TestKeyFormat(t *testing.T) {
+	document, err := genDocument(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	masterKey := &document.VerificationMethod[0]
+	publicKey, err := masterKey.PublicKeyBytes()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	for _, format := range []KeyFormat{KeyFormatHex, KeyFormatMultibase, KeyFormatJwk} {
+		method, err := masterKey.WithKeyFormat(format, nil)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		result, err := method.PublicKeyBytes()
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if !bytes.Equal(result, publicKey) {
+			t.Errorf("Unexpect key in %s: %x", format, result)
+			return
+		}
+	}
+
+	result, err := document.WithKeyFormat(KeyFormatBlockchainAccountID, big.NewInt(985))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if result.VerificationMethod[0].BlockchainAccountID != "eip155:985:0xe89971bfeEA7381d47fE608d676dfb5440F0fD2E" {
+		t.Errorf("Unexpect blockchainAccountId: %s", result.VerificationMethod[0].BlockchainAccountID)
+		return
+	}
+	if document.VerificationMethod[0].PublicKeyHex == "" {
+		t.Error("Original document should not be changed")
+	}
+}
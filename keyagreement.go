@@ -0,0 +1,228 @@
+package memodid
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/xerrors"
+)
+
+const (
+	jweAlgECDHES  = "ECDH-ES"
+	jweEncA256GCM = "A256GCM"
+)
+
+// EncryptedMessage is a JWE in flattened JSON serialization,
+// the content key is derived by ECDH-ES with X25519 and the content is encrypted by A256GCM
+type EncryptedMessage struct {
+	Protected  string `json:"protected"`
+	IV         string `json:"iv"`
+	Ciphertext string `json:"ciphertext"`
+	Tag        string `json:"tag"`
+}
+
+type jweHeader struct {
+	Alg string       `json:"alg"`
+	Enc string       `json:"enc"`
+	Kid string       `json:"kid,omitempty"`
+	Epk ephemeralKey `json:"epk"`
+}
+
+type ephemeralKey struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+}
+
+// GenerateX25519Key generates a key pair for key agreement
+func GenerateX25519Key() ([]byte, []byte, error) {
+	privateKey := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(privateKey); err != nil {
+		return nil, nil, err
+	}
+	publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	return privateKey, publicKey, nil
+}
+
+// Encrypt encrypts plaintext to recipient, which is a DID or a DID URL of a keyAgreement method.
+// The recipient key is looked up by resolving the DID, the first keyAgreement method is used if recipient is a DID.
+func Encrypt(resolver DIDResolver, recipient string, plaintext []byte) (*EncryptedMessage, error) {
+	var did MemoDID
+	var didUrl *MemoDIDUrl
+	if strings.Contains(recipient, "#") {
+		u, err := ParseDIDUrl(recipient)
+		if err != nil {
+			return nil, err
+		}
+		did, didUrl = u.DID(), u
+	} else {
+		d, err := ParseDID(recipient)
+		if err != nil {
+			return nil, err
+		}
+		did = *d
+	}
+
+	document, err := resolver.Resolve(did.String())
+	if err != nil {
+		return nil, err
+	}
+	if isDeactivatedDocument(document) {
+		return nil, xerrors.Errorf("%s: %w", did.String(), ErrDeactivated)
+	}
+
+	for _, id := range document.KeyAgreement {
+		if didUrl != nil && id.String() != didUrl.String() {
+			continue
+		}
+		method := findVerificationMethod(document, id)
+		if method == nil {
+			continue
+		}
+		return EncryptTo(method, plaintext)
+	}
+
+	return nil, xerrors.Errorf("%s has no key agreement method", recipient)
+}
+
+// EncryptTo encrypts plaintext to an x25519 verification method
+func EncryptTo(method *VerificationMethod, plaintext []byte) (*EncryptedMessage, error) {
+	if !isX25519Type(method.Type) {
+		return nil, xerrors.Errorf("%s(%s) can't be used for key agreement", method.ID.String(), method.Type)
+	}
+	publicKey, err := method.PublicKeyBytes()
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidatePublicKey(method.Type, publicKey); err != nil {
+		return nil, err
+	}
+
+	ephemeralPrivateKey, ephemeralPublicKey, err := GenerateX25519Key()
+	if err != nil {
+		return nil, err
+	}
+	z, err := curve25519.X25519(ephemeralPrivateKey, publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := json.Marshal(jweHeader{
+		Alg: jweAlgECDHES,
+		Enc: jweEncA256GCM,
+		Kid: method.ID.String(),
+		Epk: ephemeralKey{
+			Kty: "OKP",
+			Crv: "X25519",
+			X:   base64.RawURLEncoding.EncodeToString(ephemeralPublicKey),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(header)
+
+	gcm, err := newContentCipher(z)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	sealed := gcm.Seal(nil, iv, plaintext, []byte(protected))
+	tagStart := len(sealed) - gcm.Overhead()
+
+	return &EncryptedMessage{
+		Protected:  protected,
+		IV:         base64.RawURLEncoding.EncodeToString(iv),
+		Ciphertext: base64.RawURLEncoding.EncodeToString(sealed[:tagStart]),
+		Tag:        base64.RawURLEncoding.EncodeToString(sealed[tagStart:]),
+	}, nil
+}
+
+// Decrypt decrypts msg with the x25519 private key of the recipient keyAgreement method
+func Decrypt(privateKey []byte, msg *EncryptedMessage) ([]byte, error) {
+	data, err := base64.RawURLEncoding.DecodeString(msg.Protected)
+	if err != nil {
+		return nil, err
+	}
+	var header jweHeader
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != jweAlgECDHES || header.Enc != jweEncA256GCM {
+		return nil, xerrors.Errorf("unsupported jwe algorithm %s/%s", header.Alg, header.Enc)
+	}
+	if header.Epk.Kty != "OKP" || header.Epk.Crv != "X25519" {
+		return nil, xerrors.Errorf("unsupported ephemeral key %s/%s", header.Epk.Kty, header.Epk.Crv)
+	}
+
+	ephemeralPublicKey, err := base64.RawURLEncoding.DecodeString(header.Epk.X)
+	if err != nil {
+		return nil, err
+	}
+	z, err := curve25519.X25519(privateKey, ephemeralPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	iv, err := base64.RawURLEncoding.DecodeString(msg.IV)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := base64.RawURLEncoding.DecodeString(msg.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := base64.RawURLEncoding.DecodeString(msg.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newContentCipher(z)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != gcm.NonceSize() {
+		return nil, xerrors.Errorf("invalid iv length %d", len(iv))
+	}
+
+	return gcm.Open(nil, iv, append(ciphertext, tag...), []byte(msg.Protected))
+}
+
+// newContentCipher derives the A256GCM content key from shared secret z
+func newContentCipher(z []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(concatKDF(z, jweEncA256GCM, 32))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// concatKDF is the Concat KDF of NIST SP 800-56A used by ECDH-ES(RFC 7518 section 4.6),
+// with empty PartyUInfo and PartyVInfo; keyLen must not exceed the sha256 output size
+func concatKDF(z []byte, algID string, keyLen int) []byte {
+	var otherInfo []byte
+	otherInfo = binary.BigEndian.AppendUint32(otherInfo, uint32(len(algID)))
+	otherInfo = append(otherInfo, algID...)
+	otherInfo = binary.BigEndian.AppendUint32(otherInfo, 0) // PartyUInfo
+	otherInfo = binary.BigEndian.AppendUint32(otherInfo, 0) // PartyVInfo
+	otherInfo = binary.BigEndian.AppendUint32(otherInfo, uint32(keyLen*8))
+
+	h := sha256.New()
+	h.Write([]byte{0, 0, 0, 1})
+	h.Write(z)
+	h.Write(otherInfo)
+	return h.Sum(nil)[:keyLen]
+}
@@ -0,0 +1,75 @@
+package memodid
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/memoio/did-solidity/go-contracts/proxy"
+)
+
+func TestEncryptAndDecrypt(t *testing.T) {
+	document, err := genDocument(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	privateKey, publicKey, err := GenerateX25519Key()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	method, err := FromSolityData(&document.ID, 1, &proxy.IAccountDidPublicKey{
+		Controller: document.ID.Identifier,
+		MethodType: X25519KeyAgreementKey2020,
+		PubKeyData: publicKey,
+	})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document.VerificationMethod = append(document.VerificationMethod, *method)
+	document.KeyAgreement = queryAllKeyAgreement(document.VerificationMethod)
+	if len(document.KeyAgreement) != 1 {
+		t.Errorf("Unexpect keyAgreement: %v", document.KeyAgreement)
+		return
+	}
+
+	resolver := mockResolver{}
+	resolver.add(document)
+
+	plaintext := []byte("hello memo")
+	msg, err := Encrypt(resolver, document.ID.String(), plaintext)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	result, err := Decrypt(privateKey, msg)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !bytes.Equal(result, plaintext) {
+		t.Errorf("Unexpect plaintext: %s", result)
+		return
+	}
+
+	// wrong key
+	otherKey, _, err := GenerateX25519Key()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	_, err = Decrypt(otherKey, msg)
+	if err == nil {
+		t.Error("Decrypting with another key should report an error")
+		return
+	}
+
+	// masterKey is not a keyAgreement method
+	_, err = Encrypt(resolver, document.VerificationMethod[0].ID.String(), plaintext)
+	if err == nil {
+		t.Error("Encrypting to a non keyAgreement method should report an error")
+	}
+}
@@ -0,0 +1,282 @@
+package memodid
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/xerrors"
+)
+
+// verification method types
+const (
+	EcdsaSecp256k1VerificationKey2019 = "EcdsaSecp256k1VerificationKey2019"
+	EcdsaSecp256k1RecoveryMethod2020  = "EcdsaSecp256k1RecoveryMethod2020"
+	Ed25519VerificationKey2018        = "Ed25519VerificationKey2018"
+	Ed25519VerificationKey2020        = "Ed25519VerificationKey2020"
+	X25519KeyAgreementKey2019         = "X25519KeyAgreementKey2019"
+	X25519KeyAgreementKey2020         = "X25519KeyAgreementKey2020"
+)
+
+// KeyFormat is the representation of public key in verification method
+type KeyFormat string
+
+const (
+	// hex for secp256k1 keys, multibase for ed25519 and x25519 keys
+	KeyFormatDefault             KeyFormat = ""
+	KeyFormatHex                 KeyFormat = "publicKeyHex"
+	KeyFormatMultibase           KeyFormat = "publicKeyMultibase"
+	KeyFormatJwk                 KeyFormat = "publicKeyJwk"
+	KeyFormatBlockchainAccountID KeyFormat = "blockchainAccountId"
+)
+
+// multicodec prefix of ed25519-pub and x25519-pub
+var (
+	ed25519MulticodecPrefix = []byte{0xed, 0x01}
+	x25519MulticodecPrefix  = []byte{0xec, 0x01}
+)
+
+// ValidatePublicKey checks the key material of a verification method,
+// keys of types unknown to the package are not checked
+func ValidatePublicKey(vtype string, publicKey []byte) error {
+	switch vtype {
+	case EcdsaSecp256k1VerificationKey2019:
+		if _, ok := publicKeyToAddress(publicKey); !ok {
+			return xerrors.Errorf("invalid %s public key: %d bytes", vtype, len(publicKey))
+		}
+	case Ed25519VerificationKey2018, Ed25519VerificationKey2020:
+		if len(publicKey) != ed25519.PublicKeySize {
+			return xerrors.Errorf("invalid %s public key: %d bytes", vtype, len(publicKey))
+		}
+	case X25519KeyAgreementKey2019, X25519KeyAgreementKey2020:
+		if len(publicKey) != curve25519.PointSize {
+			return xerrors.Errorf("invalid %s public key: %d bytes", vtype, len(publicKey))
+		}
+	}
+	return nil
+}
+
+// Sign signs msg with privateKey of the verification method type.
+// secp256k1 key(*ecdsa.PrivateKey) signs keccak256(msg) and returns [R || S || V];
+// ed25519 key(ed25519.PrivateKey) signs msg directly.
+func Sign(vtype string, privateKey interface{}, msg []byte) ([]byte, error) {
+	switch vtype {
+	case EcdsaSecp256k1VerificationKey2019:
+		sk, ok := privateKey.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, xerrors.Errorf("cannot assert type: privateKey is not of type *ecdsa.PrivateKey")
+		}
+		return crypto.Sign(crypto.Keccak256(msg), sk)
+	case Ed25519VerificationKey2018, Ed25519VerificationKey2020:
+		sk, ok := privateKey.(ed25519.PrivateKey)
+		if !ok {
+			return nil, xerrors.Errorf("cannot assert type: privateKey is not of type ed25519.PrivateKey")
+		}
+		return ed25519.Sign(sk, msg), nil
+	default:
+		return nil, xerrors.Errorf("unsupported verification method type %s", vtype)
+	}
+}
+
+// VerifySignature checks sig over msg with publicKey of the verification method type
+func VerifySignature(vtype string, publicKey, msg, sig []byte) (bool, error) {
+	if err := ValidatePublicKey(vtype, publicKey); err != nil {
+		return false, err
+	}
+
+	switch vtype {
+	case EcdsaSecp256k1VerificationKey2019:
+		// [R || S || V] or [R || S]
+		if len(sig) != 64 && len(sig) != 65 {
+			return false, nil
+		}
+		return crypto.VerifySignature(publicKey, crypto.Keccak256(msg), sig[:64]), nil
+	case Ed25519VerificationKey2018, Ed25519VerificationKey2020:
+		return ed25519.Verify(ed25519.PublicKey(publicKey), msg, sig), nil
+	default:
+		return false, xerrors.Errorf("%s can't be used to verify signature", vtype)
+	}
+}
+
+// DecodePublicKey decodes public key in any of the supported encodings:
+// hex with or without 0x prefix, base58btc multibase or JWK in JSON
+func DecodePublicKey(encoded string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(encoded, "{"):
+		var jwk JWK
+		if err := json.Unmarshal([]byte(encoded), &jwk); err != nil {
+			return nil, err
+		}
+		return jwk.PublicKeyBytes()
+	case strings.HasPrefix(encoded, "z"):
+		return decodeMultibaseKey(encoded)
+	case strings.HasPrefix(encoded, "eip155:"):
+		return nil, xerrors.Errorf("blockchainAccountId %s doesn't contain public key", encoded)
+	default:
+		return decodeHexKey(encoded)
+	}
+}
+
+// PublicKeyBytes returns the raw key material of the verification method
+func (v *VerificationMethod) PublicKeyBytes() ([]byte, error) {
+	switch {
+	case v.PublicKeyMultibase != "":
+		return decodeMultibaseKey(v.PublicKeyMultibase)
+	case v.PublicKeyJwk != nil:
+		return v.PublicKeyJwk.PublicKeyBytes()
+	case v.PublicKeyHex != "":
+		return decodeHexKey(v.PublicKeyHex)
+	default:
+		return nil, xerrors.Errorf("verification method %s has no public key", v.ID.String())
+	}
+}
+
+// WithKeyFormat returns a copy of the verification method whose public key is represented in format.
+// chainID is needed by blockchainAccountId, which is only supported by secp256k1 keys;
+// other keys keep the default format in that case.
+func (v *VerificationMethod) WithKeyFormat(format KeyFormat, chainID *big.Int) (*VerificationMethod, error) {
+	publicKey, err := v.PublicKeyBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	method := &VerificationMethod{
+		ID:         v.ID,
+		Controller: v.Controller,
+		Type:       v.Type,
+	}
+	if format == KeyFormatBlockchainAccountID && v.Type != EcdsaSecp256k1VerificationKey2019 {
+		format = KeyFormatDefault
+	}
+	if format == KeyFormatDefault {
+		if isMultibaseType(v.Type) {
+			format = KeyFormatMultibase
+		} else {
+			format = KeyFormatHex
+		}
+	}
+
+	switch format {
+	case KeyFormatHex:
+		method.PublicKeyHex = hexutil.Encode(publicKey)
+	case KeyFormatMultibase:
+		method.PublicKeyMultibase = encodeMultibaseKey(v.Type, publicKey)
+	case KeyFormatJwk:
+		method.PublicKeyJwk, err = NewJWK(v.Type, publicKey)
+		if err != nil {
+			return nil, err
+		}
+	case KeyFormatBlockchainAccountID:
+		if chainID == nil {
+			return nil, xerrors.Errorf("chain id is required by blockchainAccountId")
+		}
+		method.BlockchainAccountID, err = blockchainAccountID(chainID, publicKey)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, xerrors.Errorf("unsupported key format %s", format)
+	}
+
+	return method, nil
+}
+
+// Verify checks sig over msg with the verification method's public key
+func (v *VerificationMethod) Verify(msg, sig []byte) (bool, error) {
+	publicKey, err := v.PublicKeyBytes()
+	if err != nil {
+		return false, err
+	}
+	return VerifySignature(v.Type, publicKey, msg, sig)
+}
+
+func isEd25519Type(vtype string) bool {
+	return vtype == Ed25519VerificationKey2018 || vtype == Ed25519VerificationKey2020
+}
+
+func isX25519Type(vtype string) bool {
+	return vtype == X25519KeyAgreementKey2019 || vtype == X25519KeyAgreementKey2020
+}
+
+// isMultibaseType reports whether keys of vtype are represented as publicKeyMultibase
+func isMultibaseType(vtype string) bool {
+	return isEd25519Type(vtype) || isX25519Type(vtype)
+}
+
+// encodeMultibaseKey encodes public key as base58btc multibase,
+// 2020 suite keys are prefixed by multicodec ed25519-pub or x25519-pub
+func encodeMultibaseKey(vtype string, publicKey []byte) string {
+	switch vtype {
+	case Ed25519VerificationKey2020:
+		publicKey = append(append([]byte{}, ed25519MulticodecPrefix...), publicKey...)
+	case X25519KeyAgreementKey2020:
+		publicKey = append(append([]byte{}, x25519MulticodecPrefix...), publicKey...)
+	}
+	return "z" + encodeBase58(publicKey)
+}
+
+func decodeMultibaseKey(s string) ([]byte, error) {
+	if len(s) < 2 || s[0] != 'z' {
+		return nil, xerrors.Errorf("unsupported multibase encoding: %s", s)
+	}
+	data, err := decodeBase58(s[1:])
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == ed25519.PublicKeySize+len(ed25519MulticodecPrefix) &&
+		(bytes.HasPrefix(data, ed25519MulticodecPrefix) || bytes.HasPrefix(data, x25519MulticodecPrefix)) {
+		data = data[len(ed25519MulticodecPrefix):]
+	}
+	return data, nil
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+func encodeBase58(data []byte) string {
+	x := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var result []byte
+	for x.Sign() > 0 {
+		x.DivMod(x, radix, mod)
+		result = append(result, base58Alphabet[mod.Int64()])
+	}
+	// leading zero bytes
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		result = append(result, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return string(result)
+}
+
+func decodeBase58(s string) ([]byte, error) {
+	x := new(big.Int)
+	radix := big.NewInt(58)
+	for _, c := range []byte(s) {
+		i := bytes.IndexByte([]byte(base58Alphabet), c)
+		if i < 0 {
+			return nil, xerrors.Errorf("invalid base58 character %q", c)
+		}
+		x.Mul(x, radix)
+		x.Add(x, big.NewInt(int64(i)))
+	}
+
+	var zeros int
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	return append(make([]byte, zeros), x.Bytes()...), nil
+}
@@ -0,0 +1,120 @@
+package memodid
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/memoio/did-solidity/go-contracts/proxy"
+)
+
+func TestBase58(t *testing.T) {
+	if s := encodeBase58([]byte("Hello World!")); s != "2NEpo7TZRRrLZSi2U" {
+		t.Errorf("Unexpect base58 encoding: %s", s)
+		return
+	}
+
+	data := []byte{0, 0, 1, 2, 3}
+	result, err := decodeBase58(encodeBase58(data))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !bytes.Equal(result, data) {
+		t.Errorf("Unexpect base58 decoding: %x", result)
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	msg := []byte("hello")
+
+	sk, err := crypto.HexToECDSA(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	sig, err := Sign(EcdsaSecp256k1VerificationKey2019, sk, msg)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	ok, err := VerifySignature(EcdsaSecp256k1VerificationKey2019, crypto.CompressPubkey(&sk.PublicKey), msg, sig)
+	if err != nil || !ok {
+		t.Error("Can't verify secp256k1 signature")
+		return
+	}
+
+	pk, edsk, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	sig, err = Sign(Ed25519VerificationKey2020, edsk, msg)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	ok, err = VerifySignature(Ed25519VerificationKey2020, pk, msg, sig)
+	if err != nil || !ok {
+		t.Error("Can't verify ed25519 signature")
+		return
+	}
+	ok, _ = VerifySignature(Ed25519VerificationKey2020, pk, []byte("world"), sig)
+	if ok {
+		t.Error("Signature of another message should not be verified")
+		return
+	}
+
+	_, err = Sign(Ed25519VerificationKey2018, sk, msg)
+	if err == nil {
+		t.Error("Signing with mismatched key type should report an error")
+	}
+}
+
+func TestEd25519VerificationMethod(t *testing.T) {
+	_, did, err := CreatSimpleDID(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	pk, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	for _, vtype := range []string{Ed25519VerificationKey2018, Ed25519VerificationKey2020} {
+		method, err := FromSolityData(did, 1, &proxy.IAccountDidPublicKey{
+			Controller: did.Identifier,
+			MethodType: vtype,
+			PubKeyData: pk,
+		})
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if method.PublicKeyHex != "" || method.PublicKeyMultibase == "" {
+			t.Errorf("%s key should be represented as multibase", vtype)
+			return
+		}
+
+		data, err := ToSolidityData(method)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if !bytes.Equal(data.PubKeyData, pk) {
+			t.Errorf("Unexpect %s key: %x", vtype, data.PubKeyData)
+			return
+		}
+	}
+
+	if err := ValidatePublicKey(Ed25519VerificationKey2020, pk[:31]); err == nil {
+		t.Error("Validating a 31 byte ed25519 key should report an error")
+	}
+	// keys of other types are passed through as before
+	if err := ValidatePublicKey("JsonWebKey2020", []byte("key")); err != nil {
+		t.Error(err.Error())
+	}
+}
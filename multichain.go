@@ -0,0 +1,135 @@
+package memodid
+
+import (
+	"errors"
+	"math/big"
+
+	"golang.org/x/xerrors"
+)
+
+// chainResolver resolves memo dids on a chain, it is implemented by MemoDIDResolver
+type chainResolver interface {
+	ChainID() *big.Int
+	ResolveWithMetadata(didString string) (*ResolutionResult, error)
+	Dereference(didUrlString string) (string, string, error)
+}
+
+var _ chainResolver = &MemoDIDResolver{}
+
+// MultiChainResolver resolves memo dids registered on several chains.
+// A did with chain segment is resolved on its chain, the others are probed on the chains
+// in priority order until one of them has the did.
+type MultiChainResolver struct {
+	resolvers []chainResolver
+}
+
+var _ DIDResolver = &MultiChainResolver{}
+
+// NewMultiChainResolver makes a resolver of chains, the former config has higher priority
+func NewMultiChainResolver(configs ...*ChainConfig) (*MultiChainResolver, error) {
+	resolvers := make([]chainResolver, 0, len(configs))
+	for _, config := range configs {
+		resolver, err := NewMemoDIDResolverWithConfig(config)
+		if err != nil {
+			return nil, xerrors.Errorf("chain %s: %w", config.Endpoint, err)
+		}
+		resolvers = append(resolvers, resolver)
+	}
+	return newMultiChainResolver(resolvers)
+}
+
+func newMultiChainResolver(resolvers []chainResolver) (*MultiChainResolver, error) {
+	if len(resolvers) == 0 {
+		return nil, xerrors.Errorf("no chain to resolve dids")
+	}
+	seen := make(map[string]bool)
+	for _, resolver := range resolvers {
+		chainID := resolver.ChainID().String()
+		if seen[chainID] {
+			return nil, xerrors.Errorf("chain %s is configured more than once", chainID)
+		}
+		seen[chainID] = true
+	}
+	return &MultiChainResolver{resolvers: resolvers}, nil
+}
+
+func (r *MultiChainResolver) Resolve(didString string) (*MemoDIDDocument, error) {
+	result, err := r.ResolveWithMetadata(didString)
+	if err != nil {
+		return nil, err
+	}
+	return result.Document, nil
+}
+
+// ResolveWithMetadata resolves did, the chain which answers is in ResolutionMetadata.ChainID
+func (r *MultiChainResolver) ResolveWithMetadata(didString string) (*ResolutionResult, error) {
+	did, err := ParseMemoDID(didString)
+	if err != nil {
+		return nil, err
+	}
+	if did.ChainID != "" {
+		resolver, err := r.chain(did.ChainID)
+		if err != nil {
+			return nil, err
+		}
+		return resolver.ResolveWithMetadata(didString)
+	}
+
+	var result *ResolutionResult
+	err = r.probe(didString, func(resolver chainResolver) error {
+		var err error
+		result, err = resolver.ResolveWithMetadata(didString)
+		return err
+	})
+	return result, err
+}
+
+func (r *MultiChainResolver) Dereference(didUrlString string) (string, string, error) {
+	didUrl, err := ParseMemoDIDUrl(didUrlString)
+	if err != nil {
+		return "", "", err
+	}
+	if didUrl.ChainID != "" {
+		resolver, err := r.chain(didUrl.ChainID)
+		if err != nil {
+			return "", "", err
+		}
+		return resolver.Dereference(didUrlString)
+	}
+
+	var methodType, publicKey string
+	err = r.probe(didUrlString, func(resolver chainResolver) error {
+		var err error
+		methodType, publicKey, err = resolver.Dereference(didUrlString)
+		return err
+	})
+	return methodType, publicKey, err
+}
+
+func (r *MultiChainResolver) chain(chainID string) (chainResolver, error) {
+	for _, resolver := range r.resolvers {
+		if resolver.ChainID().String() == chainID {
+			return resolver, nil
+		}
+	}
+	return nil, xerrors.Errorf("chain %s isn't configured: %w", chainID, ErrNotFound)
+}
+
+// probe calls resolve on chains in order until one of them has the id.
+// Chains which fail, such as unreachable ones, are skipped, and the first failure is returned if no chain has the id.
+func (r *MultiChainResolver) probe(id string, resolve func(resolver chainResolver) error) error {
+	var failed error
+	for _, resolver := range r.resolvers {
+		err := resolve(resolver)
+		if err == nil || errors.Is(err, ErrDeactivated) {
+			return err
+		}
+		if !errors.Is(err, ErrNotFound) && failed == nil {
+			failed = xerrors.Errorf("chain %s: %w", resolver.ChainID(), err)
+		}
+	}
+	if failed != nil {
+		return failed
+	}
+	return xerrors.Errorf("%s on all chains: %w", id, ErrNotFound)
+}
@@ -0,0 +1,119 @@
+package memodid
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/xerrors"
+)
+
+type mockChainResolver struct {
+	chainID   int64
+	documents map[string]*MemoDIDDocument
+	err       error
+	calls     int
+}
+
+func (r *mockChainResolver) ChainID() *big.Int {
+	return big.NewInt(r.chainID)
+}
+
+func (r *mockChainResolver) ResolveWithMetadata(didString string) (*ResolutionResult, error) {
+	r.calls++
+	if r.err != nil {
+		return nil, r.err
+	}
+	did, err := ParseMemoDID(didString)
+	if err != nil {
+		return nil, err
+	}
+	document, ok := r.documents[did.Identifier]
+	if !ok {
+		return nil, xerrors.Errorf("%s: %w", didString, ErrNotFound)
+	}
+	return &ResolutionResult{
+		Document:           document,
+		ResolutionMetadata: ResolutionMetadata{ChainID: r.ChainID().String()},
+	}, nil
+}
+
+func (r *mockChainResolver) Dereference(didUrlString string) (string, string, error) {
+	r.calls++
+	if r.err != nil {
+		return "", "", r.err
+	}
+	didUrl, err := ParseMemoDIDUrl(didUrlString)
+	if err != nil {
+		return "", "", err
+	}
+	if _, ok := r.documents[didUrl.Identifier]; !ok {
+		return "", "", xerrors.Errorf("%s: %w", didUrlString, ErrNotFound)
+	}
+	return "EcdsaSecp256k1VerificationKey2019", r.ChainID().String(), nil
+}
+
+func TestMultiChainResolver(t *testing.T) {
+	identifier := hex.EncodeToString(crypto.Keccak256([]byte("hello")))
+	did, err := ParseMemoDID("did:memo:" + identifier)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	down := &mockChainResolver{chainID: 1, err: xerrors.New("connection refused")}
+	empty := &mockChainResolver{chainID: 2}
+	registered := &mockChainResolver{chainID: 3, documents: map[string]*MemoDIDDocument{identifier: {ID: *did}}}
+	resolver, err := newMultiChainResolver([]chainResolver{down, empty, registered})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// probe in order
+	result, err := resolver.ResolveWithMetadata(did.String())
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if result.ResolutionMetadata.ChainID != "3" || down.calls != 1 || empty.calls != 1 {
+		t.Errorf("Unexpect chain %s", result.ResolutionMetadata.ChainID)
+		return
+	}
+	_, publicKey, err := resolver.Dereference(did.String() + "#masterKey")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if publicKey != "3" {
+		t.Errorf("Dereferenced on chain %s", publicKey)
+		return
+	}
+
+	// route by chain segment
+	_, err = resolver.Resolve("did:memo:2:" + identifier)
+	if !errors.Is(err, ErrNotFound) || registered.calls != 2 {
+		t.Errorf("Did should be resolved on chain 2 only: %v", err)
+		return
+	}
+	_, err = resolver.Resolve("did:memo:4:" + identifier)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Unconfigured chain should not be found: %v", err)
+		return
+	}
+
+	// failure of chains is reported if no chain has the did
+	other := hex.EncodeToString(crypto.Keccak256([]byte("other")))
+	_, err = resolver.Resolve("did:memo:" + other)
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Failure of chain 1 should be reported: %v", err)
+		return
+	}
+
+	_, err = newMultiChainResolver([]chainResolver{empty, &mockChainResolver{chainID: 2}})
+	if err == nil {
+		t.Error("Duplicate chains should be rejected")
+	}
+}
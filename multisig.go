@@ -0,0 +1,476 @@
+package memodid
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"golang.org/x/xerrors"
+)
+
+// MultisigPolicy requires approvals of Threshold(M) of the N Signers before a document change of DID is executed.
+// AccountDid contract accepts transactions of any single controller, so the policy is enforced off chain:
+// the did should be controlled on chain only by the executor, which runs approved proposals with the policies
+// it is configured with and refuses other changes of DID. Signers are chosen by the owner of the did, and an
+// approval only counts while its signer is also a controller of the did on chain.
+type MultisigPolicy struct {
+	DID       MemoDID   `json:"did"`
+	Signers   []MemoDID `json:"signers"`
+	Threshold int       `json:"threshold"`
+}
+
+// NewMultisigPolicy returns the policy that requires threshold of signers to change did
+func NewMultisigPolicy(did MemoDID, signers []MemoDID, threshold int) (*MultisigPolicy, error) {
+	policy := &MultisigPolicy{
+		DID:       did,
+		Signers:   append([]MemoDID(nil), signers...),
+		Threshold: threshold,
+	}
+	return policy, policy.validate()
+}
+
+// LoadMultisigPolicyFile loads a policy saved as json, e.g. by the owner of the did
+func LoadMultisigPolicyFile(path string) (*MultisigPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := &MultisigPolicy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, xerrors.Errorf("%s: %w", path, err)
+	}
+	return policy, policy.validate()
+}
+
+func (p *MultisigPolicy) validate() error {
+	vd := &validator{}
+	vd.validateDID("did", p.DID)
+	seen := make(map[string]bool)
+	for i, signer := range p.Signers {
+		path := fmt.Sprintf("signers[%d]", i)
+		vd.validateDID(path, signer)
+		if seen[strings.ToLower(signer.Identifier)] {
+			vd.add(path, "duplicate signer %s", signer.String())
+		}
+		seen[strings.ToLower(signer.Identifier)] = true
+	}
+	if err := vd.err(); err != nil {
+		return err
+	}
+
+	if p.Threshold <= 0 || p.Threshold > len(p.Signers) {
+		return xerrors.Errorf("invalid threshold %d of %d signers", p.Threshold, len(p.Signers))
+	}
+	return nil
+}
+
+// Digest returns the hex encoded sha256 hash of the canonical JSON of the policy,
+// which doesn't depend on the order of signers
+func (p *MultisigPolicy) Digest() (string, error) {
+	policy := *p
+	policy.DID = p.DID.withoutChain()
+	policy.Signers = make([]MemoDID, 0, len(p.Signers))
+	for _, signer := range p.Signers {
+		policy.Signers = append(policy.Signers, signer.withoutChain())
+	}
+	sort.Slice(policy.Signers, func(i, j int) bool {
+		return strings.ToLower(policy.Signers[i].Identifier) < strings.ToLower(policy.Signers[j].Identifier)
+	})
+
+	data, err := marshalJCS(&policy)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return hexutil.Encode(hash[:]), nil
+}
+
+// isControllerOf reports whether did is a controller of document
+func isControllerOf(document *MemoDIDDocument, did MemoDID) bool {
+	for _, controller := range document.Controller {
+		if strings.EqualFold(controller.Identifier, did.Identifier) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *MultisigPolicy) isSigner(did MemoDID) bool {
+	for _, signer := range p.Signers {
+		if strings.EqualFold(signer.Identifier, did.Identifier) {
+			return true
+		}
+	}
+	return false
+}
+
+// Proposal is a proposed version of a did document, which is executed after enough approvals.
+// The changes are computed against the document that the proposal is based on.
+type Proposal struct {
+	DID      MemoDID         `json:"did"`
+	Document MemoDIDDocument `json:"document"`
+	// digest of the document that the proposal is based on, the proposal is stale once the document changes
+	BaseDigest string `json:"baseDigest"`
+	// digest of the policy that the proposal is approved under, so signers approve the threshold too
+	PolicyDigest string `json:"policyDigest"`
+	// unix time
+	CreatedAt int64 `json:"createdAt"`
+
+	Approvals []Approval `json:"approvals,omitempty"`
+}
+
+// Approval is a signature over the proposal by a method of a signer DID,
+// the method must be the masterKey, an authentication or a capabilityInvocation method
+type Approval struct {
+	Signer    MemoDIDUrl `json:"signer"`
+	Signature string     `json:"signature"`
+}
+
+// NewProposal proposes to change current into proposed under policy
+func NewProposal(current *MemoDIDDocument, proposed *MemoDIDDocument, policy *MultisigPolicy) (*Proposal, error) {
+	if isDeactivatedDocument(current) {
+		return nil, xerrors.Errorf("did can't be changed: %w", ErrDeactivated)
+	}
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(policy.DID.Identifier, current.ID.Identifier) {
+		return nil, xerrors.Errorf("policy of %s can't change %s", policy.DID.String(), current.ID.String())
+	}
+	policyDigest, err := policy.Digest()
+	if err != nil {
+		return nil, err
+	}
+	cs, err := Diff(current, proposed)
+	if err != nil {
+		return nil, err
+	}
+	if cs.IsEmpty() {
+		return nil, xerrors.Errorf("proposal has no change")
+	}
+	if err := proposed.Validate(); err != nil {
+		return nil, err
+	}
+
+	digest, err := current.Digest()
+	if err != nil {
+		return nil, err
+	}
+	document := proposed.clone()
+	document.Normalize()
+
+	return &Proposal{
+		DID:          current.ID,
+		Document:     *document,
+		BaseDigest:   hexutil.Encode(digest),
+		PolicyDigest: policyDigest,
+		CreatedAt:    time.Now().Unix(),
+	}, nil
+}
+
+// SigningPayload returns the canonical JSON of the proposal without approvals
+func (p *Proposal) SigningPayload() ([]byte, error) {
+	proposal := *p
+	proposal.Approvals = nil
+	return marshalJCS(&proposal)
+}
+
+// ID returns the hex encoded sha256 hash of the signing payload
+func (p *Proposal) ID() (string, error) {
+	payload, err := p.SigningPayload()
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(payload)
+	return hexutil.Encode(hash[:]), nil
+}
+
+// Changes returns the changes from the base document to the proposed document
+func (p *Proposal) Changes(base *MemoDIDDocument) (*ChangeSet, error) {
+	if err := p.checkBase(base); err != nil {
+		return nil, err
+	}
+	return Diff(base, &p.Document)
+}
+
+// Sign adds the approval of signer
+func (p *Proposal) Sign(signer MemoDIDUrl, vtype string, privateKey interface{}) error {
+	payload, err := p.SigningPayload()
+	if err != nil {
+		return err
+	}
+	sig, err := Sign(vtype, privateKey, payload)
+	if err != nil {
+		return err
+	}
+	p.addApproval(Approval{Signer: signer, Signature: hexutil.Encode(sig)})
+	return nil
+}
+
+// Merge collects approvals of the same proposal signed separately
+func (p *Proposal) Merge(other *Proposal) error {
+	id, err := p.ID()
+	if err != nil {
+		return err
+	}
+	otherID, err := other.ID()
+	if err != nil {
+		return err
+	}
+	if id != otherID {
+		return xerrors.Errorf("can't merge approvals of different proposals %s, %s", id, otherID)
+	}
+	for _, approval := range other.Approvals {
+		p.addApproval(approval)
+	}
+	return nil
+}
+
+func (p *Proposal) addApproval(approval Approval) {
+	for i := range p.Approvals {
+		if sameDIDUrl(p.Approvals[i].Signer, approval.Signer) {
+			p.Approvals[i] = approval
+			return
+		}
+	}
+	p.Approvals = append(p.Approvals, approval)
+}
+
+func (p *Proposal) checkBase(base *MemoDIDDocument) error {
+	if base.ID.String() != p.DID.String() {
+		return xerrors.Errorf("proposal of %s can't change %s", p.DID.String(), base.ID.String())
+	}
+	digest, err := base.Digest()
+	if err != nil {
+		return err
+	}
+	if hexutil.Encode(digest) != p.BaseDigest {
+		return xerrors.Errorf("%s has been changed since the proposal is created", p.DID.String())
+	}
+	return nil
+}
+
+// VerifyProposal checks that proposal is based on current and approved by policy.Threshold distinct signers,
+// it returns the signer DIDs with valid approvals. policy should come from a trusted source rather than
+// the proposer, the proposal must be created under the same policy.
+func VerifyProposal(resolver DIDResolver, policy *MultisigPolicy, current *MemoDIDDocument, proposal *Proposal) ([]MemoDID, error) {
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(policy.DID.Identifier, current.ID.Identifier) {
+		return nil, xerrors.Errorf("policy of %s can't change %s", policy.DID.String(), current.ID.String())
+	}
+	policyDigest, err := policy.Digest()
+	if err != nil {
+		return nil, err
+	}
+	if proposal.PolicyDigest != policyDigest {
+		return nil, xerrors.Errorf("proposal is not created under the policy of %s", policy.DID.String())
+	}
+	if isDeactivatedDocument(current) {
+		return nil, xerrors.Errorf("did can't be changed: %w", ErrDeactivated)
+	}
+	if err := proposal.checkBase(current); err != nil {
+		return nil, err
+	}
+
+	payload, err := proposal.SigningPayload()
+	if err != nil {
+		return nil, err
+	}
+
+	var approved []MemoDID
+	seen := make(map[string]bool)
+	for _, approval := range proposal.Approvals {
+		did := approval.Signer.DID()
+		if seen[strings.ToLower(did.Identifier)] || !policy.isSigner(did) || !isControllerOf(current, did) {
+			continue
+		}
+
+		document, err := resolver.Resolve(did.String())
+		if err != nil {
+			return nil, err
+		}
+		if isDeactivatedDocument(document) || !hasAuthority(document, approval.Signer) {
+			continue
+		}
+		method := findVerificationMethod(document, approval.Signer)
+		if method == nil {
+			continue
+		}
+		sig, err := hexutil.Decode(approval.Signature)
+		if err != nil {
+			continue
+		}
+		ok, err := method.Verify(payload, sig)
+		if err == nil && ok {
+			seen[strings.ToLower(did.Identifier)] = true
+			approved = append(approved, did)
+		}
+	}
+
+	if len(approved) < policy.Threshold {
+		return approved, xerrors.Errorf("proposal has %d approvals, %d required", len(approved), policy.Threshold)
+	}
+	return approved, nil
+}
+
+// SetMultisigPolicy sets the policy that proposals of policy.DID are executed with,
+// it should be loaded from a trusted source, e.g. by LoadMultisigPolicyFile
+func (c *MemoDIDController) SetMultisigPolicy(policy *MultisigPolicy) error {
+	if err := policy.validate(); err != nil {
+		return err
+	}
+
+	c.policyLock.Lock()
+	defer c.policyLock.Unlock()
+	if c.policies == nil {
+		c.policies = make(map[string]*MultisigPolicy)
+	}
+	c.policies[strings.ToLower(policy.DID.Identifier)] = policy
+	return nil
+}
+
+// ExecuteProposal verifies proposal against the resolved document with the policy set for its did,
+// and applies its changes
+func (c *MemoDIDController) ExecuteProposal(proposal *Proposal) error {
+	c.policyLock.RLock()
+	policy, ok := c.policies[strings.ToLower(proposal.DID.Identifier)]
+	c.policyLock.RUnlock()
+	if !ok {
+		return xerrors.Errorf("multisig policy of %s: %w", proposal.DID.String(), ErrNotFound)
+	}
+
+	resolver := c.resolver()
+	current, err := resolver.Resolve(proposal.DID.String())
+	if err != nil {
+		return err
+	}
+	if _, err := VerifyProposal(resolver, policy, current, proposal); err != nil {
+		return err
+	}
+
+	cs, err := proposal.Changes(current)
+	if err != nil {
+		return err
+	}
+	operations, err := c.Plan(cs)
+	if err != nil {
+		return err
+	}
+	return runOperations(&proposalController{c: c, did: proposal.DID}, operations)
+}
+
+// checkPolicy refuses changes of a did which has a multisig policy, unless the transaction
+// is run by ExecuteProposal through client
+func (c *MemoDIDController) checkPolicy(client *ethclient.Client, did MemoDID) error {
+	c.policyLock.RLock()
+	defer c.policyLock.RUnlock()
+	if _, ok := c.policies[strings.ToLower(did.Identifier)]; !ok {
+		return nil
+	}
+	if approved, ok := c.approved[client]; ok && strings.EqualFold(approved, did.Identifier) {
+		return nil
+	}
+	return xerrors.Errorf("%s has a multisig policy, it can only be changed by ExecuteProposal", did.String())
+}
+
+// transactApproved is transact with changes of did approved
+func (c *MemoDIDController) transactApproved(did MemoDID, build txBuilder) (*TxResult, error) {
+	return c.transact(func(client *ethclient.Client) (*txRequest, error) {
+		c.policyLock.Lock()
+		if c.approved == nil {
+			c.approved = make(map[*ethclient.Client]string)
+		}
+		c.approved[client] = did.Identifier
+		c.policyLock.Unlock()
+		defer func() {
+			c.policyLock.Lock()
+			delete(c.approved, client)
+			c.policyLock.Unlock()
+		}()
+
+		return build(client)
+	})
+}
+
+// proposalController runs the operations of an approved proposal of did
+type proposalController struct {
+	c   *MemoDIDController
+	did MemoDID
+}
+
+var _ DIDController = &proposalController{}
+
+func (p *proposalController) RegisterDID() error {
+	return xerrors.Errorf("proposal can't register did")
+}
+
+func (p *proposalController) AddController(did MemoDID, controller MemoDID) error {
+	_, err := p.c.transactApproved(p.did, func(client *ethclient.Client) (*txRequest, error) {
+		return p.c.addController(client, did, controller)
+	})
+	return err
+}
+
+func (p *proposalController) DeactivateController(did MemoDID, controller MemoDID) error {
+	_, err := p.c.transactApproved(p.did, func(client *ethclient.Client) (*txRequest, error) {
+		return p.c.deactivateController(client, did, controller)
+	})
+	return err
+}
+
+func (p *proposalController) AddVerificationMethod(did MemoDID, vtype string, controller MemoDID, publicKeyHex string) error {
+	_, err := p.c.transactApproved(p.did, func(client *ethclient.Client) (*txRequest, error) {
+		return p.c.addVerificationMethod(client, did, vtype, controller, publicKeyHex)
+	})
+	return err
+}
+
+func (p *proposalController) UpdateVerificationMethod(didUrl MemoDIDUrl, vtype string, publicKeyHex string) error {
+	_, err := p.c.transactApproved(p.did, func(client *ethclient.Client) (*txRequest, error) {
+		return p.c.updateVerificationMethod(client, didUrl, vtype, publicKeyHex)
+	})
+	return err
+}
+
+func (p *proposalController) DeactivateVerificationMethod(didUrl MemoDIDUrl) error {
+	_, err := p.c.transactApproved(p.did, func(client *ethclient.Client) (*txRequest, error) {
+		return p.c.deactivateVerificationMethod(client, didUrl)
+	})
+	return err
+}
+
+func (p *proposalController) AddRelationShip(did MemoDID, relationType int, didUrl MemoDIDUrl, expireTime int64) error {
+	_, err := p.c.transactApproved(p.did, func(client *ethclient.Client) (*txRequest, error) {
+		return p.c.addRelationShip(client, did, relationType, didUrl, expireTime)
+	})
+	return err
+}
+
+func (p *proposalController) addDelegationUntil(did MemoDID, didUrl MemoDIDUrl, expiration int64) error {
+	_, err := p.c.transactApproved(p.did, func(client *ethclient.Client) (*txRequest, error) {
+		return p.c.addRelationShipUntil(client, did, CapabilityDelegation, didUrl, expiration)
+	})
+	return err
+}
+
+func (p *proposalController) DeactivateRelationShip(did MemoDID, relationType int, didUrl MemoDIDUrl) error {
+	_, err := p.c.transactApproved(p.did, func(client *ethclient.Client) (*txRequest, error) {
+		return p.c.deactivateRelationShip(client, did, relationType, didUrl)
+	})
+	return err
+}
+
+func (p *proposalController) DeactivateDID(did MemoDID) error {
+	_, err := p.c.transactApproved(p.did, func(client *ethclient.Client) (*txRequest, error) {
+		return p.c.deactivateDID(client, did)
+	})
+	return err
+}
@@ -0,0 +1,327 @@
+package memodid
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+func TestProposal(t *testing.T) {
+	_, pks, err := ToPublicKeys([]string{globalPrivateKey5})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	signer1, err := genDocument(globalPrivateKey2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	signer2, err := genDocument(globalPrivateKey3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	signer3, err := genDocument(globalPrivateKey4)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	current, err := genDocument(globalPrivateKey1, signer1.ID, signer2.ID, signer3.ID)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	resolver := mockResolver{}
+	resolver.add(current)
+	resolver.add(signer1)
+	resolver.add(signer2)
+	resolver.add(signer3)
+
+	// signers are chosen by the owner, not all controllers are signers
+	policy, err := NewMultisigPolicy(current.ID, []MemoDID{signer1.ID, signer3.ID}, 2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	_, err = NewMultisigPolicy(current.ID, []MemoDID{signer1.ID, signer3.ID}, 3)
+	if err == nil {
+		t.Error("Threshold can't exceed the number of signers")
+		return
+	}
+	_, err = NewMultisigPolicy(current.ID, []MemoDID{signer1.ID, signer1.ID}, 2)
+	if err == nil {
+		t.Error("Signers should be distinct")
+		return
+	}
+
+	// policy is loaded from file by the executor
+	path := filepath.Join(t.TempDir(), "policy.json")
+	data, err := json.Marshal(policy)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	err = os.WriteFile(path, data, 0600)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	loaded, err := LoadMultisigPolicyFile(path)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	reordered, err := NewMultisigPolicy(current.ID, []MemoDID{signer3.ID, signer1.ID}, 2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	digest, err := policy.Digest()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	loadedDigest, err := loaded.Digest()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	reorderedDigest, err := reordered.Digest()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if loadedDigest != digest || reorderedDigest != digest {
+		t.Errorf("Unexpect policy digests: %s, %s, %s", digest, loadedDigest, reorderedDigest)
+		return
+	}
+
+	proposed := current.clone()
+	key1, err := genVerificationMethod(&current.ID, 1, nil, EcdsaSecp256k1VerificationKey2019, pks[0])
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	proposed.VerificationMethod = append(proposed.VerificationMethod, key1)
+	proposed.Authentication = []MemoDIDUrl{key1.ID}
+	proposal, err := NewProposal(current, proposed, policy)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// signers sign copies of the proposal separately
+	data, err = json.Marshal(proposal)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	var copied Proposal
+	err = json.Unmarshal(data, &copied)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	sk2, err := crypto.HexToECDSA(globalPrivateKey2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	err = proposal.Sign(signer1.VerificationMethod[0].ID, EcdsaSecp256k1VerificationKey2019, sk2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	_, err = VerifyProposal(resolver, policy, current, proposal)
+	if err == nil {
+		t.Error("Proposal needs 2 approvals")
+		return
+	}
+
+	// approval of a controller which is not a signer
+	sk3, err := crypto.HexToECDSA(globalPrivateKey3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	err = proposal.Sign(signer2.VerificationMethod[0].ID, EcdsaSecp256k1VerificationKey2019, sk3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	_, err = VerifyProposal(resolver, policy, current, proposal)
+	if err == nil {
+		t.Error("Controllers out of policy can't approve proposal")
+		return
+	}
+
+	// approval of a key which is not a signer
+	sk1, err := crypto.HexToECDSA(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	err = proposal.Sign(current.VerificationMethod[0].ID, EcdsaSecp256k1VerificationKey2019, sk1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	_, err = VerifyProposal(resolver, policy, current, proposal)
+	if err == nil {
+		t.Error("Only signers can approve proposal")
+		return
+	}
+
+	sk4, err := crypto.HexToECDSA(globalPrivateKey4)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	// signer qualified with the chain segment is the same as the unqualified one
+	qualified := signer3.VerificationMethod[0].ID
+	qualified.ChainID = "985"
+	err = copied.Sign(qualified, EcdsaSecp256k1VerificationKey2019, sk4)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	err = proposal.Merge(&copied)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	approved, err := VerifyProposal(resolver, policy, current, proposal)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(approved) != 2 {
+		t.Errorf("Unexpect approvals: %v", approved)
+		return
+	}
+
+	// a weaker policy can't be used to execute the proposal
+	weaker, err := NewMultisigPolicy(current.ID, []MemoDID{signer1.ID, signer3.ID}, 1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	_, err = VerifyProposal(resolver, weaker, current, proposal)
+	if err == nil {
+		t.Error("Proposal should be verified only under the policy it is created under")
+		return
+	}
+
+	cs, err := proposal.Changes(current)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(cs.VerificationMethods) != 1 || len(cs.RelationShips) != 1 {
+		t.Errorf("Unexpect changes:\n%s", cs.String())
+		return
+	}
+
+	// stale proposal
+	_, err = VerifyProposal(resolver, policy, proposed, proposal)
+	if err == nil {
+		t.Error("Proposal is stale after the document changes")
+		return
+	}
+
+	other, err := NewProposal(current, current.clone(), policy)
+	if err == nil {
+		t.Errorf("Proposal without change should be rejected: %v", other)
+		return
+	}
+
+	// approvals count only while signers are controllers of the did
+	outsider, err := genDocument(globalPrivateKey5)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	resolver.add(outsider)
+	outsiderPolicy, err := NewMultisigPolicy(current.ID, []MemoDID{signer1.ID, outsider.ID}, 2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	outsiderProposal, err := NewProposal(current, proposed, outsiderPolicy)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	sk5, err := crypto.HexToECDSA(globalPrivateKey5)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	err = outsiderProposal.Sign(signer1.VerificationMethod[0].ID, EcdsaSecp256k1VerificationKey2019, sk2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	err = outsiderProposal.Sign(outsider.VerificationMethod[0].ID, EcdsaSecp256k1VerificationKey2019, sk5)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	_, err = VerifyProposal(resolver, outsiderPolicy, current, outsiderProposal)
+	if err == nil {
+		t.Error("Signers which are not controllers can't approve proposal")
+	}
+}
+
+func TestCheckPolicy(t *testing.T) {
+	signer, err := genDocument(globalPrivateKey2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	current, err := genDocument(globalPrivateKey1, signer.ID)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	policy, err := NewMultisigPolicy(current.ID, []MemoDID{signer.ID}, 1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	c := &MemoDIDController{}
+	client := &ethclient.Client{}
+	if err := c.checkPolicy(client, current.ID); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if err := c.SetMultisigPolicy(policy); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if err := c.checkPolicy(client, current.ID); err == nil {
+		t.Error("Did with multisig policy can't be changed directly")
+		return
+	}
+	if err := c.checkPolicy(client, signer.ID); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// transactions of ExecuteProposal are approved by their clients
+	c.approved = map[*ethclient.Client]string{client: current.ID.Identifier}
+	if err := c.checkPolicy(client, current.ID); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if err := c.checkPolicy(&ethclient.Client{}, current.ID); err == nil {
+		t.Error("Only transactions of ExecuteProposal can change did with multisig policy")
+	}
+}
@@ -0,0 +1,150 @@
+package memodid
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// maxNonceRetries is how many times a transaction is resent with a new nonce after nonce errors
+const maxNonceRetries = 3
+
+// nonceManager assigns nonces of the controller account locally, so that transactions sent
+// concurrently get different nonces without waiting for each other to be packaged.
+// The zero value is ready to use, it syncs with the pending nonce of the chain at first.
+type nonceManager struct {
+	lock   sync.Mutex
+	synced bool
+	next   uint64
+	// nonces assigned but not used by any transaction and not filled, they are reused first
+	free []uint64
+}
+
+type nonceBackend interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+}
+
+// acquire returns the nonce for the next transaction of account
+func (m *nonceManager) acquire(ctx context.Context, backend nonceBackend, account common.Address) (uint64, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if !m.synced {
+		nonce, err := backend.PendingNonceAt(ctx, account)
+		if err != nil {
+			return 0, err
+		}
+		m.next = nonce
+		m.free = nil
+		m.synced = true
+	}
+
+	if len(m.free) > 0 {
+		nonce := m.free[0]
+		m.free = m.free[1:]
+		return nonce, nil
+	}
+	nonce := m.next
+	m.next++
+	return nonce, nil
+}
+
+// release returns a nonce which is not used, because the transaction isn't sent or is dropped.
+// It returns true if the nonce is a gap below nonces in use, which blocks their transactions,
+// then the nonce is kept for the caller to fill, or to give back by reuse if it can't.
+func (m *nonceManager) release(nonce uint64) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if !m.synced || nonce >= m.next {
+		return false
+	}
+	for _, n := range m.free {
+		if n == nonce {
+			return false
+		}
+	}
+	m.addFree(nonce)
+	if nonce >= m.next {
+		return false
+	}
+	for i, n := range m.free {
+		if n == nonce {
+			m.free = append(m.free[:i], m.free[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// reuse gives back a gap which can't be filled, it is used by the next transaction
+func (m *nonceManager) reuse(nonce uint64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if !m.synced || nonce >= m.next {
+		return
+	}
+	for _, n := range m.free {
+		if n == nonce {
+			return
+		}
+	}
+	m.addFree(nonce)
+}
+
+func (m *nonceManager) addFree(nonce uint64) {
+	m.free = append(m.free, nonce)
+	sort.Slice(m.free, func(i, j int) bool { return m.free[i] < m.free[j] })
+	// free nonces at the end are not gaps
+	for len(m.free) > 0 && m.free[len(m.free)-1] == m.next-1 {
+		m.free = m.free[:len(m.free)-1]
+		m.next--
+	}
+}
+
+// reset makes the next acquire sync with the chain, it is called when the local nonce is out of date,
+// such as transactions are sent by other programs with the same account
+func (m *nonceManager) reset() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.synced = false
+}
+
+// fillGasLimit is the gas of a plain transfer
+const fillGasLimit = 21000
+
+type fillBackend interface {
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+}
+
+// fillNonce sends a transfer of 0 to the account itself with nonce, so that the transactions
+// with higher nonces are not blocked by the gap
+func fillNonce(ctx context.Context, backend fillBackend, opts *bind.TransactOpts, gasPrice *big.Int, nonce uint64) (*types.Transaction, error) {
+	if gasPrice == nil {
+		price, err := backend.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, err
+		}
+		gasPrice = price
+	}
+	tx := types.NewTransaction(nonce, opts.From, big.NewInt(0), fillGasLimit, gasPrice, nil)
+	signed, err := opts.Signer(opts.From, tx)
+	if err != nil {
+		return nil, err
+	}
+	return signed, backend.SendTransaction(ctx, signed)
+}
+
+// isNonceError reports whether the transaction is rejected because its nonce is used
+func isNonceError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "nonce too low") || strings.Contains(msg, "replacement transaction underpriced")
+}
@@ -0,0 +1,166 @@
+package memodid
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/xerrors"
+)
+
+type mockNonceBackend struct {
+	nonce uint64
+	calls int
+}
+
+func (b *mockNonceBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	b.calls++
+	return b.nonce, nil
+}
+
+func TestNonceManagerConcurrent(t *testing.T) {
+	backend := &mockNonceBackend{nonce: 10}
+	var m nonceManager
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	nonces := make(map[uint64]bool)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nonce, err := m.acquire(context.TODO(), backend, common.Address{})
+			if err != nil {
+				t.Error(err.Error())
+				return
+			}
+			lock.Lock()
+			nonces[nonce] = true
+			lock.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(nonces) != 20 || backend.calls != 1 {
+		t.Errorf("Unexpect nonces: %v, synced %d times", nonces, backend.calls)
+		return
+	}
+	for nonce := uint64(10); nonce < 30; nonce++ {
+		if !nonces[nonce] {
+			t.Errorf("Nonce %d is skipped", nonce)
+			return
+		}
+	}
+}
+
+func TestNonceManagerGaps(t *testing.T) {
+	backend := &mockNonceBackend{nonce: 5}
+	var m nonceManager
+	for i := 0; i < 4; i++ {
+		_, err := m.acquire(context.TODO(), backend, common.Address{})
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+	}
+
+	// 5, 6, 7, 8 are acquired, 6 isn't sent, 8 is dropped
+	if !m.release(6) {
+		t.Error("Nonce 6 is a gap below 7 and 8")
+		return
+	}
+	if m.release(8) {
+		t.Error("Nonce 8 is the last one, not a gap")
+		return
+	}
+	// 6 is kept for filling, it is reused only if it can't be filled
+	nonce, err := m.acquire(context.TODO(), backend, common.Address{})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if nonce != 8 {
+		t.Errorf("Unexpect nonce %d, expect 8", nonce)
+		return
+	}
+	m.reuse(6)
+	for _, expected := range []uint64{6, 9} {
+		nonce, err := m.acquire(context.TODO(), backend, common.Address{})
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if nonce != expected {
+			t.Errorf("Unexpect nonce %d, expect %d", nonce, expected)
+			return
+		}
+	}
+
+	// nonces are used by others
+	backend.nonce = 20
+	m.reset()
+	nonce, err = m.acquire(context.TODO(), backend, common.Address{})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if nonce != 20 {
+		t.Errorf("Unexpect nonce %d after reset", nonce)
+		return
+	}
+
+	if !isNonceError(xerrors.New("nonce too low: next nonce 21, tx nonce 20")) || isNonceError(xerrors.New("insufficient funds")) {
+		t.Error("Unexpect nonce error check")
+	}
+}
+
+type mockFillBackend struct {
+	sent []*types.Transaction
+}
+
+func (b *mockFillBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(7), nil
+}
+
+func (b *mockFillBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	b.sent = append(b.sent, tx)
+	return nil
+}
+
+func TestFillNonce(t *testing.T) {
+	sk, err := crypto.HexToECDSA(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	opts, err := bind.NewKeyedTransactorWithChainID(sk, big.NewInt(985))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	backend := &mockFillBackend{}
+	_, err = fillNonce(context.TODO(), backend, opts, nil, 6)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(backend.sent) != 1 {
+		t.Errorf("Unexpect transactions: %v", backend.sent)
+		return
+	}
+	tx := backend.sent[0]
+	sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if tx.Nonce() != 6 || sender != opts.From || *tx.To() != opts.From || tx.Value().Sign() != 0 || tx.GasPrice().Int64() != 7 {
+		t.Errorf("Unexpect filling transaction: nonce %d, from %s, to %s", tx.Nonce(), sender, tx.To())
+	}
+}
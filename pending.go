@@ -0,0 +1,449 @@
+package memodid
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"golang.org/x/xerrors"
+)
+
+type OperationStatus int
+
+const (
+	OperationPending OperationStatus = iota
+	OperationConfirmed
+	OperationFailed
+	// the receipt isn't got before timeout, the transaction may still be packaged,
+	// so the record is kept in PendingStore to be restored
+	OperationTimedOut
+)
+
+func (s OperationStatus) String() string {
+	switch s {
+	case OperationPending:
+		return "pending"
+	case OperationConfirmed:
+		return "confirmed"
+	case OperationFailed:
+		return "failed"
+	case OperationTimedOut:
+		return "timed out"
+	default:
+		return "unknown"
+	}
+}
+
+// PendingRecord is the persisted state of a pending operation
+type PendingRecord struct {
+	// hash of the first transaction
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Nonce uint64 `json:"nonce"`
+	// the transaction and its replacements with higher fee
+	TxHashes []common.Hash `json:"txHashes"`
+	// unix time
+	SubmittedAt int64 `json:"submittedAt"`
+}
+
+// PendingOperation is a controller operation whose transaction is sent, it is tracked in the background
+type PendingOperation struct {
+	lock        sync.Mutex
+	record      PendingRecord
+	status      OperationStatus
+	result      *TxResult
+	err         error
+	done        chan struct{}
+	onConfirmed []func(*TxResult)
+	onFailed    []func(error)
+}
+
+func newPendingOperation(record PendingRecord) *PendingOperation {
+	return &PendingOperation{
+		record: record,
+		done:   make(chan struct{}),
+	}
+}
+
+func (o *PendingOperation) ID() string {
+	return o.record.ID
+}
+
+func (o *PendingOperation) Name() string {
+	return o.record.Name
+}
+
+// TxHash returns the hash of the latest transaction, it changes when the fee is bumped
+func (o *PendingOperation) TxHash() common.Hash {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if len(o.record.TxHashes) == 0 {
+		return common.Hash{}
+	}
+	return o.record.TxHashes[len(o.record.TxHashes)-1]
+}
+
+// Record returns the state to persist
+func (o *PendingOperation) Record() PendingRecord {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	record := o.record
+	record.TxHashes = append([]common.Hash(nil), o.record.TxHashes...)
+	return record
+}
+
+func (o *PendingOperation) Status() OperationStatus {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.status
+}
+
+// Wait blocks until the operation is confirmed, failed or timed out, or ctx is done
+func (o *PendingOperation) Wait(ctx context.Context) (*TxResult, error) {
+	select {
+	case <-o.done:
+		return o.result, o.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// OnConfirmed registers fn to be called with the result once the operation is confirmed,
+// fn is called at once if it is already confirmed
+func (o *PendingOperation) OnConfirmed(fn func(*TxResult)) {
+	o.lock.Lock()
+	if o.status == OperationPending {
+		o.onConfirmed = append(o.onConfirmed, fn)
+		o.lock.Unlock()
+		return
+	}
+	status, result := o.status, o.result
+	o.lock.Unlock()
+	if status == OperationConfirmed {
+		fn(result)
+	}
+}
+
+// OnFailed registers fn to be called with the error once the operation fails,
+// fn is called at once if it has already failed. It isn't called if the operation times out,
+// whose transaction may still be packaged.
+func (o *PendingOperation) OnFailed(fn func(error)) {
+	o.lock.Lock()
+	if o.status == OperationPending {
+		o.onFailed = append(o.onFailed, fn)
+		o.lock.Unlock()
+		return
+	}
+	status, err := o.status, o.err
+	o.lock.Unlock()
+	if status == OperationFailed {
+		fn(err)
+	}
+}
+
+func (o *PendingOperation) bumped(hash common.Hash) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.record.TxHashes = append(o.record.TxHashes, hash)
+}
+
+func (o *PendingOperation) finish(result *TxResult, err error) {
+	o.lock.Lock()
+	if o.status != OperationPending {
+		o.lock.Unlock()
+		return
+	}
+	o.result, o.err = result, err
+	switch {
+	case err == nil:
+		o.status = OperationConfirmed
+	case errors.Is(err, ErrReceiptTimeout):
+		o.status = OperationTimedOut
+	default:
+		o.status = OperationFailed
+	}
+	status := o.status
+	onConfirmed, onFailed := o.onConfirmed, o.onFailed
+	o.onConfirmed, o.onFailed = nil, nil
+	close(o.done)
+	o.lock.Unlock()
+
+	switch status {
+	case OperationConfirmed:
+		for _, fn := range onConfirmed {
+			fn(result)
+		}
+	case OperationFailed:
+		for _, fn := range onFailed {
+			fn(err)
+		}
+	}
+}
+
+// PendingStore persists pending operations, so that they can be tracked again after restart
+type PendingStore interface {
+	Put(record PendingRecord) error
+	Delete(id string) error
+	List() ([]PendingRecord, error)
+}
+
+// FilePendingStore saves each pending operation as a json file in a directory
+type FilePendingStore struct {
+	dir string
+}
+
+var _ PendingStore = &FilePendingStore{}
+
+func NewFilePendingStore(dir string) (*FilePendingStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FilePendingStore{dir: dir}, nil
+}
+
+func (s *FilePendingStore) Put(record PendingRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	// write to a temporary file first, so that a crash doesn't leave a broken record
+	tmp := filepath.Join(s.dir, record.ID+".json.tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, record.ID+".json"))
+}
+
+func (s *FilePendingStore) Delete(id string) error {
+	err := os.Remove(filepath.Join(s.dir, id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *FilePendingStore) List() ([]PendingRecord, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var records []PendingRecord
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var record PendingRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, xerrors.Errorf("%s: %w", entry.Name(), err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// SetPendingStore sets where the pending operations of asynchronous calls are persisted
+func (c *MemoDIDController) SetPendingStore(store PendingStore) {
+	c.pending = store
+}
+
+// submit sends the transaction of the operation and tracks it in the background.
+// The operation is returned with the error if it can't be persisted.
+func (c *MemoDIDController) submit(build txBuilder) (*PendingOperation, error) {
+	client, err := ethclient.DialContext(context.TODO(), c.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	request, err := build(client)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		// no transaction is needed
+		op := newPendingOperation(PendingRecord{SubmittedAt: time.Now().Unix()})
+		op.finish(&TxResult{}, nil)
+		return op, nil
+	}
+
+	sent, err := c.submitTx(client, request.name, request.send)
+	if err != nil {
+		return nil, err
+	}
+	op := newPendingOperation(PendingRecord{
+		ID:          sent.hashes[0].Hex(),
+		Name:        request.name,
+		Nonce:       sent.nonce,
+		TxHashes:    append([]common.Hash(nil), sent.hashes...),
+		SubmittedAt: time.Now().Unix(),
+	})
+	sent.bumped = func(hash common.Hash) {
+		op.bumped(hash)
+		c.savePending(op)
+	}
+	err = c.savePending(op)
+
+	c.startTracking(op)
+	go c.track(op, sent, request)
+	return op, err
+}
+
+// RestorePending tracks the pending operations in the store again, such as after restart or timeout.
+// Their transactions are waited without fee bump, and the DIDUrl of AddVerificationMethod isn't filled.
+// Operations which are being tracked are returned as they are rather than tracked twice.
+func (c *MemoDIDController) RestorePending() ([]*PendingOperation, error) {
+	if c.pending == nil {
+		return nil, nil
+	}
+	records, err := c.pending.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []*PendingOperation
+	for _, record := range records {
+		if len(record.TxHashes) == 0 {
+			continue
+		}
+		op := newPendingOperation(record)
+		if tracked := c.startTracking(op); tracked != op {
+			ops = append(ops, tracked)
+			continue
+		}
+		sent := &sentTx{
+			name:   record.Name,
+			nonce:  record.Nonce,
+			hashes: append([]common.Hash(nil), record.TxHashes...),
+		}
+		go c.track(op, sent, &txRequest{name: record.Name})
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+func (c *MemoDIDController) track(op *PendingOperation, sent *sentTx, request *txRequest) {
+	result, err := c.trackResult(sent, request)
+	// the transaction may be packaged after timeout, keep it to be restored
+	if !errors.Is(err, ErrReceiptTimeout) && c.pending != nil {
+		c.pending.Delete(op.ID())
+	}
+	c.stopTracking(op)
+	op.finish(result, err)
+}
+
+// startTracking records op as being tracked, the operation with the same id is returned if it is tracked already
+func (c *MemoDIDController) startTracking(op *PendingOperation) *PendingOperation {
+	c.trackLock.Lock()
+	defer c.trackLock.Unlock()
+	if tracked, ok := c.tracking[op.ID()]; ok {
+		return tracked
+	}
+	if c.tracking == nil {
+		c.tracking = make(map[string]*PendingOperation)
+	}
+	c.tracking[op.ID()] = op
+	return op
+}
+
+func (c *MemoDIDController) stopTracking(op *PendingOperation) {
+	c.trackLock.Lock()
+	defer c.trackLock.Unlock()
+	if c.tracking[op.ID()] == op {
+		delete(c.tracking, op.ID())
+	}
+}
+
+func (c *MemoDIDController) trackResult(sent *sentTx, request *txRequest) (*TxResult, error) {
+	client, err := ethclient.DialContext(context.TODO(), c.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	receipt, err := c.trackTx(context.TODO(), client, sent)
+	if err != nil {
+		return nil, err
+	}
+	return completeTx(client, request, receipt), nil
+}
+
+func (c *MemoDIDController) savePending(op *PendingOperation) error {
+	if c.pending == nil {
+		return nil
+	}
+	return c.pending.Put(op.Record())
+}
+
+// RegisterDIDAsync is RegisterDIDWithResult which returns once the transaction is sent
+func (c *MemoDIDController) RegisterDIDAsync(vtype string, publicKey []byte) (*PendingOperation, error) {
+	return c.submit(func(client *ethclient.Client) (*txRequest, error) {
+		return c.registerDID(client, vtype, publicKey)
+	})
+}
+
+// AddControllerAsync is AddController which returns once the transaction is sent
+func (c *MemoDIDController) AddControllerAsync(did MemoDID, controller MemoDID) (*PendingOperation, error) {
+	return c.submit(func(client *ethclient.Client) (*txRequest, error) {
+		return c.addController(client, did, controller)
+	})
+}
+
+// DeactivateControllerAsync is DeactivateController which returns once the transaction is sent
+func (c *MemoDIDController) DeactivateControllerAsync(did MemoDID, controller MemoDID) (*PendingOperation, error) {
+	return c.submit(func(client *ethclient.Client) (*txRequest, error) {
+		return c.deactivateController(client, did, controller)
+	})
+}
+
+// AddVerificationMethodAsync is AddVerificationMethod which returns once the transaction is sent
+func (c *MemoDIDController) AddVerificationMethodAsync(did MemoDID, vtype string, controller MemoDID, publicKeyHex string) (*PendingOperation, error) {
+	return c.submit(func(client *ethclient.Client) (*txRequest, error) {
+		return c.addVerificationMethod(client, did, vtype, controller, publicKeyHex)
+	})
+}
+
+// UpdateVerificationMethodAsync is UpdateVerificationMethod which returns once the transaction is sent
+func (c *MemoDIDController) UpdateVerificationMethodAsync(didUrl MemoDIDUrl, vtype string, publicKeyHex string) (*PendingOperation, error) {
+	return c.submit(func(client *ethclient.Client) (*txRequest, error) {
+		return c.updateVerificationMethod(client, didUrl, vtype, publicKeyHex)
+	})
+}
+
+// DeactivateVerificationMethodAsync is DeactivateVerificationMethod which returns once the transaction is sent
+func (c *MemoDIDController) DeactivateVerificationMethodAsync(didUrl MemoDIDUrl) (*PendingOperation, error) {
+	return c.submit(func(client *ethclient.Client) (*txRequest, error) {
+		return c.deactivateVerificationMethod(client, didUrl)
+	})
+}
+
+// AddRelationShipAsync is AddRelationShip which returns once the transaction is sent
+func (c *MemoDIDController) AddRelationShipAsync(did MemoDID, relationType int, didUrl MemoDIDUrl, expireTime int64) (*PendingOperation, error) {
+	return c.submit(func(client *ethclient.Client) (*txRequest, error) {
+		return c.addRelationShip(client, did, relationType, didUrl, expireTime)
+	})
+}
+
+// DeactivateRelationShipAsync is DeactivateRelationShip which returns once the transaction is sent
+func (c *MemoDIDController) DeactivateRelationShipAsync(did MemoDID, relationType int, didUrl MemoDIDUrl) (*PendingOperation, error) {
+	return c.submit(func(client *ethclient.Client) (*txRequest, error) {
+		return c.deactivateRelationShip(client, did, relationType, didUrl)
+	})
+}
+
+// DeactivateDIDAsync is DeactivateDID which returns once the transaction is sent
+func (c *MemoDIDController) DeactivateDIDAsync(did MemoDID) (*PendingOperation, error) {
+	return c.submit(func(client *ethclient.Client) (*txRequest, error) {
+		return c.deactivateDID(client, did)
+	})
+}
@@ -0,0 +1,183 @@
+package memodid
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/xerrors"
+)
+
+func TestPendingOperation(t *testing.T) {
+	hash := common.HexToHash("0x01")
+	op := newPendingOperation(PendingRecord{ID: hash.Hex(), Name: "AddController", TxHashes: []common.Hash{hash}})
+	if op.Status() != OperationPending {
+		t.Errorf("Unexpect status %s", op.Status())
+		return
+	}
+
+	confirmed := make(chan *TxResult, 2)
+	op.OnConfirmed(func(result *TxResult) { confirmed <- result })
+	op.OnFailed(func(err error) { t.Errorf("Unexpect failure: %v", err) })
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	_, err := op.Wait(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Unexpect error: %v", err)
+		return
+	}
+
+	bumped := common.HexToHash("0x02")
+	op.bumped(bumped)
+	if op.TxHash() != bumped || op.Record().TxHashes[0] != hash {
+		t.Errorf("Unexpect transactions: %v", op.Record().TxHashes)
+		return
+	}
+
+	go op.finish(&TxResult{TxHash: bumped, BlockNumber: 100}, nil)
+	result, err := op.Wait(context.TODO())
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if result.BlockNumber != 100 || op.Status() != OperationConfirmed {
+		t.Errorf("Unexpect result %v, status %s", result, op.Status())
+		return
+	}
+	<-confirmed
+
+	// callbacks registered after confirmation are called at once
+	op.OnConfirmed(func(result *TxResult) { confirmed <- result })
+	if len(confirmed) != 1 {
+		t.Error("Callback should be called after confirmation")
+		return
+	}
+
+	failed := newPendingOperation(PendingRecord{ID: hash.Hex(), TxHashes: []common.Hash{hash}})
+	failed.finish(nil, &ErrTxReverted{Name: "AddController", Hash: hash})
+	var reverted error
+	failed.OnFailed(func(err error) { reverted = err })
+	if failed.Status() != OperationFailed || !errors.Is(reverted, &ErrTxReverted{}) {
+		t.Errorf("Unexpect status %s, error %v", failed.Status(), reverted)
+	}
+}
+
+func TestFilePendingStore(t *testing.T) {
+	store, err := NewFilePendingStore(t.TempDir())
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	hash := common.HexToHash("0x01")
+	record := PendingRecord{ID: hash.Hex(), Name: "AddController", Nonce: 3, TxHashes: []common.Hash{hash}, SubmittedAt: 1000}
+	err = store.Put(record)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	record.TxHashes = append(record.TxHashes, common.HexToHash("0x02"))
+	err = store.Put(record)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	records, err := store.List()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(records) != 1 || records[0].Nonce != 3 || len(records[0].TxHashes) != 2 {
+		t.Errorf("Unexpect records: %v", records)
+		return
+	}
+
+	err = store.Delete(record.ID)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	err = store.Delete(record.ID)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	records, err = store.List()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(records) != 0 {
+		t.Errorf("Unexpect records: %v", records)
+	}
+
+	timedOut := newPendingOperation(PendingRecord{ID: hash.Hex(), TxHashes: []common.Hash{hash}})
+	timedOut.OnFailed(func(err error) { t.Errorf("Timeout is not a failure: %v", err) })
+	timedOut.finish(nil, xerrors.Errorf("AddController: %w", ErrReceiptTimeout))
+	if timedOut.Status() != OperationTimedOut {
+		t.Errorf("Unexpect status %s", timedOut.Status())
+	}
+}
+
+func TestRestorePending(t *testing.T) {
+	store, err := NewFilePendingStore(t.TempDir())
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	hash := common.HexToHash("0x01")
+	err = store.Put(PendingRecord{ID: hash.Hex(), Name: "AddController", TxHashes: []common.Hash{hash}})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// no node at the endpoint, the receipt can't be got
+	controller := &MemoDIDController{
+		endpoint: "http://127.0.0.1:1",
+		waiter:   &TxWaiter{PollInterval: time.Millisecond, Timeout: 100 * time.Millisecond},
+		pending:  store,
+	}
+	ops, err := controller.RestorePending()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	again, err := controller.RestorePending()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(ops) != 1 || len(again) != 1 || ops[0] != again[0] {
+		t.Errorf("Operation should be tracked once: %v, %v", ops, again)
+		return
+	}
+
+	_, err = ops[0].Wait(context.TODO())
+	if !errors.Is(err, ErrReceiptTimeout) || ops[0].Status() != OperationTimedOut {
+		t.Errorf("Unexpect status %s, error %v", ops[0].Status(), err)
+		return
+	}
+
+	// the record is kept, it can be restored again after timeout
+	records, err := store.List()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(records) != 1 {
+		t.Errorf("Unexpect records: %v", records)
+		return
+	}
+	again, err = controller.RestorePending()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(again) != 1 || again[0] == ops[0] {
+		t.Errorf("Timed out operation should be tracked again: %v", again)
+	}
+}
@@ -0,0 +1,116 @@
+package memodid
+
+import (
+	"encoding/json"
+	"os"
+
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v3"
+)
+
+// Reconciler drives the document of a did to a desired state kept in YAML or JSON
+type Reconciler struct {
+	resolver   DIDResolver
+	controller *MemoDIDController
+
+	// PlanOnly returns the operations without running them, callers print or review the plan
+	PlanOnly bool
+}
+
+func NewReconciler(resolver DIDResolver, controller *MemoDIDController) *Reconciler {
+	return &Reconciler{
+		resolver:   resolver,
+		controller: controller,
+	}
+}
+
+// LoadDesiredState parses a desired document in YAML or JSON, which has the same properties as the JSON form
+func LoadDesiredState(data []byte) (*MemoDIDDocument, error) {
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	document := &MemoDIDDocument{}
+	if err := json.Unmarshal(jsonData, document); err != nil {
+		return nil, err
+	}
+	if document.Context == "" {
+		document.Context = DefaultContext
+	}
+	return document, nil
+}
+
+func LoadDesiredStateFile(path string) (*MemoDIDDocument, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return LoadDesiredState(data)
+}
+
+// Plan resolves the current document and returns the operations which turn it into desired
+func (r *Reconciler) Plan(desired *MemoDIDDocument) ([]Operation, error) {
+	cs, err := r.diff(desired)
+	if err != nil {
+		return nil, err
+	}
+	if cs.IsEmpty() {
+		return nil, nil
+	}
+	return r.controller.Plan(cs)
+}
+
+// Reconcile runs the operations of Plan, nothing is done if the document is already in desired state.
+// It can be run again after a failure, the operations left are planned from the current document.
+// The operations are returned for the caller to show, the package doesn't print them.
+func (r *Reconciler) Reconcile(desired *MemoDIDDocument) ([]Operation, error) {
+	operations, err := r.Plan(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.PlanOnly {
+		return operations, nil
+	}
+
+	return operations, runOperations(r.controller, operations)
+}
+
+func (r *Reconciler) diff(desired *MemoDIDDocument) (*ChangeSet, error) {
+	vd := &validator{}
+	vd.validateDID("id", desired.ID)
+	if err := vd.err(); err != nil {
+		return nil, err
+	}
+
+	current, err := r.resolver.Resolve(desired.ID.String())
+	if err != nil {
+		return nil, err
+	}
+	if isDeactivatedDocument(current) {
+		return nil, xerrors.Errorf("%s: %w", desired.ID.String(), ErrDeactivated)
+	}
+	if len(current.VerificationMethod) == 0 {
+		return nil, xerrors.Errorf("%s is not registered: %w", desired.ID.String(), ErrNotFound)
+	}
+
+	// keyAgreement and capabilityInvocation follow from the verification methods,
+	// they can be left out of the desired state
+	desired = desired.clone()
+	if len(desired.KeyAgreement) == 0 {
+		desired.KeyAgreement = queryAllKeyAgreement(desired.VerificationMethod)
+	}
+	if len(desired.CapabilityInvocation) == 0 {
+		desired.CapabilityInvocation = queryAllInvocation(desired.VerificationMethod)
+	}
+	if err := desired.Validate(); err != nil {
+		return nil, err
+	}
+
+	return Diff(current, desired)
+}
@@ -0,0 +1,118 @@
+package memodid
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLoadDesiredState(t *testing.T) {
+	document, err := genDocument(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	method := document.VerificationMethod[0]
+
+	desiredYAML := `
+id: ` + document.ID.String() + `
+verificationMethod:
+  - id: ` + method.ID.String() + `
+    controller: ` + method.Controller.String() + `
+    type: ` + method.Type + `
+    publicKeyHex: "` + method.PublicKeyHex + `"
+authentication:
+  - ` + method.ID.String() + `
+`
+	desired, err := LoadDesiredState([]byte(desiredYAML))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if desired.Context != DefaultContext || desired.ID.String() != document.ID.String() ||
+		len(desired.VerificationMethod) != 1 || desired.VerificationMethod[0].PublicKeyHex != method.PublicKeyHex ||
+		len(desired.Authentication) != 1 {
+		t.Errorf("Unexpect desired state: %v", desired)
+		return
+	}
+
+	data, err := json.Marshal(desired)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	desired, err = LoadDesiredState(data)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(desired.Authentication) != 1 {
+		t.Errorf("Unexpect desired state from json: %v", desired)
+	}
+}
+
+func TestReconcilerDiff(t *testing.T) {
+	_, pks, err := ToPublicKeys([]string{globalPrivateKey3})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	current, err := genDocument(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	current.CapabilityInvocation = []MemoDIDUrl{current.VerificationMethod[0].ID}
+	resolver := mockResolver{}
+	resolver.add(current)
+	r := &Reconciler{resolver: resolver}
+
+	// derived capabilityInvocation is not required in desired state
+	desired := current.clone()
+	desired.CapabilityInvocation = nil
+	cs, err := r.diff(desired)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !cs.IsEmpty() {
+		t.Errorf("Unexpect changes:\n%s", cs.String())
+		return
+	}
+
+	key1, err := genVerificationMethod(&desired.ID, 1, nil, EcdsaSecp256k1VerificationKey2019, pks[0])
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	desired.VerificationMethod = append(desired.VerificationMethod, key1)
+	desired.AssertionMethod = []MemoDIDUrl{key1.ID}
+	cs, err = r.diff(desired)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	operations, err := planChangeSet(cs, 1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	for _, operation := range operations {
+		t.Log(operation.String())
+	}
+	if len(operations) != 2 {
+		t.Errorf("Unexpect operations: %v", operations)
+		return
+	}
+
+	// invalid desired state is rejected before any operation
+	key5, err := desired.ID.DIDUrl(5)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	desired.Authentication = []MemoDIDUrl{*key5}
+	_, err = r.diff(desired)
+	if err == nil {
+		t.Error("Invalid desired state should be rejected")
+	}
+}
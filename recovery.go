@@ -0,0 +1,362 @@
+package memodid
+
+import (
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"golang.org/x/xerrors"
+)
+
+// RecoveryRequest replaces masterKey and controllers of a did whose masterKey is lost.
+// It is signed by the keys in recovery relationship of the did, which may be keys of other dids(social recovery).
+// AccountDid contract has no recovery entry, so the request is checked off chain
+// and executed by a controller of the did, such as a recovery service.
+type RecoveryRequest struct {
+	DID           MemoDID   `json:"did"`
+	MasterKeyType string    `json:"masterKeyType"`
+	MasterKey     string    `json:"masterKey"`
+	Controllers   []MemoDID `json:"controllers,omitempty"`
+	// digest of the document to be recovered without capabilityDelegationExpiration, the request is invalid
+	// once the document changes, but renewing delegations doesn't invalidate pending requests
+	DocumentDigest string `json:"documentDigest"`
+	// unix time set by the requester, it keeps requests distinct but doesn't start the delay
+	CreatedAt int64 `json:"createdAt"`
+
+	Signatures []RecoverySignature `json:"signatures,omitempty"`
+}
+
+type RecoverySignature struct {
+	ID        MemoDIDUrl `json:"id"`
+	Signature string     `json:"signature"`
+}
+
+// RecoveryPolicy requires Threshold(M) signatures of the N recovery keys,
+// and the request takes effect Delay after the executor first sees it
+type RecoveryPolicy struct {
+	Threshold int
+	Delay     time.Duration
+}
+
+// NewRecoveryRequest creates an unsigned request that replaces masterKey of document with the given key,
+// and replaces its controllers with controllers
+func NewRecoveryRequest(document *MemoDIDDocument, vtype string, masterKeyHex string, controllers []MemoDID) (*RecoveryRequest, error) {
+	if isDeactivatedDocument(document) {
+		return nil, xerrors.Errorf("did can't be recovered: %w", ErrDeactivated)
+	}
+	masterKey, err := DecodePublicKey(masterKeyHex)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidatePublicKey(vtype, masterKey); err != nil {
+		return nil, err
+	}
+	if isX25519Type(vtype) {
+		return nil, xerrors.Errorf("%s can't be used as masterKey", vtype)
+	}
+	vd := &validator{}
+	for _, controller := range controllers {
+		vd.validateDID("controllers", controller)
+	}
+	if err := vd.err(); err != nil {
+		return nil, err
+	}
+
+	digest, err := recoveryDigest(document)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecoveryRequest{
+		DID:            document.ID,
+		MasterKeyType:  vtype,
+		MasterKey:      hexutil.Encode(masterKey),
+		Controllers:    controllers,
+		DocumentDigest: hexutil.Encode(digest),
+		CreatedAt:      time.Now().Unix(),
+	}, nil
+}
+
+// SigningPayload returns the canonical JSON of the request without signatures
+func (r *RecoveryRequest) SigningPayload() ([]byte, error) {
+	request := *r
+	request.Signatures = nil
+	return marshalJCS(&request)
+}
+
+// Sign adds the signature of recovery key didUrl
+func (r *RecoveryRequest) Sign(didUrl MemoDIDUrl, vtype string, privateKey interface{}) error {
+	payload, err := r.SigningPayload()
+	if err != nil {
+		return err
+	}
+	sig, err := Sign(vtype, privateKey, payload)
+	if err != nil {
+		return err
+	}
+
+	for i := range r.Signatures {
+		if sameDIDUrl(r.Signatures[i].ID, didUrl) {
+			r.Signatures[i].Signature = hexutil.Encode(sig)
+			return nil
+		}
+	}
+	r.Signatures = append(r.Signatures, RecoverySignature{ID: didUrl, Signature: hexutil.Encode(sig)})
+	return nil
+}
+
+// ID identifies the request by the digest of its signing payload,
+// so it doesn't change as signatures are added
+func (r *RecoveryRequest) ID() (string, error) {
+	payload, err := r.SigningPayload()
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(payload)
+	return hexutil.Encode(hash[:]), nil
+}
+
+// EffectiveAt returns the time after which the request can be executed,
+// seenAt is when the executor first saw the request, as CreatedAt is chosen by the requester
+func (r *RecoveryRequest) EffectiveAt(policy RecoveryPolicy, seenAt time.Time) time.Time {
+	return seenAt.Add(policy.Delay)
+}
+
+// VerifyRecovery checks that request is signed by at least policy.Threshold distinct recovery keys of document,
+// document is unchanged since the request was created, and the delay of policy has passed at now
+// since the executor first saw the request at seenAt, which should come from a RecoveryStore.
+// Recovery keys of other dids are looked up by resolver.
+func VerifyRecovery(resolver DIDResolver, document *MemoDIDDocument, request *RecoveryRequest, policy RecoveryPolicy, seenAt time.Time, now time.Time) error {
+	if err := checkRecovery(resolver, document, request, policy); err != nil {
+		return err
+	}
+	return checkRecoveryDelay(request, policy, seenAt, now)
+}
+
+func checkRecoveryDelay(request *RecoveryRequest, policy RecoveryPolicy, seenAt time.Time, now time.Time) error {
+	if effectiveAt := request.EffectiveAt(policy, seenAt); now.Before(effectiveAt) {
+		return xerrors.Errorf("recovery request takes effect at %s", effectiveAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// recoveryDigest returns the digest of document without capabilityDelegationExpiration
+func recoveryDigest(document *MemoDIDDocument) ([]byte, error) {
+	document = document.clone()
+	document.CapabilityDelegationExpiration = nil
+	return document.Digest()
+}
+
+// checkRecovery checks the request except its delay
+func checkRecovery(resolver DIDResolver, document *MemoDIDDocument, request *RecoveryRequest, policy RecoveryPolicy) error {
+	if isDeactivatedDocument(document) {
+		return xerrors.Errorf("did can't be recovered: %w", ErrDeactivated)
+	}
+	if request.DID.String() != document.ID.String() {
+		return xerrors.Errorf("recovery request of %s can't recover %s", request.DID.String(), document.ID.String())
+	}
+	if len(document.Recovery) == 0 {
+		return xerrors.Errorf("%s has no recovery key", document.ID.String())
+	}
+	// requests decoded from json aren't checked by NewRecoveryRequest
+	vd := &validator{}
+	for _, controller := range request.Controllers {
+		vd.validateDID("controllers", controller)
+	}
+	if err := vd.err(); err != nil {
+		return err
+	}
+	threshold := policy.Threshold
+	if threshold <= 0 {
+		threshold = 1
+	}
+	if threshold > len(document.Recovery) {
+		return xerrors.Errorf("threshold %d exceeds %d recovery keys", threshold, len(document.Recovery))
+	}
+
+	digest, err := recoveryDigest(document)
+	if err != nil {
+		return err
+	}
+	if hexutil.Encode(digest) != request.DocumentDigest {
+		return xerrors.Errorf("%s has been changed since the recovery request is created", document.ID.String())
+	}
+
+	payload, err := request.SigningPayload()
+	if err != nil {
+		return err
+	}
+
+	signed := make(map[string]bool)
+	for _, signature := range request.Signatures {
+		id := signature.ID.withoutChain()
+		if signed[id.String()] || !document.HasRelationShip(Recovery, signature.ID) {
+			continue
+		}
+
+		var method *VerificationMethod
+		if signature.ID.Identifier == document.ID.Identifier {
+			method = findVerificationMethod(document, signature.ID)
+		} else {
+			did := signature.ID.DID()
+			other, err := resolver.Resolve(did.String())
+			if err != nil {
+				return err
+			}
+			method = findVerificationMethod(other, signature.ID)
+		}
+		if method == nil {
+			continue
+		}
+
+		sig, err := hexutil.Decode(signature.Signature)
+		if err != nil {
+			continue
+		}
+		ok, err := method.Verify(payload, sig)
+		if err == nil && ok {
+			signed[id.String()] = true
+		}
+	}
+	if len(signed) < threshold {
+		return xerrors.Errorf("recovery request has %d valid signatures, %d required", len(signed), threshold)
+	}
+
+	return nil
+}
+
+// RecoveryStore records when the executor first saw each recovery request,
+// which starts the delay of the request
+type RecoveryStore interface {
+	// SeenAt returns when request id was first seen, now is recorded if it is new
+	SeenAt(id string, now time.Time) (time.Time, error)
+}
+
+// FileRecoveryStore saves the first seen time of each request as a file in a directory
+type FileRecoveryStore struct {
+	lock sync.Mutex
+	dir  string
+}
+
+var _ RecoveryStore = &FileRecoveryStore{}
+
+func NewFileRecoveryStore(dir string) (*FileRecoveryStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileRecoveryStore{dir: dir}, nil
+}
+
+func (s *FileRecoveryStore) SeenAt(id string, now time.Time) (time.Time, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	path := filepath.Join(s.dir, id)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		seenAt, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return time.Time{}, xerrors.Errorf("%s: %w", path, err)
+		}
+		return time.Unix(seenAt, 0), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, err
+	}
+
+	// write to a temporary file first, so that a crash doesn't leave a broken record
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(now.Unix(), 10)), 0600); err != nil {
+		return time.Time{}, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(now.Unix(), 0), nil
+}
+
+// memRecoveryStore is the default RecoveryStore of controllers, the delay restarts after restart
+type memRecoveryStore struct {
+	lock   sync.Mutex
+	seenAt map[string]time.Time
+}
+
+func newMemRecoveryStore() *memRecoveryStore {
+	return &memRecoveryStore{seenAt: make(map[string]time.Time)}
+}
+
+func (s *memRecoveryStore) SeenAt(id string, now time.Time) (time.Time, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if seenAt, ok := s.seenAt[id]; ok {
+		return seenAt, nil
+	}
+	s.seenAt[id] = now
+	return now, nil
+}
+
+// recoveredDocument returns document with masterKey and controllers replaced by request
+func recoveredDocument(document *MemoDIDDocument, request *RecoveryRequest) *MemoDIDDocument {
+	recovered := document.clone()
+	recovered.Controller = append([]MemoDID(nil), request.Controllers...)
+	for i := range recovered.VerificationMethod {
+		method := &recovered.VerificationMethod[i]
+		if method.ID.GetMethodIndex() == 0 {
+			*method = VerificationMethod{
+				ID:           method.ID,
+				Controller:   method.Controller,
+				Type:         request.MasterKeyType,
+				PublicKeyHex: request.MasterKey,
+			}
+		}
+	}
+	return recovered
+}
+
+// SetRecoveryStore sets where the controller records when it first saw recovery requests,
+// requests are recorded in memory by default
+func (c *MemoDIDController) SetRecoveryStore(store RecoveryStore) {
+	c.recoveries = store
+}
+
+// Recover verifies request against the resolved document and replaces masterKey and controllers of the did.
+// The delay of policy starts when the controller first gets a well signed request, so the request
+// is refused until Recover is called again after the delay.
+func (c *MemoDIDController) Recover(request *RecoveryRequest, policy RecoveryPolicy) error {
+	resolver := c.resolver()
+	document, err := resolver.Resolve(request.DID.String())
+	if err != nil {
+		return err
+	}
+	if err := checkRecovery(resolver, document, request, policy); err != nil {
+		return err
+	}
+	id, err := request.ID()
+	if err != nil {
+		return err
+	}
+	if c.recoveries == nil {
+		c.recoveries = newMemRecoveryStore()
+	}
+	now := time.Now()
+	seenAt, err := c.recoveries.SeenAt(id, now)
+	if err != nil {
+		return err
+	}
+	if err := checkRecoveryDelay(request, policy, seenAt, now); err != nil {
+		return err
+	}
+
+	cs, err := Diff(document, recoveredDocument(document, request))
+	if err != nil {
+		return err
+	}
+	return c.Apply(cs)
+}
@@ -0,0 +1,251 @@
+package memodid
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestVerifyRecovery(t *testing.T) {
+	_, pks, err := ToPublicKeys([]string{globalPrivateKey5})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	friend1, err := genDocument(globalPrivateKey2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	friend2, err := genDocument(globalPrivateKey3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	friend3, err := genDocument(globalPrivateKey4)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document, err := genDocument(globalPrivateKey1, friend1.ID)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document.Recovery = []MemoDIDUrl{friend1.VerificationMethod[0].ID, friend2.VerificationMethod[0].ID, friend3.VerificationMethod[0].ID}
+	document.CapabilityDelegation = []MemoDIDUrl{document.VerificationMethod[0].ID}
+	document.CapabilityDelegationExpiration = map[string]int64{document.VerificationMethod[0].ID.String(): 1000}
+	resolver := mockResolver{}
+	resolver.add(document)
+	resolver.add(friend1)
+	resolver.add(friend2)
+	resolver.add(friend3)
+
+	request, err := NewRecoveryRequest(document, EcdsaSecp256k1VerificationKey2019, pks[0], []MemoDID{friend2.ID})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	policy := RecoveryPolicy{Threshold: 2, Delay: 24 * time.Hour}
+	seenAt := time.Now()
+	effectiveAt := request.EffectiveAt(policy, seenAt)
+
+	sk2, err := crypto.HexToECDSA(globalPrivateKey2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	err = request.Sign(friend1.VerificationMethod[0].ID, EcdsaSecp256k1VerificationKey2019, sk2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	// signed twice by the same key, once qualified with the chain segment
+	qualified := friend1.VerificationMethod[0].ID
+	qualified.ChainID = "985"
+	request.Signatures = append(request.Signatures, RecoverySignature{ID: qualified, Signature: request.Signatures[0].Signature})
+	err = VerifyRecovery(resolver, document, request, policy, seenAt, effectiveAt)
+	if err == nil {
+		t.Error("Recovery request needs 2 signatures")
+		return
+	}
+
+	// signature of a key not in recovery
+	sk5, err := crypto.HexToECDSA(globalPrivateKey5)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	err = request.Sign(document.VerificationMethod[0].ID, EcdsaSecp256k1VerificationKey2019, sk5)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	err = VerifyRecovery(resolver, document, request, policy, seenAt, effectiveAt)
+	if err == nil {
+		t.Error("Only recovery keys can sign recovery request")
+		return
+	}
+
+	sk3, err := crypto.HexToECDSA(globalPrivateKey3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	qualified = friend2.VerificationMethod[0].ID
+	qualified.ChainID = "985"
+	err = request.Sign(qualified, EcdsaSecp256k1VerificationKey2019, sk3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	err = VerifyRecovery(resolver, document, request, policy, seenAt, effectiveAt.Add(-time.Second))
+	if err == nil {
+		t.Error("Recovery request can't be executed before delay")
+		return
+	}
+	err = VerifyRecovery(resolver, document, request, policy, seenAt, effectiveAt)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// renewing a delegation doesn't invalidate the request
+	renewed := document.clone()
+	renewed.CapabilityDelegationExpiration[document.VerificationMethod[0].ID.String()] = 2000
+	err = VerifyRecovery(resolver, renewed, request, policy, seenAt, effectiveAt)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// changed request
+	request.Controllers = []MemoDID{friend3.ID}
+	err = VerifyRecovery(resolver, document, request, policy, seenAt, effectiveAt)
+	if err == nil {
+		t.Error("Signatures should cover the request")
+		return
+	}
+	request.Controllers = []MemoDID{friend2.ID}
+
+	recovered := recoveredDocument(document, request)
+	cs, err := Diff(document, recovered)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	t.Log(cs.String())
+	if len(cs.Controllers) != 2 || len(cs.VerificationMethods) != 1 || cs.VerificationMethods[0].Op != UpdateOperation {
+		t.Errorf("Unexpect changes:\n%s", cs.String())
+		return
+	}
+
+	// changed document
+	err = VerifyRecovery(resolver, recovered, request, policy, seenAt, effectiveAt)
+	if err == nil {
+		t.Error("Recovery request is invalid after the document changes")
+	}
+}
+
+func TestRecoveryDelay(t *testing.T) {
+	_, pks, err := ToPublicKeys([]string{globalPrivateKey5})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	friend, err := genDocument(globalPrivateKey2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document, err := genDocument(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document.Recovery = []MemoDIDUrl{friend.VerificationMethod[0].ID}
+	resolver := mockResolver{}
+	resolver.add(document)
+	resolver.add(friend)
+
+	// the requester backdates the request beyond the delay
+	policy := RecoveryPolicy{Threshold: 1, Delay: 24 * time.Hour}
+	request, err := NewRecoveryRequest(document, EcdsaSecp256k1VerificationKey2019, pks[0], nil)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	now := time.Now()
+	request.CreatedAt = now.Add(-2 * policy.Delay).Unix()
+	sk2, err := crypto.HexToECDSA(globalPrivateKey2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	err = request.Sign(friend.VerificationMethod[0].ID, EcdsaSecp256k1VerificationKey2019, sk2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	id, err := request.ID()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	dir := filepath.Join(t.TempDir(), "recovery")
+	store, err := NewFileRecoveryStore(dir)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	seenAt, err := store.SeenAt(id, now)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	err = VerifyRecovery(resolver, document, request, policy, seenAt, now)
+	if err == nil {
+		t.Error("Backdated recovery request can't be executed before delay")
+		return
+	}
+
+	// seen again later, also after restart, the delay still starts at the first time
+	store, err = NewFileRecoveryStore(dir)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	later := now.Add(policy.Delay)
+	seenAt, err = store.SeenAt(id, later)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if seenAt.Unix() != now.Unix() {
+		t.Errorf("Unexpect first seen time: %s", seenAt)
+		return
+	}
+	err = VerifyRecovery(resolver, document, request, policy, seenAt, later)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// signatures don't change the request
+	err = request.Sign(document.VerificationMethod[0].ID, EcdsaSecp256k1VerificationKey2019, sk2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	signedID, err := request.ID()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if signedID != id {
+		t.Error("ID of recovery request should not depend on signatures")
+	}
+}
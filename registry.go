@@ -0,0 +1,132 @@
+package memodid
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"golang.org/x/xerrors"
+)
+
+// MethodResolver is the driver of a DID method in Registry,
+// it is implemented by MemoDIDResolver, MultiChainResolver, KeyResolver, WebResolver and EthrResolver
+type MethodResolver interface {
+	ResolveWithMetadata(didString string) (*ResolutionResult, error)
+}
+
+var (
+	_ MethodResolver = &MemoDIDResolver{}
+	_ MethodResolver = &MultiChainResolver{}
+	_ MethodResolver = &KeyResolver{}
+	_ MethodResolver = &WebResolver{}
+	_ MethodResolver = &EthrResolver{}
+)
+
+// Registry resolves dids of several methods by dispatching them to the driver of their method.
+// Drivers of did:key, did:web and did:ethr are registered by NewRegistry, others such as did:memo are added by Register.
+type Registry struct {
+	lock    sync.RWMutex
+	drivers map[string]MethodResolver
+}
+
+var _ DIDResolver = &Registry{}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		drivers: map[string]MethodResolver{
+			"key":  NewKeyResolver(),
+			"web":  NewWebResolver(nil),
+			"ethr": NewEthrResolver(),
+		},
+	}
+}
+
+// Register sets the driver of method, the former driver of method is replaced
+func (r *Registry) Register(method string, driver MethodResolver) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.drivers[method] = driver
+}
+
+// Methods returns the methods which have drivers in order
+func (r *Registry) Methods() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	methods := make([]string, 0, len(r.drivers))
+	for method := range r.drivers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func (r *Registry) Resolve(didString string) (*MemoDIDDocument, error) {
+	result, err := r.ResolveWithMetadata(didString)
+	if err != nil {
+		return nil, err
+	}
+	return result.Document, nil
+}
+
+func (r *Registry) ResolveWithMetadata(didString string) (*ResolutionResult, error) {
+	driver, err := r.driver(didString)
+	if err != nil {
+		return nil, err
+	}
+	return driver.ResolveWithMetadata(didString)
+}
+
+// Dereference returns the type and hex public key of the verification method,
+// the dereferencing of drivers which implement DIDResolver is used
+func (r *Registry) Dereference(didUrlString string) (string, string, error) {
+	driver, err := r.driver(didUrlString)
+	if err != nil {
+		return "", "", err
+	}
+	if resolver, ok := driver.(DIDResolver); ok {
+		return resolver.Dereference(didUrlString)
+	}
+	return dereference(driver, didUrlString)
+}
+
+func (r *Registry) driver(id string) (MethodResolver, error) {
+	method, _, _, err := splitDID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	driver, ok := r.drivers[method]
+	if !ok {
+		return nil, xerrors.Errorf("%s: %w", method, ErrUnsupportedMethod)
+	}
+	return driver, nil
+}
+
+// dereference finds the verification method in the document resolved by driver
+func dereference(driver MethodResolver, didUrlString string) (string, string, error) {
+	didUrl, err := ParseDIDUrl(didUrlString)
+	if err != nil {
+		return "", "", err
+	}
+	did := didUrl.DID()
+	result, err := driver.ResolveWithMetadata(did.String())
+	if err != nil {
+		return "", "", err
+	}
+	if result.DocumentMetadata.Deactivated {
+		return "", "", xerrors.Errorf("%s: %w", did.String(), ErrDeactivated)
+	}
+
+	method := findVerificationMethod(result.Document, *didUrl)
+	if method == nil {
+		return "", "", xerrors.Errorf("The Verify Method(%s): %w", didUrl.String(), ErrNotFound)
+	}
+	publicKey, err := method.PublicKeyBytes()
+	if err != nil {
+		return "", "", err
+	}
+	return method.Type, hexutil.Encode(publicKey), nil
+}
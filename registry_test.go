@@ -0,0 +1,70 @@
+package memodid
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	document, err := genDocument(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	memoDID, identifier := document.ID, document.ID.Identifier
+
+	registry := NewRegistry()
+	registry.Register("memo", &mockChainResolver{chainID: 985, documents: map[string]*MemoDIDDocument{identifier: document}})
+	if methods := registry.Methods(); strings.Join(methods, ",") != "ethr,key,memo,web" {
+		t.Errorf("Unexpect methods: %v", methods)
+		return
+	}
+
+	result, err := registry.ResolveWithMetadata(memoDID.String())
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if result.Document != document || result.ResolutionMetadata.ChainID != "985" {
+		t.Errorf("Unexpect result: %+v", result)
+		return
+	}
+
+	keyDID := "did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK"
+	keyDocument, err := registry.Resolve(keyDID)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if keyDocument.ID.String() != keyDID {
+		t.Errorf("Unexpect document of %s", keyDocument.ID.String())
+		return
+	}
+	// key agreement of did:key is usable by Encrypt
+	_, err = Encrypt(registry, keyDID, []byte("hello"))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	ethrDocument, err := registry.Resolve("did:ethr:0xb9c5714089478a327f09197987f16f9e5d936e8a")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(ethrDocument.VerificationMethod) != 1 {
+		t.Errorf("Unexpect document of did:ethr: %+v", ethrDocument)
+		return
+	}
+
+	_, err = registry.Resolve("did:pkh:eip155:1:0xb9c5714089478a327f09197987f16f9e5d936e8a")
+	if !errors.Is(err, ErrUnsupportedMethod) {
+		t.Errorf("Unexpect error of unregistered method: %v", err)
+		return
+	}
+	_, _, err = registry.Dereference("did:pkh:eip155:1:0xb9c5714089478a327f09197987f16f9e5d936e8a#blockchainAccountId")
+	if !errors.Is(err, ErrUnsupportedMethod) {
+		t.Errorf("Unexpect error of unregistered method: %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+package memodid
+
+// ContentTypeDIDLD is the media type of resolved documents, which have @context
+const ContentTypeDIDLD = "application/did+ld+json"
+
+// ResolutionResult is the document of a did with metadata about how it is resolved
+type ResolutionResult struct {
+	Document           *MemoDIDDocument   `json:"didDocument"`
+	ResolutionMetadata ResolutionMetadata `json:"didResolutionMetadata"`
+	DocumentMetadata   DocumentMetadata   `json:"didDocumentMetadata"`
+}
+
+type ResolutionMetadata struct {
+	ContentType string `json:"contentType,omitempty"`
+	// the chain which answered, only for memo dids
+	ChainID string `json:"chainId,omitempty"`
+}
+
+type DocumentMetadata struct {
+	Deactivated bool `json:"deactivated,omitempty"`
+	// violations of DID Core rules in the resolved document, which is returned anyway
+	ValidationErrors ValidationErrors `json:"validationErrors,omitempty"`
+}
@@ -1,343 +1,475 @@
-package memodid
-
-import (
-	"context"
-	"math/big"
-	"time"
-
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
-	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/common/hexutil"
-	"github.com/ethereum/go-ethereum/ethclient"
-	com "github.com/memoio/contractsv2/common"
-	inst "github.com/memoio/contractsv2/go_contracts/instance"
-	"github.com/memoio/did-solidity/go-contracts/proxy"
-	"golang.org/x/xerrors"
-)
-
-var DefaultContext = "https://www.w3.org/ns/did/v1"
-
-type MemoDIDResolver struct {
-	endpoint    string
-	accountAddr common.Address
-}
-
-var _ DIDResolver = &MemoDIDResolver{}
-
-func NewMemoDIDResolver(chain string) (*MemoDIDResolver, error) {
-	if chain == "" {
-		chain = com.DevChain
-	}
-
-	instanceAddr, endpoint := com.GetInsEndPointByChain(chain)
-
-	client, err := ethclient.DialContext(context.TODO(), endpoint)
-	if err != nil {
-		return nil, err
-	}
-
-	// new instanceIns
-	instanceIns, err := inst.NewInstance(instanceAddr, client)
-	if err != nil {
-		return nil, err
-	}
-
-	accountAddr, err := instanceIns.Instances(&bind.CallOpts{}, com.TypeAccountDid)
-	if err != nil {
-		return nil, err
-	}
-
-	return &MemoDIDResolver{
-		endpoint:    endpoint,
-		accountAddr: accountAddr,
-	}, nil
-}
-
-func (r *MemoDIDResolver) Resolve(didString string) (*MemoDIDDocument, error) {
-	did, err := ParseMemoDID(didString)
-	if err != nil {
-		return nil, err
-	}
-
-	client, err := ethclient.DialContext(context.TODO(), r.endpoint)
-	if err != nil {
-		return nil, err
-	}
-	defer client.Close()
-
-	accountIns, err := proxy.NewIAccountDid(r.accountAddr, client)
-	if err != nil {
-		return nil, err
-	}
-
-	dactivated, err := accountIns.IsDeactivated(&bind.CallOpts{}, did.Identifier)
-	if err != nil {
-		return nil, err
-	}
-	if dactivated {
-		return &MemoDIDDocument{}, nil
-	}
-
-	controllers, err := queryAllController(accountIns, did)
-	if err != nil {
-		return nil, err
-	}
-	verificationMethods, err := queryAllVerificationMethod(accountIns, did)
-	if err != nil {
-		return nil, err
-	}
-	authentications, err := queryAllAuthtication(accountIns, did)
-	if err != nil {
-		return nil, err
-	}
-	assertions, err := queryAllAssertion(accountIns, did)
-	if err != nil {
-		return nil, err
-	}
-	delegation, err := queryAllDelagation(accountIns, did)
-	if err != nil {
-		return nil, err
-	}
-	recovery, err := queryAllRecovery(accountIns, did)
-	if err != nil {
-		return nil, err
-	}
-
-	return &MemoDIDDocument{
-		Context:              DefaultContext,
-		ID:                   *did,
-		Controller:           controllers,
-		VerificationMethod:   verificationMethods,
-		Authentication:       authentications,
-		AssertionMethod:      assertions,
-		CapabilityDelegation: delegation,
-		Recovery:             recovery,
-	}, nil
-}
-
-func (r *MemoDIDResolver) Dereference(didUrlString string) (string, string, error) {
-	didUrl, err := ParseMemoDIDUrl(didUrlString)
-	if err != nil {
-		return "", "", err
-	}
-
-	client, err := ethclient.DialContext(context.TODO(), r.endpoint)
-	if err != nil {
-		return "", "", err
-	}
-	defer client.Close()
-
-	accountIns, err := proxy.NewIAccountDid(r.accountAddr, client)
-	if err != nil {
-		return "", "", err
-	}
-
-	verifyMethod, err := accountIns.GetVeri(&bind.CallOpts{}, didUrl.Identifier, big.NewInt(int64(didUrl.GetMethodIndex())))
-	if err != nil {
-		return "", "", err
-	}
-	if verifyMethod.Deactivated {
-		return "", "", xerrors.Errorf("The Verify Method(%s) is Deactivated", didUrl.String())
-	}
-
-	return verifyMethod.MethodType, hexutil.Encode(verifyMethod.PubKeyData), nil
-}
-
-func queryAllController(accountIns *proxy.IAccountDid, did *MemoDID) ([]MemoDID, error) {
-	controllerIter, err := accountIns.FilterAddController(&bind.FilterOpts{}, []string{did.Identifier})
-	if err != nil {
-		return nil, err
-	}
-
-	var controllers []MemoDID
-	for controllerIter.Next() {
-		// if hex.EncodeToString(controllerIter.Event.Did[:]) != did.Identifier {
-		// 	return nil, xerrors.Errorf("Got wrong did when query controller")
-		// }
-
-		// controller only supports did:memo currently, so no need to save prefix
-		controller, err := ParseMemoDID("did:memo:" + controllerIter.Event.Controller)
-		if err != nil {
-			return nil, err
-		}
-
-		// check controller is activated or not
-		activated, err := accountIns.IsController(&bind.CallOpts{}, did.Identifier, controller.Identifier)
-		if err != nil {
-			return nil, err
-		}
-		if activated {
-			controllers = append(controllers, *controller)
-		}
-	}
-
-	return controllers, nil
-}
-
-func queryAllVerificationMethod(accountIns *proxy.IAccountDid, did *MemoDID) ([]VerificationMethod, error) {
-	size, err := accountIns.GetVeriLen(&bind.CallOpts{}, did.Identifier)
-	if err != nil {
-		return nil, err
-	}
-
-	var verificationMethods []VerificationMethod
-	for i := int64(0); i < size.Int64(); i++ {
-		verificationMethodSol, err := accountIns.GetVeri(&bind.CallOpts{}, did.Identifier, big.NewInt(i))
-		if err != nil {
-			return nil, err
-		}
-		if !verificationMethodSol.Deactivated {
-			verificationMethod, err := FromSolityData(did, i, &verificationMethodSol)
-			if err != nil {
-				return nil, err
-			}
-			verificationMethods = append(verificationMethods, *verificationMethod)
-		}
-	}
-
-	return verificationMethods, nil
-}
-
-func queryAllAuthtication(accountIns *proxy.IAccountDid, did *MemoDID) ([]MemoDIDUrl, error) {
-	authIter, err := accountIns.FilterAddAuth(&bind.FilterOpts{}, []string{did.Identifier})
-	if err != nil {
-		return nil, err
-	}
-	defer authIter.Close()
-
-	var authentications []MemoDIDUrl
-	for authIter.Next() {
-		// if hex.EncodeToString(authIter.Event.Did[:]) != did.Identifier {
-		// 	return nil, xerrors.Errorf("Got wrong did when query authentication")
-		// }
-
-		// parse method id
-		didUrl, err := ParseMemoDIDUrl(authIter.Event.Id)
-		if err != nil {
-			return nil, err
-		}
-
-		// check method id is activated or not
-		activated, err := accountIns.InAuth(&bind.CallOpts{}, did.Identifier, didUrl.String())
-		if err != nil {
-			return nil, err
-		}
-		verificationMethod, err := accountIns.GetVeri(&bind.CallOpts{}, didUrl.DID().Identifier, big.NewInt(int64(didUrl.GetMethodIndex())))
-		if err != nil {
-			return nil, err
-		}
-		if activated && !verificationMethod.Deactivated {
-			authentications = append(authentications, *didUrl)
-		}
-	}
-
-	return authentications, nil
-}
-
-func queryAllAssertion(accountIns *proxy.IAccountDid, did *MemoDID) ([]MemoDIDUrl, error) {
-	assertionIter, err := accountIns.FilterAddAssertion(&bind.FilterOpts{}, []string{did.Identifier})
-	if err != nil {
-		return nil, err
-	}
-	defer assertionIter.Close()
-
-	var assertions []MemoDIDUrl
-	for assertionIter.Next() {
-		// if hex.EncodeToString(assertionIter.Event.Did[:]) != did.Identifier {
-		// 	return nil, xerrors.Errorf("Got wrong did when query assertion")
-		// }
-
-		// parse method id
-		didUrl, err := ParseMemoDIDUrl(assertionIter.Event.Id)
-		if err != nil {
-			return nil, err
-		}
-
-		// check method id is activated or not
-		activated, err := accountIns.InAssertion(&bind.CallOpts{}, did.Identifier, didUrl.String())
-		if err != nil {
-			return nil, err
-		}
-		verificationMethod, err := accountIns.GetVeri(&bind.CallOpts{}, didUrl.DID().Identifier, big.NewInt(int64(didUrl.GetMethodIndex())))
-		if err != nil {
-			return nil, err
-		}
-		if activated && !verificationMethod.Deactivated {
-			assertions = append(assertions, *didUrl)
-		}
-	}
-
-	return assertions, nil
-}
-
-func queryAllDelagation(accountIns *proxy.IAccountDid, did *MemoDID) ([]MemoDIDUrl, error) {
-	delegationIter, err := accountIns.FilterAddDelegation(&bind.FilterOpts{}, []string{did.Identifier})
-	if err != nil {
-		return nil, err
-	}
-	defer delegationIter.Close()
-
-	var delegations []MemoDIDUrl
-	for delegationIter.Next() {
-		// if hex.EncodeToString(delegationIter.Event.Did[:]) != did.Identifier {
-		// 	return nil, xerrors.Errorf("Got wrong did when query delegation")
-		// }
-
-		// parse method id
-		didUrl, err := ParseMemoDIDUrl(delegationIter.Event.Id)
-		if err != nil {
-			return nil, err
-		}
-
-		// check delegation id is expired or not
-		expiration, err := accountIns.InDelegation(&bind.CallOpts{}, did.Identifier, didUrl.String())
-		if err != nil {
-			return nil, err
-		}
-		verificationMethod, err := accountIns.GetVeri(&bind.CallOpts{}, didUrl.DID().Identifier, big.NewInt(int64(didUrl.GetMethodIndex())))
-		if err != nil {
-			return nil, err
-		}
-		if expiration.Int64() >= time.Now().Unix() && !verificationMethod.Deactivated {
-			delegations = append(delegations, *didUrl)
-		}
-	}
-
-	return delegations, nil
-}
-
-func queryAllRecovery(accountIns *proxy.IAccountDid, did *MemoDID) ([]MemoDIDUrl, error) {
-	recoveryIter, err := accountIns.FilterAddRecovery(&bind.FilterOpts{}, []string{did.Identifier})
-	if err != nil {
-		return nil, err
-	}
-	defer recoveryIter.Close()
-
-	var recovery []MemoDIDUrl
-	for recoveryIter.Next() {
-		// if hex.EncodeToString(recoveryIter.Event.Did[:]) != did.Identifier {
-		// 	return nil, xerrors.Errorf("Got wrong did when query recovery")
-		// }
-
-		// parse method id
-		didUrl, err := ParseMemoDIDUrl(recoveryIter.Event.Recovery)
-		if err != nil {
-			return nil, err
-		}
-
-		// check method id is activated or not
-		activated, err := accountIns.InRecovery(&bind.CallOpts{}, did.Identifier, didUrl.String())
-		if err != nil {
-			return nil, err
-		}
-		verificationMethod, err := accountIns.GetVeri(&bind.CallOpts{}, didUrl.DID().Identifier, big.NewInt(int64(didUrl.GetMethodIndex())))
-		if err != nil {
-			return nil, err
-		}
-		if activated && !verificationMethod.Deactivated {
-			recovery = append(recovery, *didUrl)
-		}
-	}
-
-	return recovery, nil
-}
+package memodid
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	com "github.com/memoio/contractsv2/common"
+	"github.com/memoio/did-solidity/go-contracts/proxy"
+	"golang.org/x/xerrors"
+)
+
+var DefaultContext = "https://www.w3.org/ns/did/v1"
+
+type MemoDIDResolver struct {
+	endpoint    string
+	chainID     *big.Int
+	accountAddr common.Address
+	// reverse lookup index, it is synced by each lookup
+	reverse *reverseIndex
+}
+
+var _ DIDResolver = &MemoDIDResolver{}
+
+func NewMemoDIDResolver(chain string) (*MemoDIDResolver, error) {
+	if chain == "" {
+		chain = com.DevChain
+	}
+	return NewMemoDIDResolverWithConfig(DefaultChainConfig(chain))
+}
+
+// NewMemoDIDResolverWithConfig makes a resolver of the chain described by config
+func NewMemoDIDResolverWithConfig(config *ChainConfig) (*MemoDIDResolver, error) {
+	client, err := ethclient.DialContext(context.TODO(), config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	contracts, err := config.contracts(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MemoDIDResolver{
+		endpoint:    config.Endpoint,
+		chainID:     contracts.chainID,
+		accountAddr: contracts.accountAddr,
+		reverse:     newReverseIndex(),
+	}, nil
+}
+
+// ResolveWithKeyFormat resolves did, public keys in the document are represented in format
+func (r *MemoDIDResolver) ResolveWithKeyFormat(didString string, format KeyFormat) (*MemoDIDDocument, error) {
+	document, err := r.Resolve(didString)
+	if err != nil {
+		return nil, err
+	}
+	return document.WithKeyFormat(format, r.chainID)
+}
+
+// ChainID returns the id of the chain which the resolver resolves dids on
+func (r *MemoDIDResolver) ChainID() *big.Int {
+	return r.chainID
+}
+
+// Resolve resolves did, a did with chain segment must be of the chain of the resolver,
+// and its document is the same as the one without chain segment
+func (r *MemoDIDResolver) Resolve(didString string) (*MemoDIDDocument, error) {
+	did, err := r.parseDID(didString)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := ethclient.DialContext(context.TODO(), r.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	accountIns, err := proxy.NewIAccountDid(r.accountAddr, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveDocument(accountIns, did)
+}
+
+// ResolveWithMetadata resolves did with the chain which answers and whether it is deactivated,
+// it returns ErrNotFound if did isn't registered on the chain
+func (r *MemoDIDResolver) ResolveWithMetadata(didString string) (*ResolutionResult, error) {
+	did, err := r.parseDID(didString)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := ethclient.DialContext(context.TODO(), r.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	accountIns, err := proxy.NewIAccountDid(r.accountAddr, client)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ResolutionResult{
+		ResolutionMetadata: ResolutionMetadata{
+			ContentType: ContentTypeDIDLD,
+			ChainID:     r.chainID.String(),
+		},
+	}
+	deactivated, err := accountIns.IsDeactivated(&bind.CallOpts{}, did.Identifier)
+	if err != nil {
+		return nil, err
+	}
+	if deactivated {
+		result.Document = &MemoDIDDocument{}
+		result.DocumentMetadata.Deactivated = true
+		return result, nil
+	}
+	// a registered did has master key at least
+	size, err := accountIns.GetVeriLen(&bind.CallOpts{}, did.Identifier)
+	if err != nil {
+		return nil, err
+	}
+	if size.Sign() == 0 {
+		return nil, xerrors.Errorf("%s on chain %s: %w", did.String(), r.chainID, ErrNotFound)
+	}
+
+	result.Document, err = resolveDocument(accountIns, did)
+	if err != nil {
+		return nil, err
+	}
+	var validationErrors ValidationErrors
+	if xerrors.As(result.Document.Validate(), &validationErrors) {
+		result.DocumentMetadata.ValidationErrors = validationErrors
+	}
+	return result, nil
+}
+
+// parseDID parses did and checks its chain segment, the did without chain segment is returned
+func (r *MemoDIDResolver) parseDID(didString string) (*MemoDID, error) {
+	did, err := ParseMemoDID(didString)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkChain(did.ChainID); err != nil {
+		return nil, err
+	}
+	onChain := did.withoutChain()
+	return &onChain, nil
+}
+
+func (r *MemoDIDResolver) checkChain(chainID string) error {
+	if chainID != "" && chainID != r.chainID.String() {
+		return xerrors.Errorf("did of chain %s can't be resolved on chain %s: %w", chainID, r.chainID, ErrNotFound)
+	}
+	return nil
+}
+
+func resolveDocument(accountIns *proxy.IAccountDid, did *MemoDID) (*MemoDIDDocument, error) {
+	dactivated, err := accountIns.IsDeactivated(&bind.CallOpts{}, did.Identifier)
+	if err != nil {
+		return nil, err
+	}
+	if dactivated {
+		return &MemoDIDDocument{}, nil
+	}
+
+	controllers, err := queryAllController(accountIns, did)
+	if err != nil {
+		return nil, err
+	}
+	verificationMethods, err := queryAllVerificationMethod(accountIns, did)
+	if err != nil {
+		return nil, err
+	}
+	authentications, err := queryAllAuthtication(accountIns, did)
+	if err != nil {
+		return nil, err
+	}
+	assertions, err := queryAllAssertion(accountIns, did)
+	if err != nil {
+		return nil, err
+	}
+	delegation, delegationExpiration, err := queryAllDelagation(accountIns, did)
+	if err != nil {
+		return nil, err
+	}
+	recovery, err := queryAllRecovery(accountIns, did)
+	if err != nil {
+		return nil, err
+	}
+	keyAgreements := queryAllKeyAgreement(verificationMethods)
+	invocations := queryAllInvocation(verificationMethods)
+
+	document := &MemoDIDDocument{
+		Context:              DefaultContext,
+		ID:                   *did,
+		Controller:           controllers,
+		VerificationMethod:   verificationMethods,
+		Authentication:       authentications,
+		AssertionMethod:      assertions,
+		CapabilityDelegation: delegation,
+		Recovery:             recovery,
+		KeyAgreement:         keyAgreements,
+		CapabilityInvocation: invocations,
+
+		CapabilityDelegationExpiration: delegationExpiration,
+	}
+	// output doesn't depend on the order of events.
+	// the document on chain is returned as it is even if it breaks DID Core rules,
+	// the violations are reported in metadata by ResolveWithMetadata
+	document.Normalize()
+
+	return document, nil
+}
+
+func (r *MemoDIDResolver) Dereference(didUrlString string) (string, string, error) {
+	parsed, err := ParseMemoDIDUrl(didUrlString)
+	if err != nil {
+		return "", "", err
+	}
+	if err := r.checkChain(parsed.ChainID); err != nil {
+		return "", "", err
+	}
+	didUrl := parsed.withoutChain()
+
+	client, err := ethclient.DialContext(context.TODO(), r.endpoint)
+	if err != nil {
+		return "", "", err
+	}
+	defer client.Close()
+
+	accountIns, err := proxy.NewIAccountDid(r.accountAddr, client)
+	if err != nil {
+		return "", "", err
+	}
+
+	size, err := accountIns.GetVeriLen(&bind.CallOpts{}, didUrl.Identifier)
+	if err != nil {
+		return "", "", err
+	}
+	if int64(didUrl.GetMethodIndex()) >= size.Int64() {
+		return "", "", xerrors.Errorf("The Verify Method(%s): %w", didUrl.String(), ErrNotFound)
+	}
+
+	verifyMethod, err := accountIns.GetVeri(&bind.CallOpts{}, didUrl.Identifier, big.NewInt(int64(didUrl.GetMethodIndex())))
+	if err != nil {
+		return "", "", err
+	}
+	if verifyMethod.Deactivated {
+		return "", "", xerrors.Errorf("The Verify Method(%s): %w", didUrl.String(), ErrDeactivated)
+	}
+
+	return verifyMethod.MethodType, hexutil.Encode(verifyMethod.PubKeyData), nil
+}
+
+func queryAllController(accountIns *proxy.IAccountDid, did *MemoDID) ([]MemoDID, error) {
+	controllerIter, err := accountIns.FilterAddController(&bind.FilterOpts{}, []string{did.Identifier})
+	if err != nil {
+		return nil, err
+	}
+
+	var controllers []MemoDID
+	for controllerIter.Next() {
+		// if hex.EncodeToString(controllerIter.Event.Did[:]) != did.Identifier {
+		// 	return nil, xerrors.Errorf("Got wrong did when query controller")
+		// }
+
+		// controller only supports did:memo currently, so no need to save prefix
+		controller, err := ParseMemoDID("did:memo:" + controllerIter.Event.Controller)
+		if err != nil {
+			return nil, err
+		}
+
+		// check controller is activated or not
+		activated, err := accountIns.IsController(&bind.CallOpts{}, did.Identifier, controller.Identifier)
+		if err != nil {
+			return nil, err
+		}
+		if activated {
+			controllers = append(controllers, *controller)
+		}
+	}
+
+	return controllers, nil
+}
+
+func queryAllVerificationMethod(accountIns *proxy.IAccountDid, did *MemoDID) ([]VerificationMethod, error) {
+	size, err := accountIns.GetVeriLen(&bind.CallOpts{}, did.Identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	var verificationMethods []VerificationMethod
+	for i := int64(0); i < size.Int64(); i++ {
+		verificationMethodSol, err := accountIns.GetVeri(&bind.CallOpts{}, did.Identifier, big.NewInt(i))
+		if err != nil {
+			return nil, err
+		}
+		if !verificationMethodSol.Deactivated {
+			verificationMethod, err := FromSolityData(did, i, &verificationMethodSol)
+			if err != nil {
+				return nil, err
+			}
+			verificationMethods = append(verificationMethods, *verificationMethod)
+		}
+	}
+
+	return verificationMethods, nil
+}
+
+func queryAllAuthtication(accountIns *proxy.IAccountDid, did *MemoDID) ([]MemoDIDUrl, error) {
+	authIter, err := accountIns.FilterAddAuth(&bind.FilterOpts{}, []string{did.Identifier})
+	if err != nil {
+		return nil, err
+	}
+	defer authIter.Close()
+
+	var authentications []MemoDIDUrl
+	for authIter.Next() {
+		// if hex.EncodeToString(authIter.Event.Did[:]) != did.Identifier {
+		// 	return nil, xerrors.Errorf("Got wrong did when query authentication")
+		// }
+
+		// parse method id
+		didUrl, err := ParseMemoDIDUrl(authIter.Event.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		// check method id is activated or not
+		activated, err := accountIns.InAuth(&bind.CallOpts{}, did.Identifier, didUrl.String())
+		if err != nil {
+			return nil, err
+		}
+		verificationMethod, err := accountIns.GetVeri(&bind.CallOpts{}, didUrl.DID().Identifier, big.NewInt(int64(didUrl.GetMethodIndex())))
+		if err != nil {
+			return nil, err
+		}
+		if activated && !verificationMethod.Deactivated {
+			authentications = append(authentications, *didUrl)
+		}
+	}
+
+	return authentications, nil
+}
+
+func queryAllAssertion(accountIns *proxy.IAccountDid, did *MemoDID) ([]MemoDIDUrl, error) {
+	assertionIter, err := accountIns.FilterAddAssertion(&bind.FilterOpts{}, []string{did.Identifier})
+	if err != nil {
+		return nil, err
+	}
+	defer assertionIter.Close()
+
+	var assertions []MemoDIDUrl
+	for assertionIter.Next() {
+		// if hex.EncodeToString(assertionIter.Event.Did[:]) != did.Identifier {
+		// 	return nil, xerrors.Errorf("Got wrong did when query assertion")
+		// }
+
+		// parse method id
+		didUrl, err := ParseMemoDIDUrl(assertionIter.Event.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		// check method id is activated or not
+		activated, err := accountIns.InAssertion(&bind.CallOpts{}, did.Identifier, didUrl.String())
+		if err != nil {
+			return nil, err
+		}
+		verificationMethod, err := accountIns.GetVeri(&bind.CallOpts{}, didUrl.DID().Identifier, big.NewInt(int64(didUrl.GetMethodIndex())))
+		if err != nil {
+			return nil, err
+		}
+		if activated && !verificationMethod.Deactivated {
+			assertions = append(assertions, *didUrl)
+		}
+	}
+
+	return assertions, nil
+}
+
+// queryAllDelagation returns unexpired delegations and their expiration
+func queryAllDelagation(accountIns *proxy.IAccountDid, did *MemoDID) ([]MemoDIDUrl, map[string]int64, error) {
+	all, err := queryDelegations(accountIns, did)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var delegations []MemoDIDUrl
+	var expirations map[string]int64
+	now := time.Now()
+	for _, delegation := range all {
+		if delegation.Expired(now) {
+			continue
+		}
+		if expirations == nil {
+			expirations = make(map[string]int64)
+		}
+		delegations = append(delegations, delegation.ID)
+		expirations[delegation.ID.String()] = delegation.Expiration.Unix()
+	}
+
+	return delegations, expirations, nil
+}
+
+func queryAllRecovery(accountIns *proxy.IAccountDid, did *MemoDID) ([]MemoDIDUrl, error) {
+	recoveryIter, err := accountIns.FilterAddRecovery(&bind.FilterOpts{}, []string{did.Identifier})
+	if err != nil {
+		return nil, err
+	}
+	defer recoveryIter.Close()
+
+	var recovery []MemoDIDUrl
+	for recoveryIter.Next() {
+		// if hex.EncodeToString(recoveryIter.Event.Did[:]) != did.Identifier {
+		// 	return nil, xerrors.Errorf("Got wrong did when query recovery")
+		// }
+
+		// parse method id
+		didUrl, err := ParseMemoDIDUrl(recoveryIter.Event.Recovery)
+		if err != nil {
+			return nil, err
+		}
+
+		// check method id is activated or not
+		activated, err := accountIns.InRecovery(&bind.CallOpts{}, did.Identifier, didUrl.String())
+		if err != nil {
+			return nil, err
+		}
+		verificationMethod, err := accountIns.GetVeri(&bind.CallOpts{}, didUrl.DID().Identifier, big.NewInt(int64(didUrl.GetMethodIndex())))
+		if err != nil {
+			return nil, err
+		}
+		if activated && !verificationMethod.Deactivated {
+			recovery = append(recovery, *didUrl)
+		}
+	}
+
+	return recovery, nil
+}
+
+// keyAgreement is not recorded by AccountDid contract, all activated x25519 methods are used for key agreement
+func queryAllKeyAgreement(verificationMethods []VerificationMethod) []MemoDIDUrl {
+	var keyAgreements []MemoDIDUrl
+	for _, verificationMethod := range verificationMethods {
+		if isX25519Type(verificationMethod.Type) {
+			keyAgreements = append(keyAgreements, verificationMethod.ID)
+		}
+	}
+
+	return keyAgreements
+}
+
+// capabilityInvocation is not recorded by AccountDid contract, the activated masterKey
+// is the only method that the contract accepts to invoke updates of the document
+func queryAllInvocation(verificationMethods []VerificationMethod) []MemoDIDUrl {
+	var invocations []MemoDIDUrl
+	for _, verificationMethod := range verificationMethods {
+		if verificationMethod.ID.GetMethodIndex() == 0 {
+			invocations = append(invocations, verificationMethod.ID)
+		}
+	}
+
+	return invocations
+}
@@ -0,0 +1,192 @@
+package memodid
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/memoio/did-solidity/go-contracts/proxy"
+	"golang.org/x/xerrors"
+)
+
+// TxResult is the result of a controller operation
+type TxResult struct {
+	TxHash      common.Hash
+	BlockNumber uint64
+	GasUsed     uint64
+	Receipt     *types.Receipt
+	// events emitted by AccountDid contract
+	Events []TxEvent
+
+	// the verification method added by AddVerificationMethod, nil if it can't be found
+	DIDUrl *MemoDIDUrl
+}
+
+// TxEvent is a decoded event log, indexed string arguments are hashes
+type TxEvent struct {
+	Name string
+	Args map[string]interface{}
+}
+
+// txRequest is the transaction of a controller operation
+type txRequest struct {
+	name string
+	send txSender
+	// complete fills the result after the transaction is confirmed, such as the added DID URL,
+	// it can't fail since the transaction is already done
+	complete func(client *ethclient.Client, result *TxResult)
+}
+
+// txBuilder checks the operation with client and makes its transaction,
+// the request is nil if no transaction is needed
+type txBuilder func(client *ethclient.Client) (*txRequest, error)
+
+// transact sends the transaction of the operation and returns its result
+func (c *MemoDIDController) transact(build txBuilder) (*TxResult, error) {
+	client, err := ethclient.DialContext(context.TODO(), c.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	request, err := build(client)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return &TxResult{}, nil
+	}
+
+	receipt, err := c.sendTx(client, request.name, request.send)
+	if err != nil {
+		return nil, err
+	}
+	return completeTx(client, request, receipt), nil
+}
+
+// completeTx makes the result of the confirmed transaction, it never fails
+// and parts which can't be known are left empty
+func completeTx(client *ethclient.Client, request *txRequest, receipt *types.Receipt) *TxResult {
+	result := newTxResult(receipt)
+	if request.complete != nil {
+		request.complete(client, result)
+	}
+	return result
+}
+
+// newTxResult makes the result of receipt with events of AccountDid contract decoded
+func newTxResult(receipt *types.Receipt) *TxResult {
+	result := &TxResult{
+		TxHash:      receipt.TxHash,
+		BlockNumber: receipt.BlockNumber.Uint64(),
+		GasUsed:     receipt.GasUsed,
+		Receipt:     receipt,
+	}
+	if accountABI, err := proxy.IAccountDidMetaData.GetAbi(); err == nil {
+		result.Events = decodeEvents(accountABI, receipt.Logs)
+	}
+	return result
+}
+
+// decodeEvents decodes logs of events in contractABI, logs of other events
+// and logs which can't be decoded are skipped
+func decodeEvents(contractABI *abi.ABI, logs []*types.Log) []TxEvent {
+	var events []TxEvent
+	for _, log := range logs {
+		if len(log.Topics) == 0 {
+			continue
+		}
+		event, err := contractABI.EventByID(log.Topics[0])
+		if err != nil {
+			continue
+		}
+
+		args := make(map[string]interface{})
+		if len(log.Data) > 0 {
+			if err := contractABI.UnpackIntoMap(args, event.Name, log.Data); err != nil {
+				continue
+			}
+		}
+		var indexed abi.Arguments
+		for _, arg := range event.Inputs {
+			if arg.Indexed {
+				indexed = append(indexed, arg)
+			}
+		}
+		if err := abi.ParseTopicsIntoMap(args, indexed, log.Topics[1:]); err != nil {
+			continue
+		}
+
+		events = append(events, TxEvent{Name: event.Name, Args: args})
+	}
+	return events
+}
+
+// addedMethod returns the verification method added to did by the transaction of result,
+// it is nil if the method can't be found
+func (c *MemoDIDController) addedMethod(client *ethclient.Client, did MemoDID, publicKey proxy.IAccountDidPublicKey, result *TxResult) *MemoDIDUrl {
+	if index, ok := addedMethodIndex(did, result.Events); ok {
+		didUrl, err := did.DIDUrl(index)
+		if err == nil {
+			return didUrl
+		}
+	}
+
+	didUrl, err := c.searchAddedMethod(client, did, publicKey, result.Receipt)
+	if err != nil {
+		return nil
+	}
+	return didUrl
+}
+
+// addedMethodIndex returns the index in the AddVeri event of did
+func addedMethodIndex(did MemoDID, events []TxEvent) (int64, bool) {
+	didHash := crypto.Keccak256Hash([]byte(did.Identifier))
+	for _, event := range events {
+		if event.Name != "AddVeri" || event.Args["did"] != didHash {
+			continue
+		}
+		if index, ok := event.Args["index"].(*big.Int); ok && index.IsInt64() {
+			return index.Int64(), true
+		}
+	}
+	return 0, false
+}
+
+// searchAddedMethod finds the verification method added to did in the block of receipt with historical state,
+// methods added by other transactions in the same block are told apart by their content
+func (c *MemoDIDController) searchAddedMethod(client *ethclient.Client, did MemoDID, publicKey proxy.IAccountDidPublicKey, receipt *types.Receipt) (*MemoDIDUrl, error) {
+	accountIns, err := proxy.NewIAccountDid(c.accountAddr, client)
+	if err != nil {
+		return nil, err
+	}
+
+	block := receipt.BlockNumber
+	before, err := accountIns.GetVeriLen(&bind.CallOpts{Context: context.TODO(), BlockNumber: new(big.Int).Sub(block, big.NewInt(1))}, did.Identifier)
+	if err != nil {
+		return nil, err
+	}
+	after, err := accountIns.GetVeriLen(&bind.CallOpts{Context: context.TODO(), BlockNumber: block}, did.Identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := after.Int64() - 1; i >= before.Int64(); i-- {
+		method, err := accountIns.GetVeri(&bind.CallOpts{Context: context.TODO(), BlockNumber: block}, did.Identifier, big.NewInt(i))
+		if err != nil {
+			return nil, err
+		}
+		if method.MethodType == publicKey.MethodType && strings.EqualFold(method.Controller, publicKey.Controller) &&
+			bytes.Equal(method.PubKeyData, publicKey.PubKeyData) {
+			return did.DIDUrl(i)
+		}
+	}
+	return nil, xerrors.Errorf("verification method added by transaction(%s): %w", receipt.TxHash, ErrNotFound)
+}
@@ -0,0 +1,90 @@
+package memodid
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const testEventABI = `[{"type":"event","name":"AddDelegation","anonymous":false,"inputs":[
+	{"name":"did","type":"string","indexed":true},
+	{"name":"id","type":"string","indexed":false},
+	{"name":"expiration","type":"uint256","indexed":false}]},
+	{"type":"event","name":"AddVeri","anonymous":false,"inputs":[
+	{"name":"did","type":"string","indexed":true},
+	{"name":"index","type":"uint256","indexed":false}]}]`
+
+func TestDecodeEvents(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(testEventABI))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	event := contractABI.Events["AddDelegation"]
+	identifier := "ce5ac89f84530a1cf2cdee5a0643045a8b0a4995b1c765ba289d7859cfb1193e"
+	data, err := event.Inputs.NonIndexed().Pack("did:memo:"+identifier+"#key-1", common.Big1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	logs := []*types.Log{
+		// log of another contract
+		{Topics: []common.Hash{crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))}},
+		{Topics: []common.Hash{event.ID, crypto.Keccak256Hash([]byte(identifier))}, Data: data},
+		// log which can't be decoded
+		{Topics: []common.Hash{event.ID, crypto.Keccak256Hash([]byte(identifier))}, Data: data[:32]},
+	}
+
+	events := decodeEvents(&contractABI, logs)
+	if len(events) != 1 || events[0].Name != "AddDelegation" {
+		t.Errorf("Unexpect events: %v", events)
+		return
+	}
+	args := events[0].Args
+	if args["id"] != "did:memo:"+identifier+"#key-1" || args["did"] != crypto.Keccak256Hash([]byte(identifier)) {
+		t.Errorf("Unexpect event arguments: %v", args)
+	}
+}
+
+func TestAddedMethodIndex(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(testEventABI))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	event := contractABI.Events["AddVeri"]
+	data, err := event.Inputs.NonIndexed().Pack(big.NewInt(3))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document, err := genDocument(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	did := document.ID
+	logs := []*types.Log{
+		{Topics: []common.Hash{event.ID, crypto.Keccak256Hash([]byte(did.Identifier))}, Data: data},
+	}
+
+	index, ok := addedMethodIndex(did, decodeEvents(&contractABI, logs))
+	if !ok || index != 3 {
+		t.Errorf("Unexpect index of added method: %d", index)
+		return
+	}
+
+	other, err := genDocument(globalPrivateKey2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if _, ok := addedMethodIndex(other.ID, decodeEvents(&contractABI, logs)); ok {
+		t.Error("Index of other did should not be found")
+	}
+}
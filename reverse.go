@@ -0,0 +1,594 @@
+package memodid
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/memoio/did-solidity/go-contracts/proxy"
+	"golang.org/x/xerrors"
+)
+
+// QueryDIDsByController returns all activated DIDs whose controller property contains controllerString
+func (r *MemoDIDResolver) QueryDIDsByController(controllerString string) ([]MemoDID, error) {
+	controller, err := ParseMemoDID(controllerString)
+	if err != nil {
+		return nil, err
+	}
+
+	backend, err := r.reverseBackend()
+	if err != nil {
+		return nil, err
+	}
+	defer backend.close()
+
+	index := r.reverseIndex()
+	if err := index.sync(backend, nil); err != nil {
+		return nil, err
+	}
+	return index.controlledDIDs(backend, controller)
+}
+
+// QueryMethodsByController returns all activated verification methods whose controller is controllerString
+func (r *MemoDIDResolver) QueryMethodsByController(controllerString string) ([]VerificationMethod, error) {
+	controller, err := ParseMemoDID(controllerString)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.queryMethods(nil, func(method *VerificationMethod) bool {
+		return method.Controller.Identifier == controller.Identifier
+	})
+}
+
+// QueryMethodsByPublicKey returns all activated verification methods whose public key is publicKeyHex
+func (r *MemoDIDResolver) QueryMethodsByPublicKey(publicKeyHex string) ([]VerificationMethod, error) {
+	publicKey, err := decodeHexKey(publicKeyHex)
+	if err != nil {
+		return nil, err
+	}
+
+	// the key may be the master key of a did created by its own address
+	var address *common.Address
+	if addr, ok := publicKeyToAddress(publicKey); ok {
+		address = &addr
+	}
+
+	return r.queryMethods(address, func(method *VerificationMethod) bool {
+		key, err := method.PublicKeyBytes()
+		return err == nil && bytes.Equal(key, publicKey)
+	})
+}
+
+// QueryMethodsByAddress returns all activated secp256k1 verification methods whose public key maps to address
+func (r *MemoDIDResolver) QueryMethodsByAddress(address common.Address) ([]VerificationMethod, error) {
+	return r.queryMethods(&address, func(method *VerificationMethod) bool {
+		return methodHasAddress(method, address)
+	})
+}
+
+// QueryDIDsByAddress returns all DIDs held by address: DIDs having a verification method
+// whose key maps to address, and DIDs controlled by them directly or indirectly
+func (r *MemoDIDResolver) QueryDIDsByAddress(address common.Address) ([]MemoDID, error) {
+	backend, err := r.reverseBackend()
+	if err != nil {
+		return nil, err
+	}
+	defer backend.close()
+
+	index := r.reverseIndex()
+	if err := index.sync(backend, &address); err != nil {
+		return nil, err
+	}
+	return index.didsByAddress(backend, address)
+}
+
+func (r *MemoDIDResolver) queryMethods(address *common.Address, match func(*VerificationMethod) bool) ([]VerificationMethod, error) {
+	backend, err := r.reverseBackend()
+	if err != nil {
+		return nil, err
+	}
+	defer backend.close()
+
+	index := r.reverseIndex()
+	if err := index.sync(backend, address); err != nil {
+		return nil, err
+	}
+	return index.methods(backend, address, match)
+}
+
+func (r *MemoDIDResolver) reverseBackend() (*chainReverseBackend, error) {
+	client, err := ethclient.DialContext(context.TODO(), r.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	accountIns, err := proxy.NewIAccountDid(r.accountAddr, client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &chainReverseBackend{client: client, accountIns: accountIns}, nil
+}
+
+// reverseIndex returns the index kept by the resolver, resolvers made without
+// constructor have no index and scan the chain from the first block each time
+func (r *MemoDIDResolver) reverseIndex() *reverseIndex {
+	if r.reverse == nil {
+		return newReverseIndex()
+	}
+	return r.reverse
+}
+
+// reverseBackend is the chain data used by reverse lookup
+type reverseBackend interface {
+	blockNumber() (uint64, error)
+	// nonceAt returns the nonce of address in the latest block
+	nonceAt(address common.Address) (uint64, error)
+	// controllerEvents returns AddController events in blocks [from, to]
+	controllerEvents(from, to uint64) ([]controllerEvent, error)
+	// referencedDIDs returns the identifiers of DIDs in relationship events in blocks [from, to]
+	referencedDIDs(from, to uint64) ([]string, error)
+	veriLen(did string) (int64, error)
+	isDeactivated(did string) (bool, error)
+	isController(did, controller string) (bool, error)
+	verificationMethods(did *MemoDID) ([]VerificationMethod, error)
+}
+
+// controllerEvent is an AddController event, did is indexed so only its hash is recorded.
+// identifier is the did decoded from the transaction, it is empty if the transaction
+// doesn't call the proxy directly, such as calls through a multisig wallet or a relayer.
+type controllerEvent struct {
+	didHash    common.Hash
+	identifier string
+	controller string
+}
+
+// reverseIndexPage is the number of blocks scanned by each event query
+const reverseIndexPage = 5000
+
+// reverseIndex keeps DIDs found in events and DIDs created by addresses.
+// It is synced incrementally, so each lookup only scans the blocks and nonces after the former one.
+type reverseIndex struct {
+	lock sync.Mutex
+	page uint64
+	// the next block to scan
+	next uint64
+	// controller identifier -> identifiers of DIDs which it is added to
+	controlled map[string][]string
+	// hash of did -> controllers added to the did which is not known yet
+	unresolved map[common.Hash][]string
+	// hash of did -> identifier of DIDs known by the index
+	hashes map[common.Hash]string
+	// identifiers referenced by events in order of appearance
+	known []string
+	seen  map[string]bool
+	// nonces of address whose DIDs are checked, and the registered DIDs among them
+	checked map[common.Address]uint64
+	created map[common.Address][]string
+}
+
+func newReverseIndex() *reverseIndex {
+	return &reverseIndex{
+		page:       reverseIndexPage,
+		controlled: make(map[string][]string),
+		unresolved: make(map[common.Hash][]string),
+		hashes:     make(map[common.Hash]string),
+		seen:       make(map[string]bool),
+		checked:    make(map[common.Address]uint64),
+		created:    make(map[common.Address][]string),
+	}
+}
+
+// sync scans the blocks after the last synced one in pages, and checks the DIDs created
+// by address with nonces after the last checked one. A did is regarded as created by
+// address with nonce n if it is registered after the transaction with nonce n is packaged.
+func (x *reverseIndex) sync(backend reverseBackend, address *common.Address) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	head, err := backend.blockNumber()
+	if err != nil {
+		return err
+	}
+	for x.next <= head {
+		to := x.next + x.page - 1
+		if to > head {
+			to = head
+		}
+		events, err := backend.controllerEvents(x.next, to)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			x.add(event.controller)
+			if event.identifier != "" {
+				x.addControlled(event.identifier, event.controller)
+			} else if identifier, ok := x.hashes[event.didHash]; ok {
+				x.addControlled(identifier, event.controller)
+			} else {
+				x.unresolved[event.didHash] = append(x.unresolved[event.didHash], event.controller)
+			}
+		}
+		referenced, err := backend.referencedDIDs(x.next, to)
+		if err != nil {
+			return err
+		}
+		for _, identifier := range referenced {
+			x.add(identifier)
+		}
+		x.next = to + 1
+	}
+
+	if address == nil {
+		return nil
+	}
+	nonce, err := backend.nonceAt(*address)
+	if err != nil {
+		return err
+	}
+	for n := x.checked[*address]; n < nonce; n++ {
+		identifier := memoIdentifier(*address, n)
+		size, err := backend.veriLen(identifier)
+		if err != nil {
+			return err
+		}
+		if size > 0 {
+			x.created[*address] = append(x.created[*address], identifier)
+			x.resolve(identifier)
+		}
+		x.checked[*address] = n + 1
+	}
+	return nil
+}
+
+func (x *reverseIndex) add(identifier string) {
+	if !x.seen[identifier] {
+		x.seen[identifier] = true
+		x.known = append(x.known, identifier)
+		x.resolve(identifier)
+	}
+}
+
+func (x *reverseIndex) addControlled(identifier, controller string) {
+	if !contains(x.controlled[controller], identifier) {
+		x.controlled[controller] = append(x.controlled[controller], identifier)
+	}
+}
+
+// resolve records the hash of identifier, and adds the controllers in events of it
+// which were found before identifier is known
+func (x *reverseIndex) resolve(identifier string) {
+	hash := crypto.Keccak256Hash([]byte(identifier))
+	if _, ok := x.hashes[hash]; ok {
+		return
+	}
+	x.hashes[hash] = identifier
+	for _, controller := range x.unresolved[hash] {
+		x.addControlled(identifier, controller)
+	}
+	delete(x.unresolved, hash)
+}
+
+// candidates returns the DIDs created by address and referenced by events
+func (x *reverseIndex) candidates(address *common.Address) []string {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	var identifiers []string
+	if address != nil {
+		identifiers = append(identifiers, x.created[*address]...)
+	}
+	for _, identifier := range x.known {
+		if !contains(identifiers, identifier) {
+			identifiers = append(identifiers, identifier)
+		}
+	}
+	return identifiers
+}
+
+// controlledDIDs returns all activated DIDs controlled by controller
+func (x *reverseIndex) controlledDIDs(backend reverseBackend, controller *MemoDID) ([]MemoDID, error) {
+	x.lock.Lock()
+	identifiers := append([]string{}, x.controlled[controller.Identifier]...)
+	x.lock.Unlock()
+
+	var dids []MemoDID
+	for _, identifier := range identifiers {
+		did, err := ParseMemoDID("did:memo:" + identifier)
+		if err != nil {
+			continue
+		}
+
+		// check controller is activated or not
+		activated, err := backend.isController(did.Identifier, controller.Identifier)
+		if err != nil {
+			return nil, err
+		}
+		deactivated, err := backend.isDeactivated(did.Identifier)
+		if err != nil {
+			return nil, err
+		}
+		if activated && !deactivated {
+			dids = append(dids, *did)
+		}
+	}
+	return dids, nil
+}
+
+// methods returns activated verification methods of registered and activated DIDs which match
+func (x *reverseIndex) methods(backend reverseBackend, address *common.Address, match func(*VerificationMethod) bool) ([]VerificationMethod, error) {
+	var methods []VerificationMethod
+	for _, identifier := range x.candidates(address) {
+		did, err := ParseMemoDID("did:memo:" + identifier)
+		if err != nil {
+			continue
+		}
+
+		// ignore unregistered and deactivated dids
+		size, err := backend.veriLen(did.Identifier)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			continue
+		}
+		deactivated, err := backend.isDeactivated(did.Identifier)
+		if err != nil {
+			return nil, err
+		}
+		if deactivated {
+			continue
+		}
+
+		verificationMethods, err := backend.verificationMethods(did)
+		if err != nil {
+			return nil, err
+		}
+		for i := range verificationMethods {
+			if match(&verificationMethods[i]) {
+				methods = append(methods, verificationMethods[i])
+			}
+		}
+	}
+	return methods, nil
+}
+
+func (x *reverseIndex) didsByAddress(backend reverseBackend, address common.Address) ([]MemoDID, error) {
+	methods, err := x.methods(backend, &address, func(method *VerificationMethod) bool {
+		return methodHasAddress(method, address)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var dids []MemoDID
+	visited := make(map[string]bool)
+	for _, method := range methods {
+		did := method.ID.DID()
+		if !visited[did.Identifier] {
+			visited[did.Identifier] = true
+			dids = append(dids, did)
+		}
+	}
+
+	// controlled dids, visited prevents loops in the controller graph
+	for i := 0; i < len(dids); i++ {
+		controlled, err := x.controlledDIDs(backend, &dids[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, did := range controlled {
+			if !visited[did.Identifier] {
+				visited[did.Identifier] = true
+				dids = append(dids, did)
+			}
+		}
+	}
+
+	return dids, nil
+}
+
+func methodHasAddress(method *VerificationMethod, address common.Address) bool {
+	publicKey, err := method.PublicKeyBytes()
+	if err != nil {
+		return false
+	}
+	addr, ok := publicKeyToAddress(publicKey)
+	return ok && addr == address
+}
+
+func contains(identifiers []string, identifier string) bool {
+	for _, id := range identifiers {
+		if id == identifier {
+			return true
+		}
+	}
+	return false
+}
+
+// chainReverseBackend reads reverse lookup data from the AccountDid contract
+type chainReverseBackend struct {
+	client     *ethclient.Client
+	accountIns *proxy.IAccountDid
+}
+
+func (b *chainReverseBackend) close() {
+	b.client.Close()
+}
+
+func (b *chainReverseBackend) blockNumber() (uint64, error) {
+	return b.client.BlockNumber(context.TODO())
+}
+
+func (b *chainReverseBackend) nonceAt(address common.Address) (uint64, error) {
+	return b.client.NonceAt(context.TODO(), address, nil)
+}
+
+func (b *chainReverseBackend) controllerEvents(from, to uint64) ([]controllerEvent, error) {
+	controllerIter, err := b.accountIns.FilterAddController(&bind.FilterOpts{Start: from, End: &to}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer controllerIter.Close()
+
+	var events []controllerEvent
+	for controllerIter.Next() {
+		event := controllerEvent{
+			didHash:    controllerIter.Event.Did,
+			controller: controllerIter.Event.Controller,
+		}
+		// did is only known from the input of a direct proxy call, other dids are matched by hash in index
+		identifier, err := queryDIDFromTx(b.client, controllerIter.Event.Raw.TxHash)
+		if err == nil && crypto.Keccak256Hash([]byte(identifier)) == event.didHash {
+			event.identifier = identifier
+		}
+		events = append(events, event)
+	}
+	return events, controllerIter.Error()
+}
+
+func (b *chainReverseBackend) referencedDIDs(from, to uint64) ([]string, error) {
+	opts := &bind.FilterOpts{Start: from, End: &to}
+	var identifiers []string
+	addDIDUrl := func(didUrlString string) {
+		if didUrl, err := ParseMemoDIDUrl(didUrlString); err == nil {
+			identifiers = append(identifiers, didUrl.Identifier)
+		}
+	}
+
+	authIter, err := b.accountIns.FilterAddAuth(opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer authIter.Close()
+	for authIter.Next() {
+		addDIDUrl(authIter.Event.Id)
+	}
+	if err := authIter.Error(); err != nil {
+		return nil, err
+	}
+
+	assertionIter, err := b.accountIns.FilterAddAssertion(opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer assertionIter.Close()
+	for assertionIter.Next() {
+		addDIDUrl(assertionIter.Event.Id)
+	}
+	if err := assertionIter.Error(); err != nil {
+		return nil, err
+	}
+
+	delegationIter, err := b.accountIns.FilterAddDelegation(opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer delegationIter.Close()
+	for delegationIter.Next() {
+		addDIDUrl(delegationIter.Event.Id)
+	}
+	if err := delegationIter.Error(); err != nil {
+		return nil, err
+	}
+
+	recoveryIter, err := b.accountIns.FilterAddRecovery(opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer recoveryIter.Close()
+	for recoveryIter.Next() {
+		addDIDUrl(recoveryIter.Event.Recovery)
+	}
+	if err := recoveryIter.Error(); err != nil {
+		return nil, err
+	}
+
+	return identifiers, nil
+}
+
+func (b *chainReverseBackend) veriLen(did string) (int64, error) {
+	size, err := b.accountIns.GetVeriLen(&bind.CallOpts{}, did)
+	if err != nil {
+		return 0, err
+	}
+	return size.Int64(), nil
+}
+
+func (b *chainReverseBackend) isDeactivated(did string) (bool, error) {
+	return b.accountIns.IsDeactivated(&bind.CallOpts{}, did)
+}
+
+func (b *chainReverseBackend) isController(did, controller string) (bool, error) {
+	return b.accountIns.IsController(&bind.CallOpts{}, did, controller)
+}
+
+func (b *chainReverseBackend) verificationMethods(did *MemoDID) ([]VerificationMethod, error) {
+	return queryAllVerificationMethod(b.accountIns, did)
+}
+
+// queryDIDFromTx returns the did argument of a proxy transaction
+func queryDIDFromTx(client *ethclient.Client, txHash common.Hash) (string, error) {
+	tx, _, err := client.TransactionByHash(context.TODO(), txHash)
+	if err != nil {
+		return "", err
+	}
+
+	proxyABI, err := proxy.ProxyMetaData.GetAbi()
+	if err != nil {
+		return "", err
+	}
+
+	data := tx.Data()
+	if len(data) < 4 {
+		return "", xerrors.Errorf("transaction(%s) is not a proxy call", txHash)
+	}
+	method, err := proxyABI.MethodById(data[:4])
+	if err != nil {
+		return "", err
+	}
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return "", err
+	}
+	if len(args) == 0 {
+		return "", xerrors.Errorf("transaction(%s) has no did argument", txHash)
+	}
+	did, ok := args[0].(string)
+	if !ok {
+		return "", xerrors.Errorf("transaction(%s) has no did argument", txHash)
+	}
+
+	return did, nil
+}
+
+// memoIdentifier returns memo-specific-id of the did created by address with nonce
+func memoIdentifier(address common.Address, nonce uint64) string {
+	return hex.EncodeToString(crypto.Keccak256(binary.AppendUvarint(address.Bytes(), nonce)))
+}
+
+// publicKeyToAddress converts compressed or uncompressed secp256k1 public key to address
+func publicKeyToAddress(publicKey []byte) (common.Address, bool) {
+	switch len(publicKey) {
+	case 33:
+		pk, err := crypto.DecompressPubkey(publicKey)
+		if err != nil {
+			return common.Address{}, false
+		}
+		return crypto.PubkeyToAddress(*pk), true
+	case 65:
+		pk, err := crypto.UnmarshalPubkey(publicKey)
+		if err != nil {
+			return common.Address{}, false
+		}
+		return crypto.PubkeyToAddress(*pk), true
+	default:
+		return common.Address{}, false
+	}
+}
@@ -0,0 +1,225 @@
+package memodid
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestPublicKeyToAddress(t *testing.T) {
+	privateKey, err := crypto.HexToECDSA(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	expected := common.HexToAddress("0xe89971bfeEA7381d47fE608d676dfb5440F0fD2E")
+
+	address, ok := publicKeyToAddress(crypto.CompressPubkey(&privateKey.PublicKey))
+	if !ok || address != expected {
+		t.Errorf("Unexpect address of compressed public key: %s", address)
+	}
+
+	address, ok = publicKeyToAddress(crypto.FromECDSAPub(&privateKey.PublicKey))
+	if !ok || address != expected {
+		t.Errorf("Unexpect address of uncompressed public key: %s", address)
+	}
+
+	_, ok = publicKeyToAddress([]byte("hello"))
+	if ok {
+		t.Error("Converting an invalid public key should fail")
+	}
+}
+
+func TestMemoIdentifier(t *testing.T) {
+	address := common.HexToAddress("0xe89971bfeEA7381d47fE608d676dfb5440F0fD2E")
+
+	identifier := memoIdentifier(address, 0)
+	if _, err := hex.DecodeString(identifier); err != nil || len(identifier) != 64 {
+		t.Errorf("%s is not 32 byte hex string", identifier)
+	}
+	if memoIdentifier(address, 1) == identifier {
+		t.Error("DIDs created with different nonce should be different")
+	}
+}
+
+// mockReverseBackend has events in blocks [0, head],
+// AddController events of relayed dids can't be decoded from their transactions
+type mockReverseBackend struct {
+	head        uint64
+	nonces      map[common.Address]uint64
+	controllers map[uint64][][2]string
+	relayed     map[string]bool
+	referenced  map[uint64][]string
+	methods     map[string][]VerificationMethod
+	deactivated map[string]bool
+	removed     map[[2]string]bool
+
+	pages     [][2]uint64
+	veriCalls int
+}
+
+func (b *mockReverseBackend) blockNumber() (uint64, error) {
+	return b.head, nil
+}
+
+func (b *mockReverseBackend) nonceAt(address common.Address) (uint64, error) {
+	return b.nonces[address], nil
+}
+
+func (b *mockReverseBackend) controllerEvents(from, to uint64) ([]controllerEvent, error) {
+	b.pages = append(b.pages, [2]uint64{from, to})
+	var events []controllerEvent
+	for block := from; block <= to; block++ {
+		for _, event := range b.controllers[block] {
+			did, controller := event[0], event[1]
+			controllerEvent := controllerEvent{didHash: crypto.Keccak256Hash([]byte(did)), controller: controller}
+			if !b.relayed[did] {
+				controllerEvent.identifier = did
+			}
+			events = append(events, controllerEvent)
+		}
+	}
+	return events, nil
+}
+
+func (b *mockReverseBackend) referencedDIDs(from, to uint64) ([]string, error) {
+	var identifiers []string
+	for block := from; block <= to; block++ {
+		identifiers = append(identifiers, b.referenced[block]...)
+	}
+	return identifiers, nil
+}
+
+func (b *mockReverseBackend) veriLen(did string) (int64, error) {
+	b.veriCalls++
+	return int64(len(b.methods[did])), nil
+}
+
+func (b *mockReverseBackend) isDeactivated(did string) (bool, error) {
+	return b.deactivated[did], nil
+}
+
+func (b *mockReverseBackend) isController(did, controller string) (bool, error) {
+	return !b.removed[[2]string{did, controller}], nil
+}
+
+func (b *mockReverseBackend) verificationMethods(did *MemoDID) ([]VerificationMethod, error) {
+	return b.methods[did.Identifier], nil
+}
+
+func TestQueryDIDsByAddress(t *testing.T) {
+	privateKey, err := crypto.HexToECDSA(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	otherKey, err := crypto.HexToECDSA(globalPrivateKey2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	address := crypto.PubkeyToAddress(privateKey.PublicKey)
+	method := func(identifier string, index int64, key *ecdsa.PrivateKey) VerificationMethod {
+		did := MemoDID{Method: "memo", Identifier: identifier, Identifiers: []string{identifier}}
+		id, _ := did.DIDUrl(index)
+		return VerificationMethod{
+			ID:           *id,
+			Controller:   did,
+			Type:         EcdsaSecp256k1VerificationKey2019,
+			PublicKeyHex: hexutil.Encode(crypto.CompressPubkey(&key.PublicKey)),
+		}
+	}
+
+	// did1 is created by address, did4 has a key of address,
+	// did2 is controlled by did1 and controls did3 which is deactivated,
+	// did5 was controlled by did1, did6 is controlled by did1 through a relayer
+	// and is known after it is referenced, did7 is never known
+	did1 := memoIdentifier(address, 0)
+	did2 := hex.EncodeToString(crypto.Keccak256([]byte("did2")))
+	did3 := hex.EncodeToString(crypto.Keccak256([]byte("did3")))
+	did4 := hex.EncodeToString(crypto.Keccak256([]byte("did4")))
+	did5 := hex.EncodeToString(crypto.Keccak256([]byte("did5")))
+	did6 := hex.EncodeToString(crypto.Keccak256([]byte("did6")))
+	did7 := hex.EncodeToString(crypto.Keccak256([]byte("did7")))
+	backend := &mockReverseBackend{
+		head:   9,
+		nonces: map[common.Address]uint64{address: 2},
+		controllers: map[uint64][][2]string{
+			3: {{did2, did1}, {did5, did1}, {did6, did1}, {did7, did1}},
+			7: {{did3, did2}},
+		},
+		relayed:    map[string]bool{did6: true, did7: true},
+		referenced: map[uint64][]string{8: {did4}, 9: {did6}},
+		methods: map[string][]VerificationMethod{
+			did1: {method(did1, 0, privateKey)},
+			did2: {method(did2, 0, otherKey)},
+			did3: {method(did3, 0, privateKey)},
+			did4: {method(did4, 0, otherKey), method(did4, 1, privateKey)},
+			did5: {method(did5, 0, otherKey)},
+			did6: {method(did6, 0, otherKey)},
+			did7: {method(did7, 0, otherKey)},
+		},
+		deactivated: map[string]bool{did3: true},
+		removed:     map[[2]string]bool{{did5, did1}: true},
+	}
+
+	index := newReverseIndex()
+	index.page = 4
+	if err := index.sync(backend, &address); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	dids, err := index.didsByAddress(backend, address)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	var identifiers []string
+	for _, did := range dids {
+		identifiers = append(identifiers, did.Identifier)
+	}
+	if strings.Join(identifiers, ",") != strings.Join([]string{did1, did4, did2, did6}, ",") {
+		t.Errorf("Unexpect dids: %v", identifiers)
+		return
+	}
+	if len(backend.pages) != 3 || backend.pages[0] != [2]uint64{0, 3} || backend.pages[2] != [2]uint64{8, 9} {
+		t.Errorf("Unexpect pages: %v", backend.pages)
+		return
+	}
+
+	// synced blocks and nonces are not scanned again
+	backend.pages = nil
+	backend.veriCalls = 0
+	if err := index.sync(backend, &address); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(backend.pages) != 0 || backend.veriCalls != 0 {
+		t.Errorf("Synced data is scanned again: %v, %d", backend.pages, backend.veriCalls)
+		return
+	}
+
+	backend.head = 10
+	backend.controllers[10] = [][2]string{{did3, did4}}
+	if err := index.sync(backend, nil); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(backend.pages) != 1 || backend.pages[0] != [2]uint64{10, 10} {
+		t.Errorf("Unexpect pages: %v", backend.pages)
+		return
+	}
+	controlled, err := index.controlledDIDs(backend, &MemoDID{Method: "memo", Identifier: did1})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(controlled) != 2 || controlled[0].Identifier != did2 || controlled[1].Identifier != did6 {
+		t.Errorf("Unexpect controlled dids: %v", controlled)
+	}
+}
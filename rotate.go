@@ -0,0 +1,284 @@
+package memodid
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"golang.org/x/xerrors"
+)
+
+// KeyRotation is the state of a key rotation, it can be kept to resume or roll back a rotation that failed halfway
+type KeyRotation struct {
+	DID MemoDID
+	Old MemoDIDUrl
+	// New is nil until the new key is added
+	New *MemoDIDUrl
+
+	// controller of the old and the new method
+	Controller   MemoDID
+	Type         string
+	PublicKeyHex string
+
+	// masterKey is rotated in place, the old key is kept for rollback
+	OldType         string
+	OldPublicKeyHex string
+
+	// relationships of the old key, which are copied to the new key
+	RelationShips []int
+	// expiration(unix time) of the old key's capabilityDelegation
+	DelegationExpiration int64
+
+	Completed bool
+}
+
+// RotateKey replaces oldKey with a new key. For key-N, the new key is added as a method of the same controller,
+// every relationship of the old key is copied to the new key, and then the old key is deactivated.
+// masterKey is bound to capabilityInvocation, so it is updated in place.
+// If an error is returned, the rotation can be passed to ResumeRotation or RollbackRotation.
+func (c *MemoDIDController) RotateKey(oldKey MemoDIDUrl, vtype string, publicKeyHex string) (*KeyRotation, error) {
+	publicKey, err := DecodePublicKey(publicKeyHex)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidatePublicKey(vtype, publicKey); err != nil {
+		return nil, err
+	}
+
+	did := oldKey.DID()
+	document, err := c.resolver().Resolve(did.String())
+	if err != nil {
+		return nil, err
+	}
+	if isDeactivatedDocument(document) {
+		return nil, xerrors.Errorf("%s: %w", did.String(), ErrDeactivated)
+	}
+	old := findVerificationMethod(document, oldKey)
+	if old == nil {
+		return nil, xerrors.Errorf("%s is not an activated verification method", oldKey.String())
+	}
+	// x25519 keys are only used for key agreement
+	if isX25519Type(old.Type) != isX25519Type(vtype) {
+		return nil, xerrors.Errorf("%s(%s) can't be rotated to %s", oldKey.String(), old.Type, vtype)
+	}
+	oldPublicKey, err := old.PublicKeyBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	rotation := &KeyRotation{
+		DID:             did,
+		Old:             oldKey,
+		Controller:      old.Controller,
+		Type:            vtype,
+		PublicKeyHex:    hexutil.Encode(publicKey),
+		OldType:         old.Type,
+		OldPublicKeyHex: hexutil.Encode(oldPublicKey),
+	}
+
+	if oldKey.GetMethodIndex() == 0 {
+		if isX25519Type(vtype) {
+			return nil, xerrors.Errorf("%s can't be used as masterKey", vtype)
+		}
+		rotation.New = &oldKey
+		if err := c.UpdateVerificationMethod(oldKey, vtype, rotation.PublicKeyHex); err != nil {
+			return rotation, err
+		}
+		rotation.Completed = true
+		return rotation, nil
+	}
+
+	rotation.setRelationShips(document)
+	return rotation, c.ResumeRotation(rotation)
+}
+
+// setRelationShips records every relationship that the old key holds in document
+func (r *KeyRotation) setRelationShips(document *MemoDIDDocument) {
+	r.RelationShips = nil
+	for _, relationType := range []int{Authentication, AssertionMethod, CapabilityDelegation, Recovery, KeyAgreement, CapabilityInvocation} {
+		if !document.HasRelationShip(relationType, r.Old) {
+			continue
+		}
+		r.RelationShips = append(r.RelationShips, relationType)
+		if relationType == CapabilityDelegation {
+			r.DelegationExpiration = document.CapabilityDelegationExpiration[r.Old.String()]
+		}
+	}
+}
+
+// ResumeRotation runs the steps of rotation which are not done yet
+func (c *MemoDIDController) ResumeRotation(rotation *KeyRotation) error {
+	if rotation.Completed {
+		return nil
+	}
+	if rotation.Old.GetMethodIndex() == 0 {
+		if err := c.UpdateVerificationMethod(rotation.Old, rotation.Type, rotation.PublicKeyHex); err != nil {
+			return err
+		}
+		rotation.Completed = true
+		return nil
+	}
+
+	document, err := c.resolver().Resolve(rotation.DID.String())
+	if err != nil {
+		return err
+	}
+	methodCount, err := c.methodCount(rotation.DID)
+	if err != nil {
+		return err
+	}
+	operations, err := planRotation(document, rotation, methodCount)
+	if err != nil {
+		return err
+	}
+	if err := runOperations(c, operations); err != nil {
+		return err
+	}
+
+	rotation.Completed = true
+	return nil
+}
+
+// RollbackRotation deactivates the new key of a rotation whose old key is still activated,
+// a rotated masterKey is updated back to the old key
+func (c *MemoDIDController) RollbackRotation(rotation *KeyRotation) error {
+	if rotation.Old.GetMethodIndex() == 0 {
+		if err := c.UpdateVerificationMethod(rotation.Old, rotation.OldType, rotation.OldPublicKeyHex); err != nil {
+			return err
+		}
+		rotation.Completed = false
+		return nil
+	}
+
+	document, err := c.resolver().Resolve(rotation.DID.String())
+	if err != nil {
+		return err
+	}
+	operations, err := planRollback(document, rotation)
+	if err != nil {
+		return err
+	}
+	if err := runOperations(c, operations); err != nil {
+		return err
+	}
+
+	rotation.New = nil
+	rotation.Completed = false
+	return nil
+}
+
+// planRotation returns the steps left: add the new key, copy relationships and deactivate the old key.
+// Steps already on chain are skipped, so a rotation can be resumed from any point.
+func planRotation(document *MemoDIDDocument, rotation *KeyRotation, methodCount int64) ([]Operation, error) {
+	if isDeactivatedDocument(document) {
+		return nil, xerrors.Errorf("%s: %w", rotation.DID.String(), ErrDeactivated)
+	}
+	did := rotation.DID
+	var operations []Operation
+
+	old := findVerificationMethod(document, rotation.Old)
+	added, err := rotatedKeyAdded(document, rotation)
+	if err != nil {
+		return nil, err
+	}
+	if !added {
+		if old == nil {
+			return nil, xerrors.Errorf("%s is deactivated before %s is added", rotation.Old.String(), rotation.PublicKeyHex)
+		}
+		newKey, err := did.DIDUrl(methodCount)
+		if err != nil {
+			return nil, err
+		}
+		rotation.New = newKey
+		vtype, controller, publicKeyHex := rotation.Type, rotation.Controller, rotation.PublicKeyHex
+		operations = append(operations, Operation{
+			Description: fmt.Sprintf("AddVerificationMethod(%s, %s, %s, %s)", did.String(), vtype, controller.String(), publicKeyHex),
+			run: func(c DIDController) error {
+				return c.AddVerificationMethod(did, vtype, controller, publicKeyHex)
+			},
+		})
+	}
+
+	newKey := *rotation.New
+	for _, relationType := range rotation.RelationShips {
+		if added && document.HasRelationShip(relationType, newKey) {
+			continue
+		}
+		relationType := relationType
+		if relationType == CapabilityDelegation {
+			// the new key is delegated until the old key expires, an expired delegation is copied as it is
+			expiration := rotation.DelegationExpiration
+			operations = append(operations, Operation{
+				Description: fmt.Sprintf("AddRelationShip(%s, %s, %s, until %s)", did.String(), relationShipName(relationType), newKey.String(), time.Unix(expiration, 0).UTC().Format(time.RFC3339)),
+				run: func(c DIDController) error {
+					if dc, ok := c.(delegationController); ok {
+						return dc.addDelegationUntil(did, newKey, expiration)
+					}
+					return c.AddRelationShip(did, relationType, newKey, expiration-time.Now().Unix())
+				},
+			})
+			continue
+		}
+		operations = append(operations, Operation{
+			Description: fmt.Sprintf("AddRelationShip(%s, %s, %s, 0)", did.String(), relationShipName(relationType), newKey.String()),
+			run: func(c DIDController) error {
+				return c.AddRelationShip(did, relationType, newKey, 0)
+			},
+		})
+	}
+
+	if old != nil {
+		oldKey := rotation.Old
+		operations = append(operations, Operation{
+			Description: fmt.Sprintf("DeactivateVerificationMethod(%s)", oldKey.String()),
+			run: func(c DIDController) error {
+				return c.DeactivateVerificationMethod(oldKey)
+			},
+		})
+	}
+
+	return operations, nil
+}
+
+func planRollback(document *MemoDIDDocument, rotation *KeyRotation) ([]Operation, error) {
+	if findVerificationMethod(document, rotation.Old) == nil {
+		return nil, xerrors.Errorf("%s is already deactivated, the rotation can't be rolled back", rotation.Old.String())
+	}
+	added, err := rotatedKeyAdded(document, rotation)
+	if err != nil {
+		return nil, err
+	}
+	if !added {
+		return nil, nil
+	}
+
+	// relationships of the new key are removed with it
+	newKey := *rotation.New
+	return []Operation{{
+		Description: fmt.Sprintf("DeactivateVerificationMethod(%s)", newKey.String()),
+		run: func(c DIDController) error {
+			return c.DeactivateVerificationMethod(newKey)
+		},
+	}}, nil
+}
+
+// rotatedKeyAdded reports whether the new key of rotation is an activated method of the document
+func rotatedKeyAdded(document *MemoDIDDocument, rotation *KeyRotation) (bool, error) {
+	if rotation.New == nil {
+		return false, nil
+	}
+	method := findVerificationMethod(document, *rotation.New)
+	if method == nil {
+		return false, nil
+	}
+	publicKey, err := DecodePublicKey(rotation.PublicKeyHex)
+	if err != nil {
+		return false, err
+	}
+	methodKey, err := method.PublicKeyBytes()
+	if err != nil {
+		return false, err
+	}
+	return method.Type == rotation.Type && bytes.Equal(compressPublicKey(methodKey), compressPublicKey(publicKey)), nil
+}
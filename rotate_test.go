@@ -0,0 +1,180 @@
+package memodid
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestPlanRotation(t *testing.T) {
+	_, pks, err := ToPublicKeys([]string{globalPrivateKey2, globalPrivateKey3})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document, err := genDocument(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	did := document.ID
+	key1, err := genVerificationMethod(&did, 1, nil, EcdsaSecp256k1VerificationKey2019, pks[0])
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document.VerificationMethod = append(document.VerificationMethod, key1)
+	document.Authentication = []MemoDIDUrl{key1.ID}
+	document.CapabilityDelegation = []MemoDIDUrl{key1.ID}
+
+	document.CapabilityDelegationExpiration = map[string]int64{key1.ID.String(): 1000}
+
+	rotation := &KeyRotation{
+		DID:          did,
+		Old:          key1.ID,
+		Controller:   did,
+		Type:         EcdsaSecp256k1VerificationKey2019,
+		PublicKeyHex: pks[1],
+	}
+	rotation.setRelationShips(document)
+	if len(rotation.RelationShips) != 2 || rotation.DelegationExpiration != 1000 {
+		t.Errorf("Unexpect relationships of the old key: %v, %d", rotation.RelationShips, rotation.DelegationExpiration)
+		return
+	}
+	operations, err := planRotation(document, rotation, 2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	r := &recordController{}
+	err = runOperations(r, operations)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	expected := []string{
+		"AddVerificationMethod " + EcdsaSecp256k1VerificationKey2019,
+		"AddRelationShip authentication key-2",
+		"AddRelationShip capabilityDelegation key-2 until 1000",
+		"DeactivateVerificationMethod key-1",
+	}
+	if strings.Join(r.ops, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpect operations:\n%s", strings.Join(r.ops, "\n"))
+		return
+	}
+	if rotation.New == nil || rotation.New.Fragment != "key-2" {
+		t.Errorf("Unexpect new key: %v", rotation.New)
+		return
+	}
+
+	// resume after the new key and authentication are added
+	key2, err := genVerificationMethod(&did, 2, nil, EcdsaSecp256k1VerificationKey2019, pks[1])
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document.VerificationMethod = append(document.VerificationMethod, key2)
+	document.Authentication = append(document.Authentication, key2.ID)
+	operations, err = planRotation(document, rotation, 3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	r = &recordController{}
+	err = runOperations(r, operations)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	expected = []string{
+		"AddRelationShip capabilityDelegation key-2 until 1000",
+		"DeactivateVerificationMethod key-1",
+	}
+	if strings.Join(r.ops, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpect operations after resume:\n%s", strings.Join(r.ops, "\n"))
+		return
+	}
+
+	// roll back
+	operations, err = planRollback(document, rotation)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(operations) != 1 || operations[0].String() != "DeactivateVerificationMethod("+key2.ID.String()+")" {
+		t.Errorf("Unexpect rollback: %v", operations)
+		return
+	}
+
+	// old key is deactivated, nothing left
+	document.VerificationMethod = []VerificationMethod{document.VerificationMethod[0], key2}
+	document.CapabilityDelegation = []MemoDIDUrl{key2.ID}
+	operations, err = planRotation(document, rotation, 3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(operations) != 0 {
+		t.Errorf("Unexpect operations of completed rotation: %v", operations)
+		return
+	}
+	_, err = planRollback(document, rotation)
+	if err == nil {
+		t.Error("Rotation can't be rolled back after the old key is deactivated")
+	}
+}
+
+func TestPlanKeyAgreementRotation(t *testing.T) {
+	_, oldKey, err := GenerateX25519Key()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	_, newKey, err := GenerateX25519Key()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document, err := genDocument(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	did := document.ID
+	key1, err := genVerificationMethod(&did, 1, nil, X25519KeyAgreementKey2020, hexutil.Encode(oldKey))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document.VerificationMethod = append(document.VerificationMethod, key1)
+	document.KeyAgreement = queryAllKeyAgreement(document.VerificationMethod)
+
+	rotation := &KeyRotation{
+		DID:          did,
+		Old:          key1.ID,
+		Controller:   did,
+		Type:         X25519KeyAgreementKey2020,
+		PublicKeyHex: hexutil.Encode(newKey),
+	}
+	rotation.setRelationShips(document)
+	operations, err := planRotation(document, rotation, 2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	r := &recordController{}
+	err = runOperations(r, operations)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	expected := []string{
+		"AddVerificationMethod " + X25519KeyAgreementKey2020,
+		"AddRelationShip keyAgreement key-2",
+		"DeactivateVerificationMethod key-1",
+	}
+	if strings.Join(r.ops, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpect operations:\n%s", strings.Join(r.ops, "\n"))
+	}
+}
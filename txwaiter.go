@@ -0,0 +1,201 @@
+package memodid
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"golang.org/x/xerrors"
+)
+
+// TxWaiter waits for the receipt of a transaction. New blocks are watched through newHeads
+// subscription if the endpoint supports it(websocket), otherwise the receipt is polled.
+type TxWaiter struct {
+	// interval of polling the receipt
+	PollInterval time.Duration
+	// no timeout if it is 0
+	Timeout time.Duration
+	// number of blocks after the block that packages the transaction,
+	// 0 means the receipt is returned once it is got
+	Confirmations uint64
+}
+
+// DefaultTxWaiter polls the receipt every block(5s) for one minute
+func DefaultTxWaiter() *TxWaiter {
+	return &TxWaiter{
+		PollInterval: 5 * time.Second,
+		Timeout:      time.Minute,
+	}
+}
+
+// txBackend is the part of ethclient.Client used by TxWaiter
+type txBackend interface {
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	TransactionByHash(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+}
+
+var _ txBackend = &ethclient.Client{}
+
+// Wait waits until transaction txHash of operation name is packaged and confirmed, and returns its receipt,
+// receipt.BlockNumber is the block that packages the transaction. It returns:
+//   - ErrReceiptTimeout if the transaction isn't confirmed before timeout
+//   - ErrTxDropped if the transaction is removed from the pool without being packaged
+//   - ErrTxReplaced if another transaction with the same nonce is packaged
+//   - *ErrTxReverted with the receipt if the transaction failed
+func (w *TxWaiter) Wait(ctx context.Context, endPoint string, txHash common.Hash, name string) (*types.Receipt, error) {
+	client, err := ethclient.DialContext(ctx, endPoint)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	return w.wait(ctx, client, txHash, name)
+}
+
+func (w *TxWaiter) wait(ctx context.Context, backend txBackend, txHash common.Hash, name string) (*types.Receipt, error) {
+	if w.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.Timeout)
+		defer cancel()
+	}
+
+	interval := w.PollInterval
+	if interval <= 0 {
+		interval = DefaultTxWaiter().PollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// http endpoint doesn't support subscription, just poll
+	heads := make(chan *types.Header, 1)
+	var subErr <-chan error
+	sub, err := backend.SubscribeNewHead(ctx, heads)
+	if err == nil {
+		defer sub.Unsubscribe()
+		subErr = sub.Err()
+	}
+
+	var state txState
+	for {
+		receipt, done, err := w.check(ctx, backend, txHash, &state)
+		if err != nil {
+			return receipt, xerrors.Errorf("%s: %w", name, err)
+		}
+		if done {
+			if receipt.Status == types.ReceiptStatusFailed {
+				return receipt, txReverted(ctx, backend, txHash, name, receipt)
+			}
+			return receipt, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, xerrors.Errorf("%s: cann't get transaction(%s) receipt, not packaged: %w", name, txHash, ErrReceiptTimeout)
+		case <-ticker.C:
+		case <-heads:
+		case <-subErr:
+			// subscription is broken, fall back to polling
+			subErr = nil
+		}
+	}
+}
+
+// txState records the sender and nonce of the transaction once it is seen in the pool
+type txState struct {
+	seen   bool
+	sender common.Address
+	nonce  uint64
+}
+
+// check returns the receipt and done=true once the transaction is confirmed
+func (w *TxWaiter) check(ctx context.Context, backend txBackend, txHash common.Hash, state *txState) (*types.Receipt, bool, error) {
+	receipt, err := backend.TransactionReceipt(ctx, txHash)
+	if err == nil && receipt != nil {
+		if w.Confirmations == 0 {
+			return receipt, true, nil
+		}
+		head, err := backend.BlockNumber(ctx)
+		if err != nil {
+			return nil, false, nil
+		}
+		// the receipt is queried again until confirmed, so the block of a reorged transaction is updated
+		return receipt, head >= receipt.BlockNumber.Uint64()+w.Confirmations, nil
+	}
+	if err != nil && err != ethereum.NotFound {
+		// try again later
+		return nil, false, nil
+	}
+
+	tx, _, err := backend.TransactionByHash(ctx, txHash)
+	if err == nil {
+		if !state.seen {
+			sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+			if err != nil {
+				return nil, false, err
+			}
+			state.seen, state.sender, state.nonce = true, sender, tx.Nonce()
+		}
+		return nil, false, nil
+	}
+	// a transaction not seen yet may be still broadcasting
+	if err != ethereum.NotFound || !state.seen {
+		return nil, false, nil
+	}
+
+	nonce, err := backend.NonceAt(ctx, state.sender, nil)
+	if err != nil {
+		return nil, false, nil
+	}
+	// the transaction may be packaged after its receipt is queried, check it again
+	receipt, err = backend.TransactionReceipt(ctx, txHash)
+	if err != ethereum.NotFound {
+		return nil, false, nil
+	}
+	if nonce > state.nonce {
+		return nil, false, xerrors.Errorf("transaction(%s) with nonce %d: %w", txHash, state.nonce, ErrTxReplaced)
+	}
+	return nil, false, xerrors.Errorf("transaction(%s): %w", txHash, ErrTxDropped)
+}
+
+func txReverted(ctx context.Context, backend txBackend, txHash common.Hash, name string, receipt *types.Receipt) error {
+	tx, _, err := backend.TransactionByHash(ctx, txHash)
+	if err != nil {
+		return &ErrTxReverted{Name: name, Hash: txHash}
+	}
+	// a transaction running out of gas uses all of its gas limit
+	if receipt.GasUsed == tx.Gas() {
+		return &ErrTxReverted{Name: name, Hash: txHash, Reason: "out of gas"}
+	}
+	return &ErrTxReverted{Name: name, Hash: txHash, Reason: revertReason(ctx, backend, tx, receipt)}
+}
+
+// revertReason replays the failed transaction in the block it is packaged to get the revert reason
+// of the contract, it returns "" if the reason can't be got
+func revertReason(ctx context.Context, backend txBackend, tx *types.Transaction, receipt *types.Receipt) string {
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return ""
+	}
+
+	msg := ethereum.CallMsg{
+		From:  from,
+		To:    tx.To(),
+		Gas:   tx.Gas(),
+		Value: tx.Value(),
+		Data:  tx.Data(),
+	}
+	_, err = backend.CallContract(ctx, msg, receipt.BlockNumber)
+	if err == nil {
+		return ""
+	}
+	return strings.TrimPrefix(err.Error(), "execution reverted: ")
+}
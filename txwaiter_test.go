@@ -0,0 +1,206 @@
+package memodid
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/xerrors"
+)
+
+// mockBackend is a chain with one transaction, step is called before each poll
+type mockBackend struct {
+	tx      *types.Transaction
+	pending bool
+	receipt *types.Receipt
+	head    uint64
+	nonce   uint64
+	reason  string
+
+	polls int
+	step  func(b *mockBackend)
+	// called when the nonce is queried
+	onNonce func(b *mockBackend)
+}
+
+func (b *mockBackend) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	b.polls++
+	if b.step != nil {
+		b.step(b)
+	}
+	if b.receipt == nil {
+		return nil, ethereum.NotFound
+	}
+	return b.receipt, nil
+}
+
+func (b *mockBackend) TransactionByHash(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error) {
+	if !b.pending && b.receipt == nil {
+		return nil, false, ethereum.NotFound
+	}
+	return b.tx, b.pending, nil
+}
+
+func (b *mockBackend) BlockNumber(ctx context.Context) (uint64, error) {
+	return b.head, nil
+}
+
+func (b *mockBackend) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	if b.onNonce != nil {
+		b.onNonce(b)
+	}
+	return b.nonce, nil
+}
+
+func (b *mockBackend) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return nil, xerrors.Errorf("execution reverted: %s", b.reason)
+}
+
+func (b *mockBackend) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	return nil, xerrors.Errorf("notifications not supported")
+}
+
+func newMockBackend(nonce uint64) (*mockBackend, error) {
+	sk, err := crypto.HexToECDSA(globalPrivateKey1)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(0), 300000, big.NewInt(1), nil), types.LatestSignerForChainID(big.NewInt(985)), sk)
+	if err != nil {
+		return nil, err
+	}
+	return &mockBackend{tx: tx, pending: true, nonce: nonce}, nil
+}
+
+func TestTxWaiterConfirmations(t *testing.T) {
+	backend, err := newMockBackend(3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	backend.step = func(b *mockBackend) {
+		if b.polls == 2 {
+			b.pending = false
+			b.receipt = &types.Receipt{Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(100)}
+			b.head = 100
+		} else if b.receipt != nil {
+			b.head++
+		}
+	}
+
+	waiter := &TxWaiter{PollInterval: time.Millisecond, Timeout: time.Second, Confirmations: 3}
+	receipt, err := waiter.wait(context.TODO(), backend, backend.tx.Hash(), "AddController")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if receipt.BlockNumber.Uint64() != 100 || backend.head != 103 {
+		t.Errorf("Unexpect receipt of block %d at head %d", receipt.BlockNumber.Uint64(), backend.head)
+	}
+}
+
+func TestTxWaiterFailures(t *testing.T) {
+	waiter := &TxWaiter{PollInterval: time.Millisecond, Timeout: 50 * time.Millisecond}
+
+	// removed from the pool while the nonce isn't used
+	backend, err := newMockBackend(3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	backend.step = func(b *mockBackend) {
+		if b.polls == 2 {
+			b.pending = false
+		}
+	}
+	_, err = waiter.wait(context.TODO(), backend, backend.tx.Hash(), "AddController")
+	if !errors.Is(err, ErrTxDropped) {
+		t.Errorf("Unexpect error: %v", err)
+		return
+	}
+
+	// another transaction with the same nonce is packaged
+	backend, err = newMockBackend(3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	backend.step = func(b *mockBackend) {
+		if b.polls == 2 {
+			b.pending = false
+			b.nonce = 4
+		}
+	}
+	_, err = waiter.wait(context.TODO(), backend, backend.tx.Hash(), "AddController")
+	if !errors.Is(err, ErrTxReplaced) {
+		t.Errorf("Unexpect error: %v", err)
+		return
+	}
+
+	// packaged between the queries of receipt and nonce
+	backend, err = newMockBackend(3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	backend.step = func(b *mockBackend) {
+		if b.polls == 2 {
+			b.pending = false
+		}
+	}
+	backend.onNonce = func(b *mockBackend) {
+		b.nonce = 4
+		b.receipt = &types.Receipt{Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(100)}
+	}
+	_, err = waiter.wait(context.TODO(), backend, backend.tx.Hash(), "AddController")
+	if err != nil {
+		t.Errorf("Packaged transaction should not be reported as replaced: %v", err)
+		return
+	}
+
+	// never seen
+	backend, err = newMockBackend(3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	backend.pending = false
+	_, err = waiter.wait(context.TODO(), backend, backend.tx.Hash(), "AddController")
+	if !errors.Is(err, ErrReceiptTimeout) {
+		t.Errorf("Unexpect error: %v", err)
+		return
+	}
+
+	// reverted
+	backend, err = newMockBackend(3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	backend.reason = "not controller"
+	// not the first transaction of the block
+	backend.receipt = &types.Receipt{Status: types.ReceiptStatusFailed, BlockNumber: big.NewInt(100), GasUsed: 21000, CumulativeGasUsed: 63000}
+	receipt, err := waiter.wait(context.TODO(), backend, backend.tx.Hash(), "AddController")
+	var reverted *ErrTxReverted
+	if !errors.As(err, &reverted) || reverted.Reason != "not controller" || reverted.Name != "AddController" {
+		t.Errorf("Unexpect error: %v", err)
+		return
+	}
+	if receipt == nil || receipt.BlockNumber.Uint64() != 100 {
+		t.Errorf("Unexpect receipt: %v", receipt)
+		return
+	}
+
+	// all gas is used
+	backend.receipt = &types.Receipt{Status: types.ReceiptStatusFailed, BlockNumber: big.NewInt(100), GasUsed: backend.tx.Gas(), CumulativeGasUsed: backend.tx.Gas()}
+	_, err = waiter.wait(context.TODO(), backend, backend.tx.Hash(), "AddController")
+	if !errors.As(err, &reverted) || reverted.Reason != "out of gas" {
+		t.Errorf("Unexpect error: %v", err)
+	}
+}
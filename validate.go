@@ -0,0 +1,226 @@
+package memodid
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// zero did used as placeholder of empty controller
+const zeroIdentifier = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// ValidationError is a violation of DID Core rules, Path locates the offending property,
+// e.g. verificationMethod[1].publicKeyHex
+type ValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationErrors is the list of all violations in a document
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return "invalid did document: " + strings.Join(msgs, "; ")
+}
+
+type validator struct {
+	errs ValidationErrors
+	// dids of other methods are accepted in resolved documents of other methods,
+	// otherwise dids must be memo dids as they are written to contract
+	anyMethod bool
+}
+
+func (v *validator) add(path string, format string, args ...interface{}) {
+	v.errs = append(v.errs, &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// Validate checks the document against DID Core rules,
+// the returned error is ValidationErrors if the document is not well-formed
+func (d *MemoDIDDocument) Validate() error {
+	v := &validator{anyMethod: d.ID.Method != "memo"}
+
+	if d.Context != DefaultContext {
+		v.add("@context", "must be %s", DefaultContext)
+	}
+	v.validateDID("id", d.ID)
+
+	seen := make(map[string]bool)
+	for i, controller := range d.Controller {
+		path := fmt.Sprintf("controller[%d]", i)
+		v.validateDID(path, controller)
+		if seen[controller.Identifier] {
+			v.add(path, "duplicate controller %s", controller.String())
+		}
+		seen[controller.Identifier] = true
+	}
+
+	methods := make(map[string]*VerificationMethod)
+	for i := range d.VerificationMethod {
+		method := &d.VerificationMethod[i]
+		path := fmt.Sprintf("verificationMethod[%d]", i)
+		v.validateVerificationMethod(path, method)
+		if method.ID.Identifier != d.ID.Identifier {
+			v.add(path+".id", "%s doesn't belong to %s", method.ID.String(), d.ID.String())
+		}
+		if _, ok := methods[method.ID.String()]; ok {
+			v.add(path+".id", "duplicate verification method %s", method.ID.String())
+		}
+		methods[method.ID.String()] = method
+	}
+
+	relationShips := []struct {
+		name   string
+		didUrl []MemoDIDUrl
+	}{
+		{"authentication", d.Authentication},
+		{"assertionMethod", d.AssertionMethod},
+		{"capabilityDelegation", d.CapabilityDelegation},
+		{"recovery", d.Recovery},
+		{"keyAgreement", d.KeyAgreement},
+		{"capabilityInvocation", d.CapabilityInvocation},
+	}
+	for _, relationShip := range relationShips {
+		seen := make(map[string]bool)
+		for i, didUrl := range relationShip.didUrl {
+			path := fmt.Sprintf("%s[%d]", relationShip.name, i)
+			if seen[didUrl.String()] {
+				v.add(path, "duplicate reference %s", didUrl.String())
+			}
+			seen[didUrl.String()] = true
+
+			// methods of other DIDs are referenced, they can't be checked locally
+			if didUrl.Identifier != d.ID.Identifier {
+				continue
+			}
+			method, ok := methods[didUrl.String()]
+			if !ok {
+				v.add(path, "%s has no matching verification method", didUrl.String())
+				continue
+			}
+			if relationShip.name == "keyAgreement" && !isX25519Type(method.Type) {
+				v.add(path, "%s(%s) can't be used for key agreement", didUrl.String(), method.Type)
+			}
+			if relationShip.name != "keyAgreement" && isX25519Type(method.Type) {
+				v.add(path, "%s(%s) can only be used for key agreement", didUrl.String(), method.Type)
+			}
+		}
+	}
+
+	// maps are iterated in random order, so errors are sorted by DID URL
+	var expirations []string
+	for id := range d.CapabilityDelegationExpiration {
+		expirations = append(expirations, id)
+	}
+	sort.Strings(expirations)
+	for _, id := range expirations {
+		path := fmt.Sprintf("capabilityDelegationExpiration[%s]", id)
+		didUrl, err := ParseMemoDIDUrl(id)
+		if err != nil {
+			v.add(path, "%s", err.Error())
+			continue
+		}
+		if !d.HasRelationShip(CapabilityDelegation, *didUrl) {
+			v.add(path, "%s is not in capabilityDelegation", id)
+		}
+		if d.CapabilityDelegationExpiration[id] <= 0 {
+			v.add(path, "invalid expiration %d", d.CapabilityDelegationExpiration[id])
+		}
+	}
+
+	return v.err()
+}
+
+// Validate checks the verification method against DID Core rules
+func (v *VerificationMethod) Validate() error {
+	vd := &validator{anyMethod: v.ID.Method != "memo"}
+	vd.validateVerificationMethod("verificationMethod", v)
+	return vd.err()
+}
+
+// validateDID checks did is a memo did, dids of other methods are accepted without checking
+// their method-specific-id only if v.anyMethod is set
+func (v *validator) validateDID(path string, did MemoDID) {
+	if did.Method == "" || did.Identifier == "" {
+		v.add(path, "missing method or method-specific-id")
+		return
+	}
+	if did.Method != "memo" {
+		if !v.anyMethod {
+			v.add(path, "unsupported method %s", did.Method)
+		}
+		return
+	}
+	if isNot32ByteHex(did.Identifier) {
+		v.add(path, "%s is not 32 byte hex string", did.Identifier)
+	}
+	if did.Identifier == zeroIdentifier {
+		v.add(path, "zero did is not a valid did")
+	}
+}
+
+func (v *validator) validateVerificationMethod(path string, method *VerificationMethod) {
+	if method.ID.Method == "memo" && method.ID.GetMethodIndex() < 0 {
+		v.add(path+".id", "unsupported fragment %s", method.ID.Fragment)
+	}
+	v.validateDID(path+".controller", method.Controller)
+
+	// exactly one representation of public key
+	var representations []string
+	if method.PublicKeyHex != "" {
+		representations = append(representations, "publicKeyHex")
+	}
+	if method.PublicKeyMultibase != "" {
+		representations = append(representations, "publicKeyMultibase")
+	}
+	if method.PublicKeyJwk != nil {
+		representations = append(representations, "publicKeyJwk")
+	}
+	if method.BlockchainAccountID != "" {
+		representations = append(representations, "blockchainAccountId")
+	}
+	switch len(representations) {
+	case 0:
+		v.add(path, "missing public key")
+		return
+	case 1:
+	default:
+		v.add(path, "multiple public key representations: %s", strings.Join(representations, ", "))
+		return
+	}
+
+	keyPath := path + "." + representations[0]
+	if method.BlockchainAccountID != "" {
+		if method.Type != EcdsaSecp256k1VerificationKey2019 && method.Type != EcdsaSecp256k1RecoveryMethod2020 {
+			v.add(keyPath, "%s doesn't support blockchainAccountId", method.Type)
+		}
+		parts := strings.Split(method.BlockchainAccountID, ":")
+		if len(parts) != 3 || parts[0] != "eip155" || len(parts[2]) != 42 {
+			v.add(keyPath, "%s is not a CAIP-10 account id", method.BlockchainAccountID)
+		}
+		return
+	}
+
+	publicKey, err := method.PublicKeyBytes()
+	if err != nil {
+		v.add(keyPath, "%s", err.Error())
+		return
+	}
+	if err := ValidatePublicKey(method.Type, publicKey); err != nil {
+		v.add(keyPath, "%s", err.Error())
+	}
+}
@@ -0,0 +1,95 @@
+package memodid
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+func TestValidate(t *testing.T) {
+	document, err := genDocument(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	document.Authentication = []MemoDIDUrl{document.VerificationMethod[0].ID}
+	if err := document.Validate(); err != nil {
+		t.Errorf("Validating a well-formed document should not report an error: %s", err.Error())
+		return
+	}
+
+	zeroDID, err := ParseMemoDID("did:memo:" + zeroIdentifier)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	missing, err := document.ID.DIDUrl(3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	document.Controller = []MemoDID{*zeroDID}
+	document.AssertionMethod = []MemoDIDUrl{*missing}
+	document.VerificationMethod[0].PublicKeyHex = "0x0102"
+
+	err = document.Validate()
+	var errs ValidationErrors
+	if !xerrors.As(err, &errs) {
+		t.Errorf("Validate should report ValidationErrors: %v", err)
+		return
+	}
+
+	paths := make(map[string]bool)
+	for _, e := range errs {
+		paths[e.Path] = true
+	}
+	for _, path := range []string{"controller[0]", "assertionMethod[0]", "verificationMethod[0].publicKeyHex"} {
+		if !paths[path] {
+			t.Errorf("Missing validation error at %s: %s", path, err.Error())
+		}
+	}
+
+	// invalid documents are still resolved, the violations are in metadata
+	data, err := json.Marshal(&ResolutionResult{Document: document, DocumentMetadata: DocumentMetadata{ValidationErrors: errs}})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !strings.Contains(string(data), `"validationErrors":[{"path":"controller[0]"`) {
+		t.Errorf("Unexpect metadata: %s", data)
+	}
+}
+
+func TestValidateMethod(t *testing.T) {
+	document, err := genDocument(globalPrivateKey1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	web, err := ParseDID("did:web:example.com")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// only memo dids can be written to contract
+	controller := &MemoDIDController{did: &document.ID}
+	if _, err := controller.addController(nil, document.ID, *web); err == nil {
+		t.Error("did:web should not be added as controller")
+		return
+	}
+	document.Controller = []MemoDID{*web}
+	if err := document.Validate(); err == nil {
+		t.Error("Memo document can't be controlled by did:web")
+		return
+	}
+
+	// resolved documents of other methods are checked by DID Core rules only
+	webDocument := &MemoDIDDocument{Context: DefaultContext, ID: *web, Controller: []MemoDID{*web}}
+	if err := webDocument.Validate(); err != nil {
+		t.Error(err.Error())
+	}
+}